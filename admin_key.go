@@ -0,0 +1,113 @@
+package secretsengine
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	"github.com/hashicorp/go-hclog"
+)
+
+// adminKeyExpiryWarning is how long before key_expires_at warnings are
+// returned and logged.
+const adminKeyExpiryWarning = 14 * 24 * time.Hour
+
+//nolint:gochecknoglobals // metric names, shared with the docs.
+var (
+	adminKeyHealthyGauge   = []string{"grafanacloud", "admin_key", "healthy"}
+	adminKeyExpiresInGauge = []string{"grafanacloud", "admin_key", "expires_in_seconds"}
+)
+
+// adminKeyExpiryError returns an error once the admin key has expired.
+func (c *Config) adminKeyExpiryError(now time.Time) error {
+	if c.KeyExpiresAt == nil || now.Before(*c.KeyExpiresAt) {
+		return nil
+	}
+
+	return NewInvalidConfigurationError(fmt.Sprintf("the admin key expired at %s, write a new key to the config",
+		c.KeyExpiresAt.Format(time.RFC3339)), nil)
+}
+
+// adminKeyExpiryWarning returns a warning when the admin key expires within
+// adminKeyExpiryWarning, or an empty string.
+func (c *Config) adminKeyExpiryWarning(now time.Time) string {
+	if c.KeyExpiresAt == nil || c.KeyExpiresAt.Sub(now) > adminKeyExpiryWarning {
+		return ""
+	}
+
+	if err := c.adminKeyExpiryError(now); err != nil {
+		return err.Error()
+	}
+
+	return fmt.Sprintf("the admin key expires at %s, rotate it before keys can no longer be issued or revoked",
+		c.KeyExpiresAt.Format(time.RFC3339))
+}
+
+// reportAdminKey emits the admin key metrics given the outcome of the last
+// request made with it, and logs when the key fails or is about to expire.
+func (b *grafanaCloudBackend) reportAdminKey(config *Config, requestErr error, now time.Time) {
+	labels := []metrics.Label{{Name: "organisation", Value: config.Organisation}}
+
+	healthy := float32(1)
+	if requestErr != nil {
+		healthy = 0
+
+		b.Logger().Error("the admin key could not be used, keys can neither be issued nor revoked",
+			"organisation", config.Organisation, "error", requestErr)
+	}
+
+	metrics.SetGaugeWithLabels(adminKeyHealthyGauge, healthy, labels)
+
+	if config.KeyExpiresAt == nil {
+		return
+	}
+
+	metrics.SetGaugeWithLabels(adminKeyExpiresInGauge, float32(config.KeyExpiresAt.Sub(now).Seconds()), labels)
+
+	if warning := config.adminKeyExpiryWarning(now); warning != "" {
+		b.Logger().Warn(warning, "organisation", config.Organisation)
+	}
+}
+
+// adminKeyFallbackTransport retries requests authenticated with the primary
+// admin key using the secondary key when the primary is rejected, so the
+// admin key can be rotated without an issuance outage.
+type adminKeyFallbackTransport struct {
+	base      http.RoundTripper
+	primary   string
+	secondary string
+	logger    hclog.Logger
+}
+
+func (t *adminKeyFallbackTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || (resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusForbidden) {
+		return resp, err
+	}
+
+	// Requests authenticated otherwise, such as with temporary stack keys,
+	// are not retried, nor are requests whose body cannot be replayed.
+	if req.Header.Get("Authorization") != "Bearer "+t.primary || (req.Body != nil && req.GetBody == nil) {
+		return resp, nil
+	}
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, bodyErr := req.GetBody()
+		if bodyErr != nil {
+			return resp, nil
+		}
+
+		retry.Body = body
+	}
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	t.logger.Warn("the admin key was rejected, retrying with secondary_key", "status", resp.StatusCode, "url", req.URL.Redacted())
+	retry.Header.Set("Authorization", "Bearer "+t.secondary)
+
+	return t.base.RoundTrip(retry)
+}
@@ -0,0 +1,159 @@
+package secretsengine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client/clienttest"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminKeyExpiry(t *testing.T) {
+	now := time.Date(2026, time.October, 14, 9, 0, 0, 0, time.UTC)
+
+	t.Run("Thresholds", func(t *testing.T) {
+		assert.Empty(t, (&Config{}).adminKeyExpiryWarning(now))
+		assert.NoError(t, (&Config{}).adminKeyExpiryError(now))
+
+		expiresAt := now.Add(30 * 24 * time.Hour)
+		config := &Config{KeyExpiresAt: &expiresAt}
+		assert.Empty(t, config.adminKeyExpiryWarning(now))
+
+		expiresAt = now.Add(24 * time.Hour)
+		assert.Equal(t, "the admin key expires at 2026-10-15T09:00:00Z, rotate it before keys can no longer be issued or revoked",
+			config.adminKeyExpiryWarning(now))
+		assert.NoError(t, config.adminKeyExpiryError(now))
+
+		expiresAt = now.Add(-time.Hour)
+		assert.EqualError(t, config.adminKeyExpiryError(now),
+			"invalid configuration: the admin key expired at 2026-10-14T08:00:00Z, write a new key to the config")
+		assert.Equal(t, config.adminKeyExpiryError(now).Error(), config.adminKeyExpiryWarning(now))
+	})
+
+	t.Run("Config", func(t *testing.T) {
+		server := clienttest.NewServer(organisation, key)
+		defer server.Close()
+
+		b, s := getTestBackend(t)
+
+		expiresAt := time.Now().UTC().Add(2 * 24 * time.Hour).Truncate(time.Second)
+
+		require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+			"key":            key,
+			"url":            server.URL + "/api",
+			"organisation":   organisation,
+			"key_expires_at": expiresAt.Format(time.RFC3339),
+		}))
+
+		readConfig := func() *logical.Response {
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.ReadOperation,
+				Path:      configStoragePath,
+				Storage:   s,
+			})
+			require.NoError(t, err)
+
+			return resp
+		}
+
+		resp := readConfig()
+		assert.Equal(t, expiresAt.Format(time.RFC3339), resp.Data["key_expires_at"])
+		assert.Len(t, resp.Warnings, 1)
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "health",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, true, resp.Data["healthy"])
+		assert.Len(t, resp.Warnings, 1)
+
+		// Writing a new key clears the expiry of the previous one.
+		require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{"key": "rotated"}))
+
+		resp = readConfig()
+		assert.Equal(t, "", resp.Data["key_expires_at"])
+		assert.Empty(t, resp.Warnings)
+
+		err = testConfigUpdate(b, s, map[string]interface{}{"key_expires_at": "next week"})
+		assert.EqualError(t, err, "invalid configuration: invalid key_expires_at, expected an RFC 3339 timestamp")
+	})
+
+	t.Run("Expired key fails the health check", func(t *testing.T) {
+		server := clienttest.NewServer(organisation, key)
+		defer server.Close()
+
+		b, s := getTestBackend(t)
+
+		require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+			"key":            key,
+			"url":            server.URL + "/api",
+			"organisation":   organisation,
+			"key_expires_at": "2020-01-01T00:00:00Z",
+		}))
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "health",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, false, resp.Data["healthy"])
+		assert.Equal(t, map[string]interface{}{
+			"ok":          false,
+			"status_code": 0,
+			"error":       "invalid configuration: the admin key expired at 2020-01-01T00:00:00Z, write a new key to the config",
+		}, resp.Data["checks"].(map[string]interface{})["admin_key"])
+	})
+}
+
+func TestAdminKeyFallback(t *testing.T) {
+	server := clienttest.NewServer(organisation, key)
+	defer server.Close()
+
+	b, s := getTestBackend(t)
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          "rotated-out",
+		"url":          server.URL + "/api",
+		"organisation": organisation,
+	}))
+
+	_, err := testTokenRoleCreate(t, b, s, "metrics_publisher", map[string]interface{}{
+		"gc_role": "MetricsPublisher",
+	})
+	require.NoError(t, err)
+
+	readCreds := func() (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/metrics_publisher",
+			Storage:   s,
+		})
+	}
+
+	_, err = readCreds()
+	require.ErrorContains(t, err, "401: Unauthorized")
+
+	require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{"secondary_key": key}))
+
+	resp, err := readCreds()
+	require.NoError(t, err)
+	require.False(t, resp.IsError())
+	assert.NotNil(t, server.CloudKey(resp.Data["key_name"].(string)))
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   s,
+		Secret:    resp.Secret,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, server.CloudKeys())
+
+	err = testConfigUpdate(b, s, map[string]interface{}{"key": key})
+	assert.EqualError(t, err, "invalid configuration: secondary_key must differ from key")
+}
@@ -0,0 +1,84 @@
+package secretsengine
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+)
+
+// apiLatencySamples is how many of the latest latencies of each operation
+// the percentiles of the metrics path are computed over.
+const apiLatencySamples = 1000
+
+// The operations whose latency is measured, used as the operation label.
+const (
+	apiOpCreateKey = "create_key"
+	apiOpDeleteKey = "delete_key"
+	apiOpListKeys  = "list_keys"
+)
+
+//nolint:gochecknoglobals // metric name, shared with the docs.
+var apiLatencyMetric = []string{"grafanacloud", "api", "latency"}
+
+//nolint:gochecknoglobals // reported in this order, with no samples as zero.
+var apiOps = []string{apiOpCreateKey, apiOpDeleteKey, apiOpListKeys}
+
+// apiLatencies keeps the latest latencies of each operation.
+type apiLatencies struct {
+	mu      sync.Mutex
+	samples map[string][]time.Duration
+	next    map[string]int
+}
+
+// record adds a latency of the operation, replacing the oldest once there
+// are apiLatencySamples.
+func (l *apiLatencies) record(op string, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.samples == nil {
+		l.samples, l.next = map[string][]time.Duration{}, map[string]int{}
+	}
+
+	if len(l.samples[op]) < apiLatencySamples {
+		l.samples[op] = append(l.samples[op], d)
+		return
+	}
+
+	l.samples[op][l.next[op]] = d
+	l.next[op] = (l.next[op] + 1) % apiLatencySamples
+}
+
+// percentiles returns the number of latencies kept for the operation and
+// their p50, p95 and p99, zero without any.
+func (l *apiLatencies) percentiles(op string) (int, [3]time.Duration) {
+	l.mu.Lock()
+	sorted := append([]time.Duration(nil), l.samples[op]...)
+	l.mu.Unlock()
+
+	var p [3]time.Duration
+
+	if len(sorted) == 0 {
+		return 0, p
+	}
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	for i, percentile := range []int{50, 95, 99} {
+		// Nearest rank: the smallest latency at or above the percentile.
+		rank := (percentile*len(sorted) + 99) / 100
+		p[i] = sorted[rank-1]
+	}
+
+	return len(sorted), p
+}
+
+// observeLatency records the time since start as a latency of the operation,
+// both as a telemetry sample and for the metrics path. Telemetry sinks
+// compute the percentiles themselves.
+func (b *grafanaCloudBackend) observeLatency(op string, start time.Time) {
+	metrics.MeasureSinceWithLabels(apiLatencyMetric, start, []metrics.Label{{Name: "operation", Value: op}})
+	b.latencies.record(op, time.Since(start))
+}
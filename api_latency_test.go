@@ -0,0 +1,36 @@
+package secretsengine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPILatencyPercentiles(t *testing.T) {
+	var l apiLatencies
+
+	count, p := l.percentiles(apiOpCreateKey)
+	assert.Equal(t, 0, count)
+	assert.Equal(t, [3]time.Duration{}, p)
+
+	for i := 1; i <= 100; i++ {
+		l.record(apiOpCreateKey, time.Duration(i)*time.Millisecond)
+	}
+
+	count, p = l.percentiles(apiOpCreateKey)
+	assert.Equal(t, 100, count)
+	assert.Equal(t, [3]time.Duration{50 * time.Millisecond, 95 * time.Millisecond, 99 * time.Millisecond}, p)
+
+	// The oldest latencies are replaced once apiLatencySamples are kept.
+	for i := 0; i < apiLatencySamples; i++ {
+		l.record(apiOpCreateKey, time.Second)
+	}
+
+	count, p = l.percentiles(apiOpCreateKey)
+	assert.Equal(t, apiLatencySamples, count)
+	assert.Equal(t, [3]time.Duration{time.Second, time.Second, time.Second}, p)
+
+	count, _ = l.percentiles(apiOpDeleteKey)
+	assert.Equal(t, 0, count)
+}
@@ -2,16 +2,25 @@ package secretsengine
 
 import (
 	"context"
+	"net/http"
 	"strings"
 	"sync"
+	"time"
 
-	grafanclient "github.com/grafana/grafana-api-golang-client"
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
 const grafanaCloudKeyType = "GrafanaCloudKey"
 
+// Groups used in DisplayAttrs so the Vault UI splits long forms into sections.
+// Fields without a group are rendered in the default section.
+const (
+	displayGroupEndpoints = "Endpoints"
+	displayGroupLease     = "Lease"
+)
+
 // CreateGrafanaAPIKeyInput grafana cloud data structure to create api credentials.
 type CreateGrafanaAPIKeyInput struct {
 	Name          string `json:"name"`
@@ -31,35 +40,89 @@ func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend,
 
 type grafanaCloudBackend struct {
 	*framework.Backend
-	lock   sync.RWMutex
-	client *grafanclient.Client
+	lock       sync.RWMutex
+	client     *cachedClient
+	httpClient *http.Client
+	// urlClients are used against the url of roles, keyed by it.
+	urlClients map[string]*cachedClient
+
+	issuanceRate     *issuanceRateTracker
+	inventory        keyInventory
+	revocationErrors revocationErrorLog
+	latencies        apiLatencies
+	stacks           *stackCache
+	roles            *roleCache
+	webhooks         sync.WaitGroup
+
+	tokenHMACLock sync.Mutex
+	tokenHMACKey  []byte
+
+	lokiEventsLock sync.Mutex
+
+	// outstandingKeysLock is held from checkOutstandingKeys until the key
+	// is recorded, see lockOutstandingKeys.
+	outstandingKeysLock sync.Mutex
 }
 
 func backend() *grafanaCloudBackend {
-	b := grafanaCloudBackend{}
+	b := grafanaCloudBackend{
+		issuanceRate: newIssuanceRateTracker(),
+		stacks:       newStackCache(),
+		roles:        newRoleCache(),
+	}
 
 	b.Backend = &framework.Backend{
 		Help: strings.TrimSpace(backendHelp),
 		PathsSpecial: &logical.Paths{
 			LocalStorage: []string{},
+			Root:         []string{revokeOrgKeysPath},
 			SealWrapStorage: []string{
 				"config",
 				"roles/*",
+				tokenHMACKeyStoragePath,
+				lokiEventsKeyStoragePath,
+				sealWrappedIssuancePrefix,
 			},
 		},
 		Paths: framework.PathAppend(
+			// The batch path must come first, the roles path also matches it.
+			[]*framework.Path{pathRolesBatch(&b)},
 			pathRole(&b),
 			[]*framework.Path{
 				pathConfig(&b),
+				pathConfigEndpoints(&b),
+				pathConfigCA(&b),
+				pathSettings(&b),
+				pathRoleCredentials(&b),
 				pathCredentials(&b),
+				pathEndpoints(&b),
+				pathAdopt(&b),
+				pathRevokeOrgKeys(&b),
+				pathVerify(&b),
+				pathDeprecations(&b),
+				pathRoleDrift(&b),
+				pathStackInfo(&b),
+				pathStacks(&b),
+				pathHealth(&b),
+				pathMetrics(&b),
+				pathDebugClient(&b),
+				pathKeys(&b),
+				pathUsage(&b),
+				pathAuditExport(&b),
+				pathAuditLookup(&b),
 			},
 		),
 		Secrets: []*framework.Secret{
 			b.grafanaCloudKey(),
 		},
-		BackendType: logical.TypeLogical,
-		Invalidate:  b.invalidate,
+		BackendType:    logical.TypeLogical,
+		Invalidate:     b.invalidate,
+		InitializeFunc: b.initialize,
+		PeriodicFunc:   b.periodicFunc,
+		RunningVersion: Version,
 	}
+	b.recoverHandlers()
+
 	return &b
 }
 
@@ -67,21 +130,50 @@ func (b *grafanaCloudBackend) invalidate(ctx context.Context, key string) {
 	if key == "config" {
 		b.reset()
 	}
+
+	if name, ok := roleNameFromStorageKey(key); ok {
+		b.roles.forget(name)
+	}
 }
 
 func (b *grafanaCloudBackend) reset() {
 	b.lock.Lock()
 	defer b.lock.Unlock()
 	b.client = nil
+	b.httpClient = nil
+	b.urlClients = nil
+	b.stacks.clear()
 }
 
-func (b *grafanaCloudBackend) getClient(ctx context.Context, s logical.Storage) (*grafanclient.Client, error) {
+// clientFactory returns a client of the Grafana Cloud API. The client does
+// not hold state of its own, so one is created for each request with the
+// options of the request.
+type clientFactory func(opts ...client.Option) (client.API, error)
+
+// Authentication of a cached client, reported by debug/client.
+const (
+	clientAuthAdminKey         = "admin_key"
+	clientAuthAdminKeyFallback = "admin_key_with_secondary_fallback"
+)
+
+// cachedClient creates the clients of a config until it changes, and
+// describes them for debug/client.
+type cachedClient struct {
+	newClient clientFactory
+	baseURL   string
+	auth      string
+	createdAt time.Time
+	// failover is set when the config has a fallback_url.
+	failover *urlFailoverTransport
+}
+
+func (b *grafanaCloudBackend) getClient(ctx context.Context, s logical.Storage) (client.API, error) {
 	b.lock.RLock()
 	unlockFunc := b.lock.RUnlock
 	defer func() { unlockFunc() }()
 
 	if b.client != nil {
-		return b.client, nil
+		return b.client.newClient(correlationOptions(ctx)...)
 	}
 
 	b.lock.RUnlock()
@@ -94,25 +186,73 @@ func (b *grafanaCloudBackend) getClient(ctx context.Context, s logical.Storage)
 	}
 
 	if config == nil {
-		config = new(grafanaCloudConfig)
+		return nil, NewInvalidConfigurationError("backend not configured", nil)
 	}
 
-	const apiSuffix = "api"
-	baseURL := strings.ToLower(config.URL)
-	if strings.HasSuffix(baseURL, apiSuffix) {
-		baseURL = strings.TrimSuffix(baseURL, apiSuffix)
-	} else if strings.HasSuffix(baseURL, apiSuffix+"/") {
-		baseURL = strings.TrimSuffix(baseURL, apiSuffix+"/")
+	// URLs are normalized on write, this covers configs stored by older versions.
+	baseURL, err := normalizeAPIURL(config.URL)
+	if err != nil {
+		return nil, err
 	}
 
-	b.client, err = grafanclient.New(baseURL, grafanclient.Config{
-		APIKey: config.Key,
-	})
+	httpClient, err := config.httpClient()
 	if err != nil {
 		return nil, err
 	}
 
-	return b.client, nil
+	cached := &cachedClient{baseURL: baseURL, auth: clientAuthAdminKey, createdAt: time.Now()}
+
+	if config.FallbackURL != "" {
+		fallbackURL, err := normalizeBaseURL("fallback_url", config.FallbackURL)
+		if err != nil {
+			return nil, err
+		}
+
+		// Below the admin key fallback, so its retries fail over as well.
+		cached.failover, err = newURLFailoverTransport(httpClient.Transport, baseURL, fallbackURL, b.Logger())
+		if err != nil {
+			return nil, err
+		}
+
+		httpClient.Transport = cached.failover
+	}
+
+	if config.SecondaryKey != "" {
+		cached.auth = clientAuthAdminKeyFallback
+		httpClient.Transport = &adminKeyFallbackTransport{
+			base:      httpClient.Transport,
+			primary:   config.Key,
+			secondary: config.SecondaryKey,
+			logger:    b.Logger(),
+		}
+	}
+
+	cached.newClient = func(opts ...client.Option) (client.API, error) {
+		return client.New(baseURL, config.Key, httpClient, opts...)
+	}
+
+	// Created once to validate the URL, later clients are created alike.
+	if _, err := cached.newClient(); err != nil {
+		return nil, err
+	}
+
+	b.client = cached
+	b.httpClient = httpClient
+
+	return b.client.newClient(correlationOptions(ctx)...)
+}
+
+// getHTTPClient returns the HTTP client used by the Grafana Cloud client,
+// for requests the client does not cover.
+func (b *grafanaCloudBackend) getHTTPClient(ctx context.Context, s logical.Storage) (*http.Client, error) {
+	if _, err := b.getClient(ctx, s); err != nil {
+		return nil, err
+	}
+
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	return b.httpClient, nil
 }
 
 const backendHelp = ``
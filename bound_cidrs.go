@@ -0,0 +1,65 @@
+package secretsengine
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// parseBoundCIDR parses an entry of a role's bound_cidrs, a bare IP address
+// is bound on its own.
+func parseBoundCIDR(raw string) (*net.IPNet, error) {
+	raw = strings.TrimSpace(raw)
+
+	if ip := net.ParseIP(raw); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 8 * net.IPv4len
+		}
+
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+
+	_, cidr, err := net.ParseCIDR(raw)
+	if err != nil {
+		return nil, NewInvalidConfigurationError(fmt.Sprintf("invalid bound_cidrs entry %q, expected a CIDR block or IP address", raw), err)
+	}
+
+	return cidr, nil
+}
+
+// checkBoundCIDRs returns an IssuanceDeniedError unless the request comes from
+// an address within one of the CIDR blocks. Roles without bound_cidrs may be
+// used from anywhere.
+func checkBoundCIDRs(cidrs []string, conn *logical.Connection) error {
+	if len(cidrs) == 0 {
+		return nil
+	}
+
+	if conn == nil || conn.RemoteAddr == "" {
+		return NewIssuanceDeniedError(denyReasonBoundCIDRs, "keys for this role can only be issued to known addresses, the request has no remote address")
+	}
+
+	ip := net.ParseIP(conn.RemoteAddr)
+	if ip == nil {
+		return NewIssuanceDeniedError(denyReasonBoundCIDRs,
+			fmt.Sprintf("keys for this role can only be issued to known addresses, cannot parse remote address %q", conn.RemoteAddr))
+	}
+
+	for _, raw := range cidrs {
+		cidr, err := parseBoundCIDR(raw)
+		if err != nil {
+			return err
+		}
+
+		if cidr.Contains(ip) {
+			return nil
+		}
+	}
+
+	return NewIssuanceDeniedError(denyReasonBoundCIDRs, fmt.Sprintf("keys for this role can only be issued to requests from %s, the request came from %s",
+		strings.Join(cidrs, ", "), conn.RemoteAddr))
+}
@@ -0,0 +1,87 @@
+package secretsengine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client/clienttest"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoundCIDRs(t *testing.T) {
+	from := func(addr string) *logical.Connection {
+		return &logical.Connection{RemoteAddr: addr}
+	}
+
+	t.Run("Check", func(t *testing.T) {
+		cidrs := []string{"10.0.0.0/8", "192.168.1.10", "2001:db8::/32"}
+
+		assert.NoError(t, checkBoundCIDRs(nil, nil))
+		assert.NoError(t, checkBoundCIDRs(cidrs, from("10.1.2.3")))
+		assert.NoError(t, checkBoundCIDRs(cidrs, from("192.168.1.10")))
+		assert.NoError(t, checkBoundCIDRs(cidrs, from("2001:db8::1")))
+
+		assert.EqualError(t, checkBoundCIDRs(cidrs, from("192.168.1.11")),
+			"issuance denied: keys for this role can only be issued to requests from 10.0.0.0/8, 192.168.1.10, 2001:db8::/32, the request came from 192.168.1.11")
+		assert.EqualError(t, checkBoundCIDRs(cidrs, nil),
+			"issuance denied: keys for this role can only be issued to known addresses, the request has no remote address")
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := parseBoundCIDR("10.0.0.0/33")
+		assert.EqualError(t, err, `invalid configuration: invalid bound_cidrs entry "10.0.0.0/33", expected a CIDR block or IP address`)
+	})
+
+	t.Run("Credentials", func(t *testing.T) {
+		server := clienttest.NewServer(organisation, key)
+		defer server.Close()
+
+		b, s := getTestBackend(t)
+
+		require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+			"key":          key,
+			"url":          server.URL + "/api",
+			"organisation": organisation,
+		}))
+
+		_, err := testTokenRoleCreate(t, b, s, "admin", map[string]interface{}{
+			"gc_role":     "Admin",
+			"bound_cidrs": "10.0.0.0/8",
+		})
+		require.NoError(t, err)
+
+		readCreds := func(addr string) *logical.Response {
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Operation:  logical.ReadOperation,
+				Path:       "creds/admin",
+				Storage:    s,
+				Connection: from(addr),
+			})
+			require.NoError(t, err)
+
+			return resp
+		}
+
+		resp := readCreds("10.20.30.40")
+		require.False(t, resp.IsError())
+
+		resp = readCreds("172.16.0.1")
+		require.True(t, resp.IsError())
+		assert.Contains(t, resp.Error().Error(), "the request came from 172.16.0.1")
+		assert.Len(t, server.CloudKeys(), 1)
+	})
+
+	t.Run("Role write rejects invalid entries", func(t *testing.T) {
+		b, s := getTestBackend(t)
+
+		resp, err := testTokenRoleCreate(t, b, s, "admin", map[string]interface{}{
+			"gc_role":     "Admin",
+			"bound_cidrs": "10.0.0.0/8,not-a-cidr",
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+		assert.Contains(t, resp.Error().Error(), `invalid bound_cidrs entry "not-a-cidr"`)
+	})
+}
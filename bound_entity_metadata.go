@@ -0,0 +1,60 @@
+package secretsengine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// validateBoundEntityMetadata checks the entries of a role's bound_entity_metadata.
+func validateBoundEntityMetadata(bound map[string]string) error {
+	for key := range bound {
+		if strings.TrimSpace(key) == "" {
+			return NewInvalidConfigurationError("bound_entity_metadata keys must not be empty", nil)
+		}
+	}
+
+	return nil
+}
+
+// checkBoundEntityMetadata returns an IssuanceDeniedError unless the entity
+// of the request has every key of bound set to the same value in its
+// metadata. Roles without bound_entity_metadata may be used by anyone.
+func checkBoundEntityMetadata(bound map[string]string, sys logical.SystemView, entityID string) error {
+	if len(bound) == 0 {
+		return nil
+	}
+
+	if entityID == "" {
+		return NewIssuanceDeniedError(denyReasonBoundEntityMetadata, "keys for this role can only be issued to identity entities, the request has no entity")
+	}
+
+	entity, err := sys.EntityInfo(entityID)
+	if err != nil {
+		return NewInternalError("error reading the entity of the request", err)
+	}
+
+	var metadata map[string]string
+	if entity != nil {
+		metadata = entity.Metadata
+	}
+
+	var mismatched []string
+
+	for key, value := range bound {
+		if actual, ok := metadata[key]; !ok || actual != value {
+			mismatched = append(mismatched, fmt.Sprintf("%s=%s", key, value))
+		}
+	}
+
+	if len(mismatched) == 0 {
+		return nil
+	}
+
+	sort.Strings(mismatched)
+
+	return NewIssuanceDeniedError(denyReasonBoundEntityMetadata, fmt.Sprintf("keys for this role can only be issued to entities with the metadata %s",
+		strings.Join(mismatched, ", ")))
+}
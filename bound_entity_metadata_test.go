@@ -0,0 +1,89 @@
+package secretsengine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client/clienttest"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoundEntityMetadata(t *testing.T) {
+	bound := map[string]string{"team": "observability", "env": "prod"}
+
+	t.Run("Check", func(t *testing.T) {
+		sys := logical.TestSystemView()
+
+		assert.NoError(t, checkBoundEntityMetadata(nil, sys, ""))
+
+		err := checkBoundEntityMetadata(bound, sys, "")
+		assert.EqualError(t, err, "issuance denied: keys for this role can only be issued to identity entities, the request has no entity")
+
+		sys.EntityVal = &logical.Entity{ID: "entity", Metadata: map[string]string{"team": "observability", "env": "prod", "owner": "sre"}}
+		assert.NoError(t, checkBoundEntityMetadata(bound, sys, "entity"))
+
+		sys.EntityVal.Metadata = map[string]string{"team": "platform"}
+		err = checkBoundEntityMetadata(bound, sys, "entity")
+		assert.EqualError(t, err, "issuance denied: keys for this role can only be issued to entities with the metadata env=prod, team=observability")
+	})
+
+	t.Run("Issue", func(t *testing.T) {
+		server := clienttest.NewServer(organisation, key)
+		defer server.Close()
+
+		b, s := getTestBackend(t)
+		sys := b.System().(*logical.StaticSystemView)
+
+		require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+			"key":          key,
+			"url":          server.URL + "/api",
+			"organisation": organisation,
+		}))
+
+		resp, err := testTokenRoleCreate(t, b, s, "observability", map[string]interface{}{
+			"gc_role":               "Viewer",
+			"bound_entity_metadata": "team=observability",
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+		require.Equal(t, map[string]string{"team": "observability"}, resp.Data["bound_entity_metadata"])
+
+		readCreds := func() *logical.Response {
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.ReadOperation,
+				Path:      "creds/observability",
+				Storage:   s,
+				EntityID:  "entity",
+			})
+			require.NoError(t, err)
+
+			return resp
+		}
+
+		sys.EntityVal = &logical.Entity{ID: "entity", Metadata: map[string]string{"team": "platform"}}
+
+		resp = readCreds()
+		require.EqualError(t, resp.Error(), "issuance denied: keys for this role can only be issued to entities with the metadata team=observability")
+		require.Equal(t, map[string]interface{}{"deny_reason": "bound_entity_metadata"}, resp.Data["data"])
+		require.Empty(t, server.CloudKeys())
+
+		sys.EntityVal.Metadata["team"] = "observability"
+
+		resp = readCreds()
+		require.False(t, resp.IsError())
+		require.Len(t, server.CloudKeys(), 1)
+	})
+
+	t.Run("Role write rejects empty keys", func(t *testing.T) {
+		b, s := getTestBackend(t)
+
+		resp, err := testTokenRoleCreate(t, b, s, "observability", map[string]interface{}{
+			"gc_role":               "Viewer",
+			"bound_entity_metadata": map[string]interface{}{"": "observability"},
+		})
+		require.NoError(t, err)
+		require.EqualError(t, resp.Error(), "invalid configuration: bound_entity_metadata keys must not be empty")
+	})
+}
@@ -0,0 +1,103 @@
+// Package client holds what the plugin needs on top of the Grafana API
+// client to talk to Grafana Cloud: the subset of the client it uses as
+// interfaces, so it can be replaced in tests and reused by related tooling,
+// and clients implementing them which return the errors of the Grafana API
+// client as typed APIErrors.
+package client
+
+import (
+	"net/http"
+	"strings"
+
+	grafanclient "github.com/grafana/grafana-api-golang-client"
+)
+
+// API is the part of the Grafana Cloud API used by the plugin, served at
+// https://grafana.com/api. The clients returned by New implement it.
+type API interface {
+	Stacks() (grafanclient.StackItems, error)
+	StackBySlug(slug string) (grafanclient.Stack, error)
+	ListCloudAPIKeys(org string) (*grafanclient.ListCloudAPIKeysOutput, error)
+	CreateCloudAPIKey(org string, input *grafanclient.CreateCloudAPIKeyInput) (*grafanclient.CloudAPIKey, error)
+	DeleteCloudAPIKey(org string, keyName string) error
+}
+
+var _ API = (*apiClient)(nil)
+
+// CorrelationIDHeader is the header the correlation ID of the Vault request
+// a call is made for is sent in, see WithCorrelationID.
+const CorrelationIDHeader = "X-Correlation-ID"
+
+// Option customises the clients returned by New.
+type Option func(*grafanclient.Config)
+
+// WithCorrelationID sends id in the CorrelationIDHeader of every request, so
+// the requests can be matched with Grafana Cloud server-side logs.
+func WithCorrelationID(id string) Option {
+	return func(config *grafanclient.Config) {
+		if config.HTTPHeaders == nil {
+			config.HTTPHeaders = map[string]string{}
+		}
+
+		config.HTTPHeaders[CorrelationIDHeader] = id
+	}
+}
+
+// New returns a client of the Grafana Cloud API at baseURL, such as
+// https://grafana.com, authenticated with the admin key. A trailing /api is
+// removed from baseURL as the client adds it to every request. A nil
+// httpClient uses the default client of the Grafana API client. Errors of
+// API responses are returned as APIErrors.
+func New(baseURL, key string, httpClient *http.Client, opts ...Option) (API, error) {
+	baseURL = strings.TrimRight(baseURL, "/")
+	baseURL = strings.TrimSuffix(baseURL, "/api")
+
+	config := grafanclient.Config{
+		APIKey: key,
+		Client: httpClient,
+	}
+
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	c, err := grafanclient.New(baseURL, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &apiClient{c: c}, nil
+}
+
+// apiClient is the Grafana API client, with its errors converted by NewAPIError.
+type apiClient struct {
+	c *grafanclient.Client
+}
+
+func (a *apiClient) Stacks() (grafanclient.StackItems, error) {
+	stacks, err := a.c.Stacks()
+
+	return stacks, NewAPIError(err)
+}
+
+func (a *apiClient) StackBySlug(slug string) (grafanclient.Stack, error) {
+	stack, err := a.c.StackBySlug(slug)
+
+	return stack, NewAPIError(err)
+}
+
+func (a *apiClient) ListCloudAPIKeys(org string) (*grafanclient.ListCloudAPIKeysOutput, error) {
+	keys, err := a.c.ListCloudAPIKeys(org)
+
+	return keys, NewAPIError(err)
+}
+
+func (a *apiClient) CreateCloudAPIKey(org string, input *grafanclient.CreateCloudAPIKeyInput) (*grafanclient.CloudAPIKey, error) {
+	key, err := a.c.CreateCloudAPIKey(org, input)
+
+	return key, NewAPIError(err)
+}
+
+func (a *apiClient) DeleteCloudAPIKey(org string, keyName string) error {
+	return NewAPIError(a.c.DeleteCloudAPIKey(org, keyName))
+}
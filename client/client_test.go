@@ -0,0 +1,54 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client"
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client/clienttest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	server := clienttest.NewServer("my-org", "admin-key")
+	defer server.Close()
+	server.AddStack("my-stack")
+
+	for _, baseURL := range []string{server.URL, server.URL + "/", server.URL + "/api", server.URL + "/api/"} {
+		t.Run(baseURL, func(t *testing.T) {
+			c, err := client.New(baseURL, "admin-key", nil)
+			require.NoError(t, err)
+
+			stack, err := c.StackBySlug("my-stack")
+			require.NoError(t, err)
+			require.Equal(t, "my-stack", stack.Slug)
+		})
+	}
+
+	t.Run("Invalid URL", func(t *testing.T) {
+		c, err := client.New("://grafana.com", "admin-key", nil)
+		require.Error(t, err)
+		require.Nil(t, c)
+	})
+
+	t.Run("Typed errors", func(t *testing.T) {
+		c, err := client.New(server.URL, "admin-key", nil)
+		require.NoError(t, err)
+
+		_, err = c.StackBySlug("missing")
+		require.ErrorIs(t, err, client.ErrNotFound)
+
+		var apiErr *client.APIError
+		require.ErrorAs(t, err, &apiErr)
+	})
+
+	t.Run("Correlation ID", func(t *testing.T) {
+		c, err := client.New(server.URL, "admin-key", nil, client.WithCorrelationID("request-1"))
+		require.NoError(t, err)
+
+		_, err = c.StackBySlug("my-stack")
+		require.NoError(t, err)
+
+		requests := server.Requests()
+		require.Equal(t, "request-1", requests[len(requests)-1].CorrelationID)
+	})
+}
@@ -0,0 +1,356 @@
+// Package clienttest provides an in-memory fake of the Grafana Cloud API
+// endpoints used by the plugin, for hermetic tests of the issue and revoke
+// paths.
+package clienttest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client"
+	grafanclient "github.com/grafana/grafana-api-golang-client"
+)
+
+// Request is a request received by the server.
+type Request struct {
+	Method string
+	Path   string
+	// CorrelationID is the value of the client.CorrelationIDHeader.
+	CorrelationID string
+}
+
+type injectedError struct {
+	status  int
+	message string
+	// times is how many more requests fail, zero for all of them.
+	times int
+}
+
+// Server is a fake Grafana Cloud API. Cloud API endpoints are served under
+// /api and require the admin key as bearer token, the health endpoint of
+// stack APIs is served under /stacks/<slug>.
+type Server struct {
+	*httptest.Server
+
+	// AdminKey is the key accepted by the Cloud API endpoints.
+	AdminKey string
+	// Organisation is the slug of the only organisation served.
+	Organisation string
+
+	mu         sync.Mutex
+	nextID     int64
+	cloudKeys  map[string]*grafanclient.CloudAPIKey
+	stacks     map[string]*grafanclient.Stack
+	errors     map[string]injectedError
+	misleading map[string]injectedError
+	requests   []Request
+}
+
+// NewServer starts a fake serving the given organisation, which accepts adminKey.
+func NewServer(organisation, adminKey string) *Server {
+	s := &Server{
+		AdminKey:     adminKey,
+		Organisation: organisation,
+		cloudKeys:    map[string]*grafanclient.CloudAPIKey{},
+		stacks:       map[string]*grafanclient.Stack{},
+		errors:       map[string]injectedError{},
+		misleading:   map[string]injectedError{},
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+
+	return s
+}
+
+// AddStack adds a stack with hosted Prometheus, Loki, Tempo, Alertmanager and
+// Graphite instances and returns it.
+func (s *Server) AddStack(slug string) *grafanclient.Stack {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	id := int(s.nextID * 10)
+
+	stack := &grafanclient.Stack{
+		ID:                    s.nextID,
+		OrgSlug:               s.Organisation,
+		Name:                  slug,
+		Slug:                  slug,
+		URL:                   s.URL + "/stacks/" + slug,
+		Status:                "active",
+		RegionSlug:            "eu",
+		HmInstancePromID:      id + 1,
+		HmInstancePromURL:     "https://prometheus-" + slug + ".grafana.net",
+		HlInstanceID:          id + 2,
+		HlInstanceURL:         "https://logs-" + slug + ".grafana.net",
+		HtInstanceID:          id + 3,
+		HtInstanceURL:         "https://tempo-" + slug + ".grafana.net",
+		AmInstanceID:          id + 4,
+		AmInstanceURL:         "https://alertmanager-" + slug + ".grafana.net",
+		HmInstanceGraphiteID:  id + 5,
+		HmInstanceGraphiteURL: "https://graphite-" + slug + ".grafana.net",
+	}
+
+	s.stacks[slug] = stack
+
+	return stack
+}
+
+// SetStackStatus changes the status of a stack, e.g. to "paused".
+func (s *Server) SetStackStatus(slug, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stacks[slug].Status = status
+}
+
+// InjectError makes every request with the method and path fail with the
+// given status and message, until ClearErrors is called.
+func (s *Server) InjectError(method, path string, status int, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.errors[method+" "+path] = injectedError{status: status, message: message}
+}
+
+// InjectErrorTimes makes the next times requests with the method and path
+// fail with the given status and message, later ones are handled as usual.
+func (s *Server) InjectErrorTimes(method, path string, status int, message string, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.errors[method+" "+path] = injectedError{status: status, message: message, times: times}
+}
+
+// InjectMisleadingError makes every request with the method and path be
+// handled as usual but respond with the given status and message, like a
+// deletion that succeeds but reports a failure, until ClearErrors is called.
+func (s *Server) InjectMisleadingError(method, path string, status int, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.misleading[method+" "+path] = injectedError{status: status, message: message}
+}
+
+// ClearErrors removes all injected errors.
+func (s *Server) ClearErrors() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.errors = map[string]injectedError{}
+	s.misleading = map[string]injectedError{}
+}
+
+// CloudKeys returns the names of the Cloud API keys in the organisation.
+func (s *Server) CloudKeys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.cloudKeys))
+	for name := range s.cloudKeys {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// CloudKey returns the named Cloud API key, or nil if it does not exist.
+func (s *Server) CloudKey(name string) *grafanclient.CloudAPIKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.cloudKeys[name]
+}
+
+// Requests returns the requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, CorrelationID: r.Header.Get(client.CorrelationIDHeader)})
+
+	if injected, ok := s.errors[r.Method+" "+r.URL.Path]; ok {
+		switch {
+		case injected.times == 1:
+			delete(s.errors, r.Method+" "+r.URL.Path)
+		case injected.times > 1:
+			injected.times--
+			s.errors[r.Method+" "+r.URL.Path] = injected
+		}
+
+		writeError(w, injected.status, injected.message)
+
+		return
+	}
+
+	if misleading, ok := s.misleading[r.Method+" "+r.URL.Path]; ok {
+		s.serve(httptest.NewRecorder(), r)
+		writeError(w, misleading.status, misleading.message)
+
+		return
+	}
+
+	s.serve(w, r)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/stacks/") {
+		s.handleStack(w, r)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	if r.Header.Get("Authorization") != "Bearer "+s.AdminKey {
+		writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	switch {
+	case len(parts) == 4 && parts[0] == "api" && parts[1] == "orgs" && parts[3] == "api-keys":
+		if !s.checkOrganisation(w, parts[2]) {
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			s.listCloudKeys(w)
+		case http.MethodPost:
+			s.createCloudKey(w, r)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	case len(parts) == 5 && parts[0] == "api" && parts[1] == "orgs" && parts[3] == "api-keys" && r.Method == http.MethodDelete:
+		if !s.checkOrganisation(w, parts[2]) {
+			return
+		}
+
+		s.deleteCloudKey(w, parts[4])
+	case len(parts) == 2 && parts[0] == "api" && parts[1] == "instances" && r.Method == http.MethodGet:
+		s.listStacks(w)
+	case len(parts) == 3 && parts[0] == "api" && parts[1] == "instances" && r.Method == http.MethodGet:
+		s.getStack(w, parts[2])
+	default:
+		writeError(w, http.StatusNotFound, "Not found")
+	}
+}
+
+func (s *Server) checkOrganisation(w http.ResponseWriter, org string) bool {
+	if org != s.Organisation {
+		writeError(w, http.StatusNotFound, "Organization not found")
+		return false
+	}
+
+	return true
+}
+
+func (s *Server) listCloudKeys(w http.ResponseWriter) {
+	out := grafanclient.ListCloudAPIKeysOutput{}
+	for _, key := range s.cloudKeys {
+		listed := *key
+		listed.Token = ""
+		out.Items = append(out.Items, &listed)
+	}
+
+	sort.Slice(out.Items, func(i, j int) bool { return out.Items[i].Name < out.Items[j].Name })
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) createCloudKey(w http.ResponseWriter, r *http.Request) {
+	var input grafanclient.CreateCloudAPIKeyInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if _, ok := s.cloudKeys[input.Name]; ok {
+		writeError(w, http.StatusConflict, "API key with this name already exists")
+		return
+	}
+
+	s.nextID++
+	key := &grafanclient.CloudAPIKey{
+		ID:    int(s.nextID),
+		Name:  input.Name,
+		Role:  input.Role,
+		Token: fmt.Sprintf("glc_%s_%d", input.Name, s.nextID),
+	}
+	s.cloudKeys[input.Name] = key
+
+	writeJSON(w, http.StatusOK, key)
+}
+
+func (s *Server) deleteCloudKey(w http.ResponseWriter, name string) {
+	if _, ok := s.cloudKeys[name]; !ok {
+		writeError(w, http.StatusNotFound, "API key not found")
+		return
+	}
+
+	delete(s.cloudKeys, name)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listStacks(w http.ResponseWriter) {
+	out := grafanclient.StackItems{}
+	for _, stack := range s.stacks {
+		out.Items = append(out.Items, stack)
+	}
+
+	sort.Slice(out.Items, func(i, j int) bool { return out.Items[i].Slug < out.Items[j].Slug })
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) getStack(w http.ResponseWriter, slug string) {
+	stack, ok := s.stacks[slug]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Stack not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, stack)
+}
+
+// handleStack serves the API of a stack's Grafana instance.
+func (s *Server) handleStack(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	if _, ok := s.stacks[parts[1]]; !ok {
+		writeError(w, http.StatusNotFound, "Stack not found")
+		return
+	}
+
+	// Grafana serves its health endpoint without authentication.
+	if len(parts) == 4 && parts[2] == "api" && parts[3] == "health" && r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, map[string]string{"database": "ok"})
+		return
+	}
+
+	writeError(w, http.StatusNotFound, "Not found")
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
@@ -1,5 +1,37 @@
 package client
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Kinds of APIError, which callers can branch on with errors.Is, e.g.
+// errors.Is(err, client.ErrNotFound). An APIError is of at most one kind,
+// responses with other statuses are of none.
+var (
+	// ErrNotFound is the kind of 404 responses.
+	ErrNotFound = errors.New("not found")
+	// ErrUnauthorized is the kind of 401 and 403 responses, the key was
+	// rejected or lacks the permission.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrConflict is the kind of 409 responses.
+	ErrConflict = errors.New("conflict")
+	// ErrRateLimited is the kind of 429 responses.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrUnavailable is the kind of 500, 502, 503 and 504 responses.
+	ErrUnavailable = errors.New("unavailable")
+)
+
+// apiErrorPattern matches the errors returned by the Grafana API client for non 2xx responses.
+//
+//nolint:gochecknoglobals // compiled once for parsing API errors.
+var apiErrorPattern = regexp.MustCompile(`(?s)^status: (\d{3}), body: (.*)$`)
+
 type ClientError struct {
 	Msg string
 	Err error
@@ -19,3 +51,86 @@ func (e *ClientError) Error() string {
 func (e *ClientError) Unwrap() error {
 	return e.Err
 }
+
+// APIError is an error response returned by the Grafana Cloud API.
+type APIError struct {
+	StatusCode int
+	Message    string
+	Err        error
+}
+
+// NewAPIError converts an error returned by the Grafana API client into an
+// APIError carrying the HTTP status code and the message from the response
+// body. Errors which did not come from an API response, such as connection
+// failures, are returned unchanged. The clients returned by New already
+// convert their errors.
+func NewAPIError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	matches := apiErrorPattern.FindStringSubmatch(err.Error())
+	if matches == nil {
+		return err
+	}
+
+	// The pattern only matches three digits.
+	statusCode, _ := strconv.Atoi(matches[1])
+
+	return &APIError{
+		StatusCode: statusCode,
+		Message:    apiErrorMessage(matches[2]),
+		Err:        err,
+	}
+}
+
+// apiErrorMessage extracts the message from a JSON error body, falling back to the raw body.
+func apiErrorMessage(body string) string {
+	var parsed struct {
+		Message string `json:"message"`
+	}
+
+	if err := json.Unmarshal([]byte(body), &parsed); err == nil && parsed.Message != "" {
+		return parsed.Message
+	}
+
+	return strings.TrimSpace(body)
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return strconv.Itoa(e.StatusCode)
+	}
+
+	return fmt.Sprintf("%d: %s", e.StatusCode, e.Message)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Kind returns the kind of the error, one of the Err* variables of this
+// package, or nil.
+func (e *APIError) Kind() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case http.StatusConflict:
+		return ErrConflict
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return ErrUnavailable
+	default:
+		return nil
+	}
+}
+
+// Is reports whether target is the kind of the error, see Kind.
+func (e *APIError) Is(target error) bool {
+	kind := e.Kind()
+
+	return kind != nil && kind == target
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAPIError(t *testing.T) {
+	t.Run("JSON body", func(t *testing.T) {
+		err := NewAPIError(errors.New(`status: 403, body: {"code":"Forbidden","message":"API key does not have Admin role"}`))
+
+		var apiErr *APIError
+		require.ErrorAs(t, err, &apiErr)
+		require.Equal(t, 403, apiErr.StatusCode)
+		require.EqualError(t, err, "403: API key does not have Admin role")
+		require.ErrorIs(t, err, ErrUnauthorized)
+	})
+
+	t.Run("Plain body", func(t *testing.T) {
+		err := NewAPIError(errors.New("status: 502, body: bad gateway\n"))
+
+		require.EqualError(t, err, "502: bad gateway")
+	})
+
+	t.Run("Not an API error", func(t *testing.T) {
+		original := errors.New("dial tcp: connection refused")
+
+		require.Equal(t, original, NewAPIError(original))
+		require.NoError(t, NewAPIError(nil))
+	})
+}
+
+func TestAPIErrorKind(t *testing.T) {
+	kinds := []error{ErrNotFound, ErrUnauthorized, ErrConflict, ErrRateLimited, ErrUnavailable}
+
+	for status, want := range map[int]error{
+		400: nil,
+		401: ErrUnauthorized,
+		403: ErrUnauthorized,
+		404: ErrNotFound,
+		409: ErrConflict,
+		429: ErrRateLimited,
+		500: ErrUnavailable,
+		502: ErrUnavailable,
+		503: ErrUnavailable,
+		504: ErrUnavailable,
+		501: nil,
+	} {
+		err := fmt.Errorf("wrapped: %w", NewAPIError(fmt.Errorf("status: %d, body: {}", status)))
+
+		for _, kind := range kinds {
+			require.Equal(t, kind == want, errors.Is(err, kind), "status %d, kind %q", status, kind)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"os"
 
 	grafanacloud "github.com/form3tech-oss/vault-plugin-secrets-grafanacloud"
@@ -12,17 +13,30 @@ import (
 
 func main() {
 	logger := hclog.New(&hclog.LoggerOptions{})
+
+	if len(os.Args) > 1 && os.Args[1] == smokeTestCommand {
+		os.Exit(runSmokeTest(os.Args[2:], os.Stdout))
+	}
+
 	apiClientMeta := &api.PluginAPIClientMeta{}
 	flags := apiClientMeta.FlagSet()
+	printVersion := flags.Bool("version", false, "print the plugin version and exit")
 	if err := flags.Parse(os.Args[1:]); err != nil {
 		logger.Error("plugin shutting down", "error", err)
 		os.Exit(1)
 	}
 
+	if *printVersion {
+		fmt.Println(grafanacloud.Version)
+		return
+	}
+
 	tlsConfig := apiClientMeta.GetTLSConfig()
 	tlsProviderFunc := api.VaultPluginTLSProvider(tlsConfig)
 
-	err := plugin.Serve(&plugin.ServeOpts{
+	// Multiplexing lets Vault serve every mount of the plugin from a single
+	// process, older Vault versions fall back to one process per mount.
+	err := plugin.ServeMultiplex(&plugin.ServeOpts{
 		BackendFactoryFunc: grafanacloud.Factory,
 		TLSProviderFunc:    tlsProviderFunc,
 	})
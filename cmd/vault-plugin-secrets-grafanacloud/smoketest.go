@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client"
+	grafanclient "github.com/grafana/grafana-api-golang-client"
+)
+
+const (
+	smokeTestCommand = "smoke-test"
+	// smokeTestKeyEnv is read for the admin key when -key is not given,
+	// so the key does not have to appear in the process arguments.
+	smokeTestKeyEnv  = "GRAFANA_CLOUD_API_KEY"
+	smokeTestKeyName = "vault-plugin-smoke-test"
+)
+
+// runSmokeTest checks that an admin key can manage the API keys of an
+// organisation, as the plugin will once configured with them. It returns
+// the process exit code.
+func runSmokeTest(args []string, out io.Writer) int {
+	flags := flag.NewFlagSet(smokeTestCommand, flag.ContinueOnError)
+	flags.SetOutput(out)
+
+	apiURL := flags.String("url", "https://grafana.com/api", "the Grafana Cloud API URL")
+	organisation := flags.String("organisation", "", "the organisation slug")
+	key := flags.String("key", "", "the admin API key, defaults to $"+smokeTestKeyEnv)
+	issue := flags.Bool("issue", false, "also create and delete a Viewer key, checking the key has the Admin role")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *key == "" {
+		*key = os.Getenv(smokeTestKeyEnv)
+	}
+
+	if *organisation == "" || *key == "" {
+		fmt.Fprintf(out, "%s requires -organisation and -key (or $%s)\n", smokeTestCommand, smokeTestKeyEnv)
+		return 2
+	}
+
+	if err := smokeTest(*apiURL, *organisation, *key, *issue, out); err != nil {
+		fmt.Fprintf(out, "FAIL: %s\n", err)
+		return 1
+	}
+
+	fmt.Fprintln(out, "OK")
+
+	return 0
+}
+
+func smokeTest(apiURL, organisation, key string, issue bool, out io.Writer) error {
+	// The client of the plugin, so that errors are reported alike.
+	c, err := client.New(apiURL, key, nil)
+	if err != nil {
+		return fmt.Errorf("creating client: %w", err)
+	}
+
+	keys, err := c.ListCloudAPIKeys(organisation)
+	if err != nil {
+		return fmt.Errorf("listing API keys of organisation %s: %w", organisation, err)
+	}
+
+	fmt.Fprintf(out, "listed %d API keys in organisation %s\n", len(keys.Items), organisation)
+
+	if !issue {
+		return nil
+	}
+
+	created, err := c.CreateCloudAPIKey(organisation, &grafanclient.CreateCloudAPIKeyInput{
+		Name: smokeTestKeyName,
+		Role: "Viewer",
+	})
+	if err != nil {
+		return fmt.Errorf("creating API key: %w", err)
+	}
+
+	fmt.Fprintf(out, "created API key %s\n", created.Name)
+
+	if err := c.DeleteCloudAPIKey(organisation, created.Name); err != nil {
+		return fmt.Errorf("deleting API key %s, it must be removed manually: %w", created.Name, err)
+	}
+
+	fmt.Fprintf(out, "deleted API key %s\n", created.Name)
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client/clienttest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSmokeTest(t *testing.T) {
+	server := clienttest.NewServer("testorg", "admin-key")
+	defer server.Close()
+
+	run := func(args ...string) (int, string) {
+		var out bytes.Buffer
+		code := runSmokeTest(args, &out)
+
+		return code, out.String()
+	}
+
+	t.Run("Valid key", func(t *testing.T) {
+		code, out := run("-url", server.URL+"/api/", "-organisation", "testorg", "-key", "admin-key", "-issue")
+
+		require.Equal(t, 0, code, out)
+		require.Equal(t, "listed 0 API keys in organisation testorg\n"+
+			"created API key vault-plugin-smoke-test\n"+
+			"deleted API key vault-plugin-smoke-test\n"+
+			"OK\n", out)
+		require.Empty(t, server.CloudKeys())
+	})
+
+	t.Run("Key from environment", func(t *testing.T) {
+		t.Setenv(smokeTestKeyEnv, "admin-key")
+
+		code, out := run("-url", server.URL, "-organisation", "testorg")
+
+		require.Equal(t, 0, code, out)
+	})
+
+	t.Run("Wrong organisation", func(t *testing.T) {
+		code, out := run("-url", server.URL, "-organisation", "otherorg", "-key", "admin-key")
+
+		require.Equal(t, 1, code)
+		require.Equal(t, "FAIL: listing API keys of organisation otherorg: 404: Organization not found\n", out)
+	})
+
+	t.Run("Key cannot issue", func(t *testing.T) {
+		server.InjectError(http.MethodPost, "/api/orgs/testorg/api-keys", http.StatusForbidden, "Forbidden")
+		defer server.ClearErrors()
+
+		code, out := run("-url", server.URL, "-organisation", "testorg", "-key", "admin-key", "-issue")
+
+		require.Equal(t, 1, code)
+		require.Contains(t, out, "FAIL: creating API key: 403: Forbidden\n")
+	})
+
+	t.Run("Missing key", func(t *testing.T) {
+		t.Setenv(smokeTestKeyEnv, "")
+
+		code, _ := run("-organisation", "testorg")
+
+		require.Equal(t, 2, code)
+	})
+}
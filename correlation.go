@@ -0,0 +1,80 @@
+package secretsengine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client"
+	"github.com/google/uuid"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+type correlationIDKey struct{}
+
+// withCorrelationID returns ctx carrying the correlation ID of the request.
+func withCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// correlationID returns the correlation ID carried by ctx, if any.
+func correlationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+
+	return id
+}
+
+// correlationOptions returns the client options sending the correlation ID
+// carried by ctx with every call.
+func correlationOptions(ctx context.Context) []client.Option {
+	if id := correlationID(ctx); id != "" {
+		return []client.Option{client.WithCorrelationID(id)}
+	}
+
+	return nil
+}
+
+// HandleRequest handles the request with a correlation ID, the ID of the
+// Vault request, so the calls made to Grafana Cloud for it can be matched
+// with the Vault audit log, and with Grafana Cloud server-side logs through
+// the client.CorrelationIDHeader. One is generated for requests without an
+// ID. Internal errors include it, and failed requests are logged with it at
+// debug level: failed revocations, which Vault retries, are already logged
+// at a limited rate by logRevocationError.
+func (b *grafanaCloudBackend) HandleRequest(ctx context.Context, req *logical.Request) (*logical.Response, error) {
+	id := req.ID
+	if id == "" {
+		id = uuid.New().String()
+	}
+
+	resp, err := b.Backend.HandleRequest(withCorrelationID(ctx, id), req)
+	if err != nil {
+		b.Logger().Debug("request failed", "path", req.Path, "operation", req.Operation, "correlation_id", id, "error", err)
+
+		return resp, correlatedError(err, id)
+	}
+
+	if resp.IsError() {
+		b.Logger().Debug("request refused", "path", req.Path, "operation", req.Operation, "correlation_id", id, "error", resp.Error())
+	}
+
+	return resp, nil
+}
+
+// correlatedError appends the correlation ID to internal errors and to the
+// errors returned when Grafana Cloud is unavailable, keeping their status.
+// Other errors, such as logical.ErrUnsupportedPath, are compared by Vault
+// and returned as is.
+func correlatedError(err error, id string) error {
+	var coded logical.HTTPCodedError
+	if errors.As(err, &coded) {
+		return logical.CodedError(coded.Code(), fmt.Sprintf("%s (correlation_id: %s)", err, id))
+	}
+
+	var internal *InternalError
+	if errors.As(err, &internal) {
+		return fmt.Errorf("%w (correlation_id: %s)", err, id)
+	}
+
+	return err
+}
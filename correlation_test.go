@@ -0,0 +1,91 @@
+package secretsengine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client/clienttest"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCorrelationID(t *testing.T) {
+	server := clienttest.NewServer(organisation, key)
+	defer server.Close()
+
+	b, s := getTestBackend(t)
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          server.URL + "/api",
+		"organisation": organisation,
+	}))
+
+	_, err := testTokenRoleCreate(t, b, s, "dashboards", map[string]interface{}{
+		"gc_role": "Viewer",
+	})
+	require.NoError(t, err)
+
+	// correlationIDs returns the correlation IDs of the requests Grafana
+	// Cloud received from the nth on.
+	correlationIDs := func(n int) map[string]bool {
+		ids := map[string]bool{}
+		for _, request := range server.Requests()[n:] {
+			ids[request.CorrelationID] = true
+		}
+
+		return ids
+	}
+
+	before := len(server.Requests())
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		ID:        "issue-request",
+		Operation: logical.ReadOperation,
+		Path:      "creds/dashboards",
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	require.False(t, resp.IsError())
+	assert.Equal(t, map[string]bool{"issue-request": true}, correlationIDs(before))
+
+	t.Run("Revocation", func(t *testing.T) {
+		before := len(server.Requests())
+
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			ID:        "revoke-request",
+			Operation: logical.RevokeOperation,
+			Storage:   s,
+			Secret:    resp.Secret,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]bool{"revoke-request": true}, correlationIDs(before))
+	})
+
+	t.Run("Generated", func(t *testing.T) {
+		before := len(server.Requests())
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/dashboards",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+
+		ids := correlationIDs(before)
+		require.Len(t, ids, 1)
+		assert.NotContains(t, ids, "")
+	})
+
+	t.Run("Vault errors are kept", func(t *testing.T) {
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			ID:        "unknown-request",
+			Operation: logical.ReadOperation,
+			Path:      "unknown",
+			Storage:   s,
+		})
+		require.Equal(t, logical.ErrUnsupportedPath, err)
+	})
+}
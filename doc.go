@@ -0,0 +1,13 @@
+// Package secretsengine is a Vault secrets engine issuing Grafana Cloud API
+// keys. Factory is the entrypoint used by cmd/vault-plugin-secrets-grafanacloud,
+// and can be served from another plugin binary in the same way:
+//
+//	plugin.ServeMultiplex(&plugin.ServeOpts{
+//		BackendFactoryFunc: secretsengine.Factory,
+//		TLSProviderFunc:    tlsProviderFunc,
+//	})
+//
+// Config and RoleEntry are the values stored at the config and roles/<name>
+// paths. The Grafana Cloud client used by the backend lives in the client
+// package and can be used on its own by related tooling.
+package secretsengine
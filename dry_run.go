@@ -0,0 +1,76 @@
+package secretsengine
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// dryRunCreds runs the checks createKey makes before issuing a key for the
+// role and returns what would be issued, without creating anything upstream
+// or counting the issuance.
+func (b *grafanaCloudBackend) dryRunCreds(ctx context.Context, req *logical.Request, roleName string,
+	role *RoleEntry,
+) (*logical.Response, error) {
+	now := time.Now()
+
+	if err := checkIssuanceWindows(role.IssuanceWindows, now); err != nil {
+		return errorResponse(err)
+	}
+
+	config, err := getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, NewInternalError("error reading secrets engine configuration", err)
+	}
+
+	if config == nil {
+		return errorResponse(NewInvalidConfigurationError("backend not configured", nil))
+	}
+
+	if err := checkMaintenanceMode(config); err != nil {
+		return errorResponse(err)
+	}
+
+	if _, err := b.getClient(ctx, req.Storage); err != nil {
+		return errorResponse(err)
+	}
+
+	if role.URL != "" {
+		if _, err := b.clientFor(ctx, req.Storage, config, role.URL); err != nil {
+			return errorResponse(err)
+		}
+	}
+
+	if err := checkOutstandingKeys(ctx, req.Storage, config); err != nil {
+		return errorResponse(err)
+	}
+
+	warning, err := b.issuanceRate.check(roleName, now, config)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	ttl, maxTTL := role.effectiveTTLs(b.System())
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"dry_run":   true,
+			"key_name":  keyName(config, roleName),
+			"gc_role":   role.GrafanaCloudRole,
+			"ttl":       int64(ttl.Seconds()),
+			"max_ttl":   int64(maxTTL.Seconds()),
+			"renewable": !role.NonRenewable,
+		},
+	}
+
+	if warning != "" {
+		resp.AddWarning(warning)
+	}
+
+	for _, warning := range role.ttlClampWarnings(b.System().MaxLeaseTTL()) {
+		resp.AddWarning(warning)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,88 @@
+package secretsengine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client/clienttest"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCredsDryRun(t *testing.T) {
+	server := clienttest.NewServer(organisation, key)
+	defer server.Close()
+
+	b, s := getTestBackend(t)
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":                  key,
+		"url":                  server.URL + "/api",
+		"organisation":         organisation,
+		"token_prefix":         "vault",
+		"max_outstanding_keys": 1,
+		"issuance_rate_warn":   1,
+	}))
+
+	_, err := testTokenRoleCreate(t, b, s, "cloud-role", map[string]interface{}{
+		"gc_role": "Viewer",
+		"ttl":     "1h",
+		"max_ttl": "2h",
+	})
+	require.NoError(t, err)
+
+	dryRun := func(t *testing.T, role string) *logical.Response {
+		t.Helper()
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "creds/" + role,
+			Data:      map[string]interface{}{"dry_run": true},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+
+		return resp
+	}
+
+	t.Run("Cloud API key", func(t *testing.T) {
+		resp := dryRun(t, "cloud-role")
+		require.False(t, resp.IsError())
+		require.Nil(t, resp.Secret)
+
+		require.Equal(t, true, resp.Data["dry_run"])
+		require.Regexp(t, `^vault_cloud-role_[0-9a-f-]{36}$`, resp.Data["key_name"])
+		require.Equal(t, "Viewer", resp.Data["gc_role"])
+		require.Equal(t, int64(3600), resp.Data["ttl"])
+		require.Equal(t, int64(7200), resp.Data["max_ttl"])
+		require.NotContains(t, resp.Data, "token")
+
+		require.Empty(t, server.CloudKeys())
+
+		issued, err := listIssuanceRecords(context.Background(), s)
+		require.NoError(t, err)
+		require.Empty(t, issued)
+	})
+
+	t.Run("Dry runs are not counted", func(t *testing.T) {
+		resp := dryRun(t, "cloud-role")
+		require.False(t, resp.IsError())
+		require.Empty(t, resp.Warnings, "issuance_rate_warn is not exceeded by dry runs")
+	})
+
+	t.Run("Denied by max_outstanding_keys", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/cloud-role",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+
+		resp = dryRun(t, "cloud-role")
+		require.EqualError(t, resp.Error(), "issuance denied: 1 keys are outstanding, the limit set by max_outstanding_keys is 1")
+		require.Equal(t, map[string]interface{}{"deny_reason": "max_outstanding_keys"}, resp.Data["data"])
+		require.Len(t, server.CloudKeys(), 1)
+	})
+}
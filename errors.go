@@ -1,6 +1,18 @@
 package secretsengine
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// upstreamRetryAfter is the delay suggested to clients when Grafana Cloud is unavailable.
+const upstreamRetryAfter = 30 * time.Second
 
 type InvalidConfigurationError struct {
 	Msg string
@@ -34,10 +46,99 @@ func NewInternalError(msg string, err error) *InternalError {
 	}
 }
 
+// Error includes the status and message of a wrapped Grafana Cloud API
+// error, so callers can tell why the upstream request failed.
 func (e *InternalError) Error() string {
+	var apiErr *client.APIError
+	if errors.As(e.Err, &apiErr) {
+		return fmt.Sprintf("internal error: %s: %s", e.Msg, apiErr)
+	}
+
 	return fmt.Sprintf("internal error: %s", e.Msg)
 }
 
 func (e *InternalError) Unwrap() error {
 	return e.Err
 }
+
+// Reasons of an IssuanceDeniedError, returned as deny_reason so callers can
+// branch on the guardrail that refused the key.
+const (
+	denyReasonBoundCIDRs          = "bound_cidrs"
+	denyReasonBoundEntityMetadata = "bound_entity_metadata"
+	denyReasonIssuanceWindow      = "issuance_window"
+	denyReasonIssuanceRate        = "issuance_rate"
+	denyReasonMaxOutstandingKeys  = "max_outstanding_keys"
+	denyReasonMaintenanceMode     = "maintenance_mode"
+)
+
+// IssuanceDeniedError is returned when a role's guardrails refuse to issue a key.
+type IssuanceDeniedError struct {
+	Reason string
+	Msg    string
+}
+
+func NewIssuanceDeniedError(reason, msg string) *IssuanceDeniedError {
+	return &IssuanceDeniedError{
+		Reason: reason,
+		Msg:    msg,
+	}
+}
+
+func (e *IssuanceDeniedError) Error() string {
+	return fmt.Sprintf("issuance denied: %s", e.Msg)
+}
+
+// errorResponse reports an InvalidConfigurationError or IssuanceDeniedError
+// to the caller as a logical.ErrorResponse, which Vault returns as a 400.
+// The reason of an IssuanceDeniedError is returned as deny_reason.
+// Only the outermost error is considered for those, so invalid stored data
+// wrapped in an InternalError remains an internal error. Errors caused by
+// Grafana Cloud being unavailable are returned as a 503 or 429, see
+// retryableError. Any other error is passed through and returned as a 500.
+func errorResponse(err error) (*logical.Response, error) {
+	//nolint:errorlint // wrapped errors are deliberately not unwrapped.
+	if invalid, ok := err.(*InvalidConfigurationError); ok {
+		return logical.ErrorResponse(invalid.Error()), nil
+	}
+
+	//nolint:errorlint // wrapped errors are deliberately not unwrapped.
+	if denied, ok := err.(*IssuanceDeniedError); ok {
+		// Vault returns the data element of error responses alongside the errors.
+		resp := logical.ErrorResponse(denied.Error())
+		resp.Data["data"] = map[string]interface{}{
+			"deny_reason": denied.Reason,
+		}
+
+		return resp, nil
+	}
+
+	if retryable := retryableError(err); retryable != nil {
+		return nil, retryable
+	}
+
+	return nil, err
+}
+
+// retryableError returns err as a logical.HTTPCodedError with a 503, or a
+// 429 when rate limited, if it was caused by Grafana Cloud being unreachable
+// or unavailable, so clients back off and retry. Otherwise it returns nil.
+func retryableError(err error) error {
+	status := 0
+
+	var netErr net.Error
+
+	switch {
+	case errors.Is(err, client.ErrRateLimited):
+		status = http.StatusTooManyRequests
+	case errors.Is(err, client.ErrUnavailable), errors.As(err, &netErr):
+		status = http.StatusServiceUnavailable
+	}
+
+	if status == 0 {
+		return nil
+	}
+
+	return logical.CodedError(status, fmt.Sprintf("%s; Grafana Cloud is unavailable, retry in %d seconds",
+		err, int(upstreamRetryAfter.Seconds())))
+}
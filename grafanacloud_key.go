@@ -3,16 +3,26 @@ package secretsengine
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"strings"
+	"time"
 
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client"
 	uuid "github.com/google/uuid"
 	grafanclient "github.com/grafana/grafana-api-golang-client"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
+// tenantHeader selects the tenant of multi-tenant Loki and Mimir.
+const tenantHeader = "X-Scope-OrgID"
+
 type GrafanaCloudKey struct {
-	Name             string
-	Token            string
+	Name  string
+	Token string
+	ID    int64
+	// URL is the url of the role the key was issued for, if it has one.
+	URL              string
 	User             string
 	PrometheusUser   string
 	PrometheusURL    string
@@ -38,6 +48,14 @@ func (b *grafanaCloudBackend) grafanaCloudKey() *framework.Secret {
 				Type:        framework.TypeString,
 				Description: "Grafana cloud api credentials Token",
 			},
+			"key_name": {
+				Type:        framework.TypeString,
+				Description: "Name of the key in Grafana Cloud",
+			},
+			"key_id": {
+				Type:        framework.TypeInt,
+				Description: "ID of the key in Grafana Cloud",
+			},
 		},
 		Revoke: b.keyRevoke,
 		Renew:  b.keyRenew,
@@ -45,40 +63,180 @@ func (b *grafanaCloudBackend) grafanaCloudKey() *framework.Secret {
 }
 
 func (b *grafanaCloudBackend) keyRevoke(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	c, err := b.getClient(ctx, req.Storage)
+	data, err := parseSecretData(req.Secret.InternalData)
 	if err != nil {
-		return nil, fmt.Errorf("error getting client: %w", err)
+		return nil, err
 	}
 
-	config, err := getConfig(ctx, req.Storage)
-	if err != nil {
-		return nil, err
+	// The key is already gone, deleting it again would fail and keep the lease.
+	if record, err := revokedByOrgKeysRecord(ctx, req.Storage, data.Name); err != nil || record != nil {
+		return &logical.Response{}, err
 	}
 
-	org := config.Organisation
-	tokenID := req.Secret.InternalData["name"].(string)
-	err = c.DeleteCloudAPIKey(org, tokenID)
-	if err != nil {
+	if err := b.revokeKey(ctx, req.Storage, data); err != nil {
+		b.logRevocationError(data.Name, err, time.Now())
+		b.notify(ctx, req.Storage, webhookEventRevocationFailed, map[string]interface{}{
+			"key_name": data.Name,
+			"key_id":   data.ID,
+			"role":     data.Role,
+			"error":    err.Error(),
+		})
+
 		return nil, err
 	}
 
+	if data.Name != "" {
+		if err := revokeIssuanceRecord(ctx, req.Storage, data.Name, time.Now().UTC()); err != nil {
+			return nil, err
+		}
+	}
+
+	b.notify(ctx, req.Storage, webhookEventRevoked, map[string]interface{}{
+		"key_name": data.Name,
+		"key_id":   data.ID,
+		"role":     data.Role,
+	})
+
 	return &logical.Response{}, nil
 }
 
+// revokeKey deletes the key identified by the internal data of its secret.
+func (b *grafanaCloudBackend) revokeKey(ctx context.Context, s logical.Storage, data *secretData) error {
+	if data.Name == "" {
+		return NewInternalError("secret is missing name internal data", nil)
+	}
+
+	if err := b.deleteKey(ctx, s, data.URL, data.Name); err != nil {
+		return NewInternalError("error deleting Grafana Cloud key", err)
+	}
+
+	return nil
+}
+
+// deleteKey removes the named key from the configured organisation, against
+// apiURL when the key was issued for a role with a url.
+func (b *grafanaCloudBackend) deleteKey(ctx context.Context, s logical.Storage, apiURL, name string) error {
+	config, err := getConfig(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	// Without a config there is no organisation to delete the key from,
+	// fail so that Vault retries the revocation once it is configured again.
+	if config == nil {
+		return NewInvalidConfigurationError("backend not configured", nil)
+	}
+
+	c, err := b.clientFor(ctx, s, config, apiURL)
+	if err != nil {
+		return fmt.Errorf("error getting client: %w", err)
+	}
+
+	return b.verifyDeletion(config.VerifyRevocation, name, func() error {
+		defer b.observeLatency(apiOpDeleteKey, time.Now())
+
+		return c.DeleteCloudAPIKey(config.Organisation, name)
+	}, func() (bool, error) {
+		defer b.observeLatency(apiOpListKeys, time.Now())
+
+		keys, err := c.ListCloudAPIKeys(config.Organisation)
+		if err != nil {
+			return false, err
+		}
+
+		for _, key := range keys.Items {
+			if key.Name == name {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	})
+}
+
+// verifyDeletion deletes a key with del. When verify is set it then checks
+// with exists whether the key is gone, as Grafana Cloud occasionally reports
+// an error for deletions that succeeded: the error is ignored if the key is
+// gone, and the key is deleted once more if it still exists.
+func (b *grafanaCloudBackend) verifyDeletion(verify bool, key string, del func() error, exists func() (bool, error)) error {
+	err := del()
+	if !verify {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		found, existsErr := exists()
+		if existsErr != nil {
+			b.Logger().Warn("failed to verify the key was deleted", "key", key, "error", existsErr)
+
+			if err != nil {
+				return err
+			}
+
+			return existsErr
+		}
+
+		if !found {
+			if err != nil {
+				b.Logger().Warn("deleting key reported an error but the key no longer exists", "key", key, "error", err)
+			}
+
+			return nil
+		}
+
+		if attempt == 2 {
+			if err != nil {
+				return err
+			}
+
+			return NewInternalError(fmt.Sprintf("key %s still exists after deleting it twice", key), nil)
+		}
+
+		b.Logger().Warn("key still exists after deleting it, retrying", "key", key, "error", err)
+
+		err = del()
+	}
+}
+
 func (b *grafanaCloudBackend) keyRenew(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	roleRaw, ok := req.Secret.InternalData["role"]
-	if !ok {
-		return nil, NewInternalError("secret is missing role internal data", nil)
+	data, err := parseSecretData(req.Secret.InternalData)
+	if err != nil {
+		return nil, err
+	}
+
+	// Version 1 secrets only hold the key name, which the role is derived from.
+	if data.Role == "" {
+		config, err := getConfig(ctx, req.Storage)
+		if err != nil {
+			return nil, err
+		}
+
+		if config == nil {
+			return logical.ErrorResponse("backend not configured"), nil
+		}
+
+		data.Role = roleFromKeyName(config, data.Name)
 	}
 
-	role := roleRaw.(string)
+	if record, err := revokedByOrgKeysRecord(ctx, req.Storage, data.Name); err != nil {
+		return nil, err
+	} else if record != nil {
+		return logical.ErrorResponse(fmt.Sprintf("key %q was deleted by revoke-org-keys, revoke the lease", data.Name)), nil
+	}
+
+	role := data.Role
 	roleEntry, err := b.getRole(ctx, req.Storage, role)
 	if err != nil {
 		return nil, NewInternalError("error retrieving role", err)
 	}
 
 	if roleEntry == nil {
-		return nil, NewInternalError("error retrieving role: role is nil", nil)
+		return logical.ErrorResponse(fmt.Sprintf("role %q no longer exists", role)), nil
+	}
+
+	// Leases issued before the role was made non-renewable are still renewable in Vault.
+	if roleEntry.NonRenewable {
+		return logical.ErrorResponse(fmt.Sprintf("role %q does not allow renewal, issue a new key instead", role)), nil
 	}
 
 	resp := &logical.Response{Secret: req.Secret}
@@ -93,12 +251,55 @@ func (b *grafanaCloudBackend) keyRenew(ctx context.Context, req *logical.Request
 	return resp, nil
 }
 
-func createKey(_ context.Context, c *grafanclient.Client, organisation, roleName string,
-	config *grafanaCloudConfig, grafanaCloudRole string,
-) (*GrafanaCloudKey, error) {
-	suffix := uuid.New().String()
-	tokenName := fmt.Sprintf("%s_%s", roleName, suffix)
+// keyName returns a unique name for a key issued for the role, prefixed
+// with the token_prefix and suffixed with the environment of the config if set.
+func keyName(config *Config, roleName string) string {
+	name := fmt.Sprintf("%s_%s", roleName, uuid.New().String())
+	if config.TokenPrefix != "" {
+		name = config.TokenPrefix + "_" + name
+	}
+
+	if config.Environment != "" {
+		name = name + "_" + config.Environment
+	}
+
+	return name
+}
+
+//nolint:gochecknoglobals // compiled once, matches the suffix added by keyName.
+var keyNameSuffixRegex = regexp.MustCompile(`_[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
 
+// isPluginKeyName reports whether name could have been returned by keyName
+// for the config. Without a token_prefix, keys issued by other mounts or
+// clusters sharing the organisation cannot be told apart.
+func isPluginKeyName(config *Config, name string) bool {
+	if config.TokenPrefix != "" && !strings.HasPrefix(name, config.TokenPrefix+"_") {
+		return false
+	}
+
+	if config.Environment != "" && !strings.HasSuffix(name, "_"+config.Environment) {
+		return false
+	}
+
+	return keyNameSuffixRegex.MatchString(strings.TrimSuffix(name, "_"+config.Environment))
+}
+
+// roleFromKeyName returns the role a key returned by keyName was issued for.
+func roleFromKeyName(config *Config, name string) string {
+	if config.TokenPrefix != "" {
+		name = strings.TrimPrefix(name, config.TokenPrefix+"_")
+	}
+
+	if config.Environment != "" {
+		name = strings.TrimSuffix(name, "_"+config.Environment)
+	}
+
+	return keyNameSuffixRegex.ReplaceAllString(name, "")
+}
+
+func createKey(_ context.Context, c client.API, organisation, tokenName string,
+	config *Config, grafanaCloudRole string,
+) (*GrafanaCloudKey, error) {
 	key, err := c.CreateCloudAPIKey(
 		organisation,
 		&grafanclient.CreateCloudAPIKeyInput{
@@ -106,22 +307,69 @@ func createKey(_ context.Context, c *grafanclient.Client, organisation, roleName
 			Role: grafanaCloudRole,
 		})
 	if err != nil {
-		return nil, fmt.Errorf("error creating Grafana Cloud key: %w", err)
-	}
-
-	return &GrafanaCloudKey{
-		Name:             key.Name,
-		Token:            key.Token,
-		User:             config.User,
-		PrometheusUser:   config.PrometheusUser,
-		PrometheusURL:    config.PrometheusURL,
-		LokiUser:         config.LokiUser,
-		LokiURL:          config.LokiURL,
-		TempoUser:        config.TempoUser,
-		TempoURL:         config.TempoURL,
-		AlertmanagerUser: config.AlertmanagerUser,
-		AlertmanagerURL:  config.AlertmanagerURL,
-		GraphiteUser:     config.GraphiteUser,
-		GraphiteURL:      config.GraphiteURL,
-	}, nil
+		return nil, err
+	}
+
+	cloudKey := &GrafanaCloudKey{
+		Name:  key.Name,
+		Token: key.Token,
+		ID:    int64(key.ID),
+	}
+	cloudKey.setEndpoints(&config.Endpoints)
+
+	return cloudKey, nil
+}
+
+// setEndpoints sets the users and URLs returned alongside a Cloud API key.
+func (k *GrafanaCloudKey) setEndpoints(e *Endpoints) {
+	k.User = e.PrometheusUser
+	k.PrometheusUser = e.PrometheusUser
+	k.PrometheusURL = e.PrometheusURL
+	k.LokiUser = e.LokiUser
+	k.LokiURL = e.LokiURL
+	k.TempoUser = e.TempoUser
+	k.TempoURL = e.TempoURL
+	k.AlertmanagerUser = e.AlertmanagerUser
+	k.AlertmanagerURL = e.AlertmanagerURL
+	k.GraphiteUser = e.GraphiteUser
+	k.GraphiteURL = e.GraphiteURL
+}
+
+// connectionData returns the users and URLs returned alongside the
+// token, leaving out those which are not set.
+func (k *GrafanaCloudKey) connectionData() map[string]interface{} {
+	data := map[string]interface{}{}
+
+	for field, value := range map[string]string{
+		"user":              k.User,
+		"prometheus_user":   k.PrometheusUser,
+		"loki_user":         k.LokiUser,
+		"tempo_user":        k.TempoUser,
+		"alertmanager_user": k.AlertmanagerUser,
+		"graphite_user":     k.GraphiteUser,
+		"prometheus_url":    k.PrometheusURL,
+		"loki_url":          k.LokiURL,
+		"tempo_url":         k.TempoURL,
+		"alertmanager_url":  k.AlertmanagerURL,
+		"graphite_url":      k.GraphiteURL,
+	} {
+		if value != "" {
+			data[field] = value
+		}
+	}
+
+	// The multi-tenant Loki and Mimir (hosted Prometheus) of Grafana Cloud
+	// use the instance ID as tenant, which log and metric shippers set in
+	// the X-Scope-OrgID header when not derived from the basic auth user.
+	for field, tenant := range map[string]string{
+		"prometheus_tenant_id": k.PrometheusUser,
+		"loki_tenant_id":       k.LokiUser,
+	} {
+		if tenant != "" {
+			data[field] = tenant
+			data["tenant_header"] = tenantHeader
+		}
+	}
+
+	return data
 }
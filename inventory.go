@@ -0,0 +1,115 @@
+package secretsengine
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	grafanclient "github.com/grafana/grafana-api-golang-client"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// inventoryInterval is how often the keys in the organisation are counted.
+// Vault calls the periodic function every minute, listing every key that
+// often would be wasteful for a gauge.
+const inventoryInterval = 10 * time.Minute
+
+//nolint:gochecknoglobals // metric name, shared with the docs.
+var outstandingKeysGauge = []string{"grafanacloud", "keys", "outstanding"}
+
+// keyInventory rate limits the inventory of keys done by the periodic function.
+type keyInventory struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+// due reports whether an inventory should run at now, and if so records it.
+func (i *keyInventory) due(now time.Time) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if !i.last.IsZero() && now.Sub(i.last) < inventoryInterval {
+		return false
+	}
+
+	i.last = now
+
+	return true
+}
+
+// periodicFunc is called by Vault every minute on the active node.
+func (b *grafanaCloudBackend) periodicFunc(ctx context.Context, req *logical.Request) error {
+	b.logRevocationErrorSummary(time.Now())
+
+	if !b.inventory.due(time.Now()) {
+		return nil
+	}
+
+	pruned, err := pruneIssuanceHistory(ctx, req.Storage, time.Now().Add(-issuanceHistoryRetention))
+	if err != nil {
+		b.Logger().Warn("failed to prune the issuance history", "error", err)
+	} else if pruned > 0 {
+		b.Logger().Debug("pruned the issuance history", "count", pruned)
+	}
+
+	config, err := getConfig(ctx, req.Storage)
+	if err != nil || config == nil {
+		return err
+	}
+
+	// Listing the keys doubles as the check that the admin key still works.
+	count, err := b.countPluginKeys(ctx, req.Storage, config)
+	b.reportAdminKey(config, err, time.Now())
+
+	if err != nil {
+		return nil
+	}
+
+	b.Logger().Debug("counted keys in the organisation", "organisation", config.Organisation, "count", count)
+	metrics.SetGaugeWithLabels(outstandingKeysGauge, float32(count), []metrics.Label{
+		{Name: "organisation", Value: config.Organisation},
+	})
+
+	b.logRoleDrift(ctx, req.Storage, config)
+
+	return nil
+}
+
+// countPluginKeys returns the number of Cloud API keys in the organisation
+// named like the keys this mount issues, whether or not they still have a lease.
+func (b *grafanaCloudBackend) countPluginKeys(ctx context.Context, s logical.Storage, config *Config) (int, error) {
+	keys, err := b.listPluginKeys(ctx, s, config)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(keys), nil
+}
+
+// listPluginKeys returns the Cloud API keys in the organisation named like
+// the keys this mount issues.
+func (b *grafanaCloudBackend) listPluginKeys(ctx context.Context, s logical.Storage, config *Config) ([]*grafanclient.CloudAPIKey, error) {
+	c, err := b.getClient(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	keys, err := c.ListCloudAPIKeys(config.Organisation)
+	b.observeLatency(apiOpListKeys, start)
+
+	if err != nil {
+		return nil, NewInternalError("error listing Grafana Cloud keys", err)
+	}
+
+	var pluginKeys []*grafanclient.CloudAPIKey
+
+	for _, key := range keys.Items {
+		if isPluginKeyName(config, key.Name) {
+			pluginKeys = append(pluginKeys, key)
+		}
+	}
+
+	return pluginKeys, nil
+}
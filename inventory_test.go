@@ -0,0 +1,89 @@
+package secretsengine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client/clienttest"
+	grafanclient "github.com/grafana/grafana-api-golang-client"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountPluginKeys(t *testing.T) {
+	server := clienttest.NewServer(organisation, key)
+	defer server.Close()
+
+	b, s := getTestBackend(t)
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          server.URL + "/api",
+		"organisation": organisation,
+		"token_prefix": "cluster-a",
+	}))
+
+	_, err := testTokenRoleCreate(t, b, s, "cloud-role", map[string]interface{}{
+		"gc_role": "MetricsPublisher",
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/cloud-role",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+	}
+
+	// Keys not issued by this mount, one of them by another cluster.
+	c, err := grafanclient.New(server.URL, grafanclient.Config{APIKey: key})
+	require.NoError(t, err)
+
+	for _, name := range []string{"manual", "cluster-b_cloud-role_0b7e7a6c-3f0e-4a8b-9d3c-5c1f2f6b9e10"} {
+		_, err = c.CreateCloudAPIKey(organisation, &grafanclient.CreateCloudAPIKeyInput{Name: name, Role: "Viewer"})
+		require.NoError(t, err)
+	}
+
+	config, err := getConfig(context.Background(), s)
+	require.NoError(t, err)
+
+	count, err := b.countPluginKeys(context.Background(), s, config)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	config.TokenPrefix = ""
+	count, err = b.countPluginKeys(context.Background(), s, config)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	// None of the keys were issued with an environment.
+	config.Environment = "prod-eu"
+	count, err = b.countPluginKeys(context.Background(), s, config)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestPluginKeyNames(t *testing.T) {
+	config := &Config{TokenPrefix: "cluster-a", Environment: "prod-eu"}
+
+	name := keyName(config, "cloud_role")
+	assert.True(t, isPluginKeyName(config, name))
+	assert.Equal(t, "cloud_role", roleFromKeyName(config, name))
+
+	assert.False(t, isPluginKeyName(config, "cluster-a_cloud_role_0b7e7a6c-3f0e-4a8b-9d3c-5c1f2f6b9e10"))
+	assert.False(t, isPluginKeyName(config, "cluster-a_cloud_role_0b7e7a6c-3f0e-4a8b-9d3c-5c1f2f6b9e10_prod-us"))
+}
+
+func TestKeyInventoryDue(t *testing.T) {
+	var inventory keyInventory
+
+	now := time.Now()
+	assert.True(t, inventory.due(now))
+	assert.False(t, inventory.due(now.Add(time.Minute)))
+	assert.True(t, inventory.due(now.Add(inventoryInterval)))
+}
@@ -0,0 +1,370 @@
+package secretsengine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// issuedStoragePrefix is where a record of every key with an outstanding
+// lease is kept, keyed by the key name.
+const issuedStoragePrefix = "issued/"
+
+// revokedStoragePrefix is where the records of revoked keys are moved, so
+// the issuance history outlives the leases.
+const revokedStoragePrefix = "revoked/"
+
+// sealWrappedIssuancePrefix is prepended to the prefixes above when
+// seal_wrap_issuance_records is enabled, it is listed in SealWrapStorage.
+// SealWrapStorage is fixed when the plugin is loaded, so the config flag
+// selects where records are written instead.
+const sealWrappedIssuancePrefix = "sealed/"
+
+// issuanceHistoryRetention is how long the records of revoked keys are kept.
+const issuanceHistoryRetention = 7 * 24 * time.Hour
+
+// issuanceRecord tracks a key issued under a lease. It is written when the
+// key is issued and moved under revokedStoragePrefix once the key has been
+// revoked.
+type issuanceRecord struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+	// URL is the url of the role the key was issued for, if it has one.
+	URL       string     `json:"url,omitempty"`
+	IssuedAt  time.Time  `json:"issued_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	// ExpiresAt is when the lease reaches its max TTL, renewals cannot
+	// extend it further. Unset for records written by older versions.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	// EntityID and DisplayName identify the token that requested the key.
+	EntityID    string `json:"entity_id,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+
+	// TokenHMAC matches the issued token to the record, see tokenHMAC.
+	TokenHMAC string `json:"token_hmac,omitempty"`
+
+	// Adopted is set for keys created outside Vault and put under a lease
+	// by the adopt path.
+	Adopted bool `json:"adopted,omitempty"`
+
+	// RevokedBy is set when the key was deleted by revoke-org-keys rather
+	// than by revoking its lease, which then succeeds without deleting it.
+	RevokedBy string `json:"revoked_by,omitempty"`
+}
+
+// issuanceRecordPrefix returns where the records under base are written.
+func issuanceRecordPrefix(base string, sealWrap bool) string {
+	if sealWrap {
+		return sealWrappedIssuancePrefix + base
+	}
+
+	return base
+}
+
+// issuanceRecordPrefixes returns every prefix the records under base can be
+// kept at, as records written before seal_wrap_issuance_records was toggled
+// may not have been moved yet.
+func issuanceRecordPrefixes(base string) []string {
+	return []string{sealWrappedIssuancePrefix + base, base}
+}
+
+// sealWrapIssuanceRecords reports whether the config enables
+// seal_wrap_issuance_records.
+func sealWrapIssuanceRecords(ctx context.Context, s logical.Storage) (bool, error) {
+	config, err := getConfig(ctx, s)
+	if err != nil {
+		return false, NewInternalError("error reading secrets engine configuration", err)
+	}
+
+	return config != nil && config.SealWrapIssuanceRecords, nil
+}
+
+// writeIssuanceRecord writes the record under base, seal wrapped when the
+// config says so, and deletes any copy at the other prefix.
+func writeIssuanceRecord(ctx context.Context, s logical.Storage, base string, record *issuanceRecord) error {
+	sealWrap, err := sealWrapIssuanceRecords(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	entry, err := logical.StorageEntryJSON(issuanceRecordPrefix(base, sealWrap)+record.Name, record)
+	if err != nil {
+		return NewInternalError("error encoding issuance record", err)
+	}
+
+	if err := s.Put(ctx, entry); err != nil {
+		return NewInternalError("error writing issuance record", err)
+	}
+
+	if err := s.Delete(ctx, issuanceRecordPrefix(base, !sealWrap)+record.Name); err != nil {
+		return NewInternalError("error deleting issuance record", err)
+	}
+
+	return nil
+}
+
+// deleteIssuanceRecord deletes the record of the named key under base.
+func deleteIssuanceRecord(ctx context.Context, s logical.Storage, base, name string) error {
+	for _, prefix := range issuanceRecordPrefixes(base) {
+		if err := s.Delete(ctx, prefix+name); err != nil {
+			return NewInternalError("error deleting issuance record", err)
+		}
+	}
+
+	return nil
+}
+
+// readIssuanceRecord returns the record of the named key under base, or nil
+// if there is none.
+func readIssuanceRecord(ctx context.Context, s logical.Storage, base, name string) (*issuanceRecord, error) {
+	for _, prefix := range issuanceRecordPrefixes(base) {
+		entry, err := s.Get(ctx, prefix+name)
+		if err != nil {
+			return nil, NewInternalError("error reading issuance record", err)
+		}
+
+		if entry == nil {
+			continue
+		}
+
+		record := &issuanceRecord{}
+		if err := entry.DecodeJSON(record); err != nil {
+			return nil, NewInternalError("error decoding issuance record", err)
+		}
+
+		return record, nil
+	}
+
+	return nil, nil
+}
+
+func putIssuanceRecord(ctx context.Context, s logical.Storage, record *issuanceRecord) error {
+	return writeIssuanceRecord(ctx, s, issuedStoragePrefix, record)
+}
+
+// getIssuanceRecord returns the record of the named key, or nil if there is none.
+func getIssuanceRecord(ctx context.Context, s logical.Storage, name string) (*issuanceRecord, error) {
+	return readIssuanceRecord(ctx, s, issuedStoragePrefix, name)
+}
+
+// getRevokedIssuanceRecord returns the record of the named key once revoked,
+// or nil if there is none.
+func getRevokedIssuanceRecord(ctx context.Context, s logical.Storage, name string) (*issuanceRecord, error) {
+	return readIssuanceRecord(ctx, s, revokedStoragePrefix, name)
+}
+
+// revokedByOrgKeysRecord returns the record of the named key if it was
+// deleted by revoke-org-keys, or nil.
+func revokedByOrgKeysRecord(ctx context.Context, s logical.Storage, name string) (*issuanceRecord, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	record, err := getRevokedIssuanceRecord(ctx, s, name)
+	if err != nil || record == nil || record.RevokedBy != revokedByOrgKeys {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// revokeIssuanceRecord moves the record of the named key to the revoked
+// records, stamped with the time it was revoked.
+func revokeIssuanceRecord(ctx context.Context, s logical.Storage, name string, now time.Time) error {
+	record, err := getIssuanceRecord(ctx, s, name)
+	if err != nil || record == nil {
+		return err
+	}
+
+	record.RevokedAt = &now
+
+	if err := writeIssuanceRecord(ctx, s, revokedStoragePrefix, record); err != nil {
+		return err
+	}
+
+	return deleteIssuanceRecord(ctx, s, issuedStoragePrefix, name)
+}
+
+// listIssuanceRecordNames returns the names of the records under base.
+func listIssuanceRecordNames(ctx context.Context, s logical.Storage, base string) ([]string, error) {
+	var names []string
+
+	seen := map[string]bool{}
+
+	for _, prefix := range issuanceRecordPrefixes(base) {
+		listed, err := s.List(ctx, prefix)
+		if err != nil {
+			return nil, NewInternalError("error listing issuance records", err)
+		}
+
+		for _, name := range listed {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	return names, nil
+}
+
+// readIssuanceRecords returns every record stored under the prefix.
+func readIssuanceRecords(ctx context.Context, s logical.Storage, prefix string) ([]*issuanceRecord, error) {
+	names, err := listIssuanceRecordNames(ctx, s, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*issuanceRecord, 0, len(names))
+
+	for _, name := range names {
+		record, err := readIssuanceRecord(ctx, s, prefix, name)
+		if err != nil {
+			return nil, err
+		}
+
+		if record != nil {
+			records = append(records, record)
+		}
+	}
+
+	return records, nil
+}
+
+// moveIssuanceRecords moves every record to where the records are written
+// with seal_wrap_issuance_records set to sealWrap, returning how many moved.
+func moveIssuanceRecords(ctx context.Context, s logical.Storage, sealWrap bool) (int, error) {
+	moved := 0
+
+	for _, base := range []string{issuedStoragePrefix, revokedStoragePrefix} {
+		from, to := issuanceRecordPrefix(base, !sealWrap), issuanceRecordPrefix(base, sealWrap)
+
+		names, err := s.List(ctx, from)
+		if err != nil {
+			return moved, NewInternalError("error listing issuance records", err)
+		}
+
+		for _, name := range names {
+			entry, err := s.Get(ctx, from+name)
+			if err != nil {
+				return moved, NewInternalError("error reading issuance record", err)
+			}
+
+			if entry == nil {
+				continue
+			}
+
+			if err := s.Put(ctx, &logical.StorageEntry{Key: to + name, Value: entry.Value}); err != nil {
+				return moved, NewInternalError("error writing issuance record", err)
+			}
+
+			if err := s.Delete(ctx, from+name); err != nil {
+				return moved, NewInternalError("error deleting issuance record", err)
+			}
+
+			moved++
+		}
+	}
+
+	return moved, nil
+}
+
+// issuanceHistory returns the records of the outstanding keys followed by
+// those of the keys revoked within the retention period.
+func issuanceHistory(ctx context.Context, s logical.Storage) ([]*issuanceRecord, error) {
+	issued, err := readIssuanceRecords(ctx, s, issuedStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, err := readIssuanceRecords(ctx, s, revokedStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(issued, revoked...), nil
+}
+
+// pruneIssuanceHistory deletes the records of keys revoked before cutoff,
+// returning how many were deleted.
+func pruneIssuanceHistory(ctx context.Context, s logical.Storage, cutoff time.Time) (int, error) {
+	revoked, err := readIssuanceRecords(ctx, s, revokedStoragePrefix)
+	if err != nil {
+		return 0, err
+	}
+
+	pruned := 0
+
+	for _, record := range revoked {
+		if record.RevokedAt == nil || !record.RevokedAt.Before(cutoff) {
+			continue
+		}
+
+		if err := deleteIssuanceRecord(ctx, s, revokedStoragePrefix, record.Name); err != nil {
+			return pruned, err
+		}
+
+		pruned++
+	}
+
+	return pruned, nil
+}
+
+// listIssuanceRecords returns the names of the keys with an outstanding lease.
+func listIssuanceRecords(ctx context.Context, s logical.Storage) ([]string, error) {
+	return listIssuanceRecordNames(ctx, s, issuedStoragePrefix)
+}
+
+// checkMaintenanceMode returns an IssuanceDeniedError while maintenance_mode
+// is enabled in the config. Renewals and revocations are not affected.
+func checkMaintenanceMode(config *Config) error {
+	if !config.MaintenanceMode {
+		return nil
+	}
+
+	return NewIssuanceDeniedError(denyReasonMaintenanceMode, "issuance is temporarily disabled, the mount is in maintenance mode")
+}
+
+// checkOutstandingKeys returns an IssuanceDeniedError if issuing another key
+// would exceed the max_outstanding_keys limit of the config. The limit
+// applies per mount: the issuance records of the mount are counted, not the
+// keys of the organisation. Issuance must hold lockOutstandingKeys so that
+// keys issued concurrently are counted.
+func checkOutstandingKeys(ctx context.Context, s logical.Storage, config *Config) error {
+	if config.MaxOutstandingKeys == 0 {
+		return nil
+	}
+
+	issued, err := listIssuanceRecords(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	if len(issued) >= config.MaxOutstandingKeys {
+		return NewIssuanceDeniedError(denyReasonMaxOutstandingKeys, fmt.Sprintf("%d keys are outstanding, the limit set by max_outstanding_keys is %d",
+			len(issued), config.MaxOutstandingKeys))
+	}
+
+	return nil
+}
+
+// lockOutstandingKeys serializes issuance while max_outstanding_keys is set,
+// so that no other key is issued between checkOutstandingKeys and the write
+// of the issuance record. The returned func releases the lock.
+func (b *grafanaCloudBackend) lockOutstandingKeys(ctx context.Context, s logical.Storage) (func(), error) {
+	config, err := getConfig(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil || config.MaxOutstandingKeys == 0 {
+		return func() {}, nil
+	}
+
+	b.outstandingKeysLock.Lock()
+
+	return b.outstandingKeysLock.Unlock, nil
+}
@@ -0,0 +1,82 @@
+package secretsengine
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultIssuanceRateWindow is used when issuance rate thresholds are set
+// without an issuance_rate_window.
+const defaultIssuanceRateWindow = time.Hour
+
+// issuanceRateTracker counts the keys issued per role over a sliding window.
+// The counts are kept in memory, so they start over when the plugin restarts
+// and each Vault node tracks the requests it handles.
+type issuanceRateTracker struct {
+	mu     sync.Mutex
+	issued map[string][]time.Time
+}
+
+func newIssuanceRateTracker() *issuanceRateTracker {
+	return &issuanceRateTracker{issued: map[string][]time.Time{}}
+}
+
+// check is called before issuing a key for the role at now. It returns a
+// warning once the keys issued within the window, counting the one about to
+// be issued, exceed the warn threshold, and refuses the issuance when the
+// deny threshold is reached. Keys are only counted by count once they were
+// created, so failed attempts never count towards the thresholds, at the
+// cost of concurrent requests possibly going over the deny threshold.
+func (t *issuanceRateTracker) check(role string, now time.Time, config *Config) (string, error) {
+	if config.IssuanceRateWarn == 0 && config.IssuanceRateDeny == 0 {
+		return "", nil
+	}
+
+	window := config.issuanceRateWindow()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	issued := t.inWindow(role, now, window)
+
+	if config.IssuanceRateDeny > 0 && len(issued) >= config.IssuanceRateDeny {
+		return "", NewIssuanceDeniedError(denyReasonIssuanceRate, fmt.Sprintf("role %q issued %d keys in the last %s, the limit set by issuance_rate_deny is %d",
+			role, len(issued), window, config.IssuanceRateDeny))
+	}
+
+	if total := len(issued) + 1; config.IssuanceRateWarn > 0 && total > config.IssuanceRateWarn {
+		return fmt.Sprintf("role %q issued %d keys in the last %s, above the issuance_rate_warn threshold of %d",
+			role, total, window, config.IssuanceRateWarn), nil
+	}
+
+	return "", nil
+}
+
+// count records a key issued for the role at now.
+func (t *issuanceRateTracker) count(role string, now time.Time, config *Config) {
+	if config.IssuanceRateWarn == 0 && config.IssuanceRateDeny == 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.issued[role] = append(t.inWindow(role, now, config.issuanceRateWindow()), now)
+}
+
+// inWindow drops the keys of the role issued before the window and returns
+// the others. It must be called with mu held.
+func (t *issuanceRateTracker) inWindow(role string, now time.Time, window time.Duration) []time.Time {
+	issued := t.issued[role]
+
+	// The slice is in time order.
+	cutoff := now.Add(-window)
+	for len(issued) > 0 && !issued[0].After(cutoff) {
+		issued = issued[1:]
+	}
+
+	t.issued[role] = issued
+
+	return issued
+}
@@ -0,0 +1,83 @@
+package secretsengine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordIssuance checks and counts a key issued for the role.
+func recordIssuance(tracker *issuanceRateTracker, role string, now time.Time, config *Config) (string, error) {
+	warning, err := tracker.check(role, now, config)
+	if err == nil {
+		tracker.count(role, now, config)
+	}
+
+	return warning, err
+}
+
+func TestIssuanceRate(t *testing.T) {
+	start := time.Date(2026, time.October, 14, 9, 0, 0, 0, time.UTC)
+
+	t.Run("Disabled", func(t *testing.T) {
+		tracker := newIssuanceRateTracker()
+
+		for i := 0; i < 100; i++ {
+			warning, err := recordIssuance(tracker, "role", start, &Config{})
+			require.NoError(t, err)
+			require.Empty(t, warning)
+		}
+
+		assert.Empty(t, tracker.issued)
+	})
+
+	t.Run("Warn and deny", func(t *testing.T) {
+		tracker := newIssuanceRateTracker()
+		config := &Config{
+			IssuanceRateWindow: 10 * time.Minute,
+			IssuanceRateWarn:   2,
+			IssuanceRateDeny:   3,
+		}
+
+		for i := 0; i < 2; i++ {
+			warning, err := recordIssuance(tracker, "role", start.Add(time.Duration(i)*time.Minute), config)
+			require.NoError(t, err)
+			require.Empty(t, warning)
+		}
+
+		warning, err := recordIssuance(tracker, "role", start.Add(2*time.Minute), config)
+		require.NoError(t, err)
+		assert.Equal(t, `role "role" issued 3 keys in the last 10m0s, above the issuance_rate_warn threshold of 2`, warning)
+
+		_, err = recordIssuance(tracker, "role", start.Add(3*time.Minute), config)
+		assert.EqualError(t, err, `issuance denied: role "role" issued 3 keys in the last 10m0s, the limit set by issuance_rate_deny is 3`)
+
+		// Other roles are counted separately.
+		warning, err = recordIssuance(tracker, "other", start.Add(3*time.Minute), config)
+		require.NoError(t, err)
+		assert.Empty(t, warning)
+
+		// The first key leaves the window 10 minutes after it was issued.
+		warning, err = recordIssuance(tracker, "role", start.Add(10*time.Minute), config)
+		require.NoError(t, err)
+		assert.NotEmpty(t, warning)
+	})
+
+	t.Run("Only created keys count", func(t *testing.T) {
+		tracker := newIssuanceRateTracker()
+		config := &Config{IssuanceRateDeny: 1}
+
+		// Attempts that fail upstream are checked but never counted.
+		for i := 0; i < 3; i++ {
+			_, err := tracker.check("role", start, config)
+			require.NoError(t, err)
+		}
+
+		tracker.count("role", start, config)
+
+		_, err := tracker.check("role", start, config)
+		assert.EqualError(t, err, `issuance denied: role "role" issued 1 keys in the last 1h0m0s, the limit set by issuance_rate_deny is 1`)
+	})
+}
@@ -0,0 +1,141 @@
+package secretsengine
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// issuanceWindow is a weekly recurring time range, in UTC, during which a
+// role may issue keys. It is written as "[days ]HH:MM-HH:MM", where days is
+// a single weekday ("Sat") or a range ("Mon-Fri"), e.g. "Mon-Fri 09:00-17:00".
+// Without days the window applies every day. A window whose end is before
+// its start runs past midnight into the next day.
+type issuanceWindow struct {
+	days  [7]bool
+	start time.Duration
+	end   time.Duration
+}
+
+//nolint:gochecknoglobals // lookup table for parsing weekdays.
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+func parseIssuanceWindow(raw string) (*issuanceWindow, error) {
+	fields := strings.Fields(raw)
+
+	w := &issuanceWindow{}
+
+	switch len(fields) {
+	case 1:
+		for i := range w.days {
+			w.days[i] = true
+		}
+	case 2:
+		if err := w.parseDays(fields[0]); err != nil {
+			return nil, err
+		}
+
+		fields = fields[1:]
+	default:
+		return nil, NewInvalidConfigurationError(fmt.Sprintf("invalid issuance_window %q, expected [days ]HH:MM-HH:MM", raw), nil)
+	}
+
+	times := strings.SplitN(fields[0], "-", 2)
+	if len(times) != 2 {
+		return nil, NewInvalidConfigurationError(fmt.Sprintf("invalid issuance_window %q, expected [days ]HH:MM-HH:MM", raw), nil)
+	}
+
+	var err error
+	if w.start, err = parseTimeOfDay(times[0]); err != nil {
+		return nil, err
+	}
+
+	if w.end, err = parseTimeOfDay(times[1]); err != nil {
+		return nil, err
+	}
+
+	if w.start == w.end {
+		return nil, NewInvalidConfigurationError(fmt.Sprintf("invalid issuance_window %q, start and end are equal", raw), nil)
+	}
+
+	return w, nil
+}
+
+func (w *issuanceWindow) parseDays(raw string) error {
+	days := strings.SplitN(strings.ToLower(raw), "-", 2)
+	first, last := days[0], days[len(days)-1]
+
+	from, ok := weekdays[first]
+	if !ok {
+		return NewInvalidConfigurationError(fmt.Sprintf("invalid weekday %q in issuance_window", first), nil)
+	}
+
+	to, ok := weekdays[last]
+	if !ok {
+		return NewInvalidConfigurationError(fmt.Sprintf("invalid weekday %q in issuance_window", last), nil)
+	}
+
+	for day := from; ; day = (day + 1) % 7 {
+		w.days[day] = true
+
+		if day == to {
+			return nil
+		}
+	}
+}
+
+func parseTimeOfDay(raw string) (time.Duration, error) {
+	t, err := time.Parse("15:04", raw)
+	if err != nil {
+		return 0, NewInvalidConfigurationError(fmt.Sprintf("invalid time %q in issuance_window, expected HH:MM", raw), err)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// contains reports whether t falls within the window.
+func (w *issuanceWindow) contains(t time.Time) bool {
+	t = t.UTC()
+	sinceMidnight := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+
+	if w.start < w.end {
+		return w.days[t.Weekday()] && sinceMidnight >= w.start && sinceMidnight < w.end
+	}
+
+	// The window runs past midnight, so the part before its end
+	// belongs to the window that started the previous day.
+	yesterday := (t.Weekday() + 6) % 7
+
+	return (w.days[t.Weekday()] && sinceMidnight >= w.start) || (w.days[yesterday] && sinceMidnight < w.end)
+}
+
+// checkIssuanceWindows returns an IssuanceDeniedError unless now falls within
+// one of the windows. Roles without windows may issue at any time.
+func checkIssuanceWindows(windows []string, now time.Time) error {
+	if len(windows) == 0 {
+		return nil
+	}
+
+	for _, raw := range windows {
+		w, err := parseIssuanceWindow(raw)
+		if err != nil {
+			return err
+		}
+
+		if w.contains(now) {
+			return nil
+		}
+	}
+
+	return NewIssuanceDeniedError(denyReasonIssuanceWindow, fmt.Sprintf("keys for this role can only be issued during %s (UTC), it is now %s",
+		strings.Join(windows, ", "), now.UTC().Format("Mon 15:04")))
+}
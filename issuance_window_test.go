@@ -0,0 +1,66 @@
+package secretsengine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssuanceWindow(t *testing.T) {
+	// Wednesday.
+	day := time.Date(2026, time.October, 14, 0, 0, 0, 0, time.UTC)
+	at := func(weekdayOffset int, hhmm string) time.Time {
+		tod, err := parseTimeOfDay(hhmm)
+		require.NoError(t, err)
+
+		return day.AddDate(0, 0, weekdayOffset).Add(tod)
+	}
+
+	t.Run("Contains", func(t *testing.T) {
+		for _, tc := range []struct {
+			window   string
+			at       time.Time
+			expected bool
+		}{
+			{"09:00-17:00", at(0, "09:00"), true},
+			{"09:00-17:00", at(0, "16:59"), true},
+			{"09:00-17:00", at(0, "17:00"), false},
+			{"09:00-17:00", at(3, "12:00"), true},
+			{"Mon-Fri 09:00-17:00", at(2, "12:00"), true},
+			{"Mon-Fri 09:00-17:00", at(3, "12:00"), false},
+			{"sat 10:00-12:00", at(3, "11:00"), true},
+			{"Fri-Mon 10:00-12:00", at(5, "11:00"), true},
+			{"Fri-Mon 10:00-12:00", at(6, "11:00"), false},
+			{"Wed 22:00-02:00", at(0, "23:00"), true},
+			{"Wed 22:00-02:00", at(1, "01:00"), true},
+			{"Wed 22:00-02:00", at(0, "01:00"), false},
+		} {
+			w, err := parseIssuanceWindow(tc.window)
+			require.NoError(t, err, tc.window)
+			assert.Equal(t, tc.expected, w.contains(tc.at), "%s at %s", tc.window, tc.at.Format("Mon 15:04"))
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for window, expected := range map[string]string{
+			"":                       `invalid configuration: invalid issuance_window "", expected [days ]HH:MM-HH:MM`,
+			"09:00":                  `invalid configuration: invalid issuance_window "09:00", expected [days ]HH:MM-HH:MM`,
+			"9am-5pm":                `invalid configuration: invalid time "9am" in issuance_window, expected HH:MM`,
+			"Mon-Funday 09:00-17:00": `invalid configuration: invalid weekday "funday" in issuance_window`,
+			"09:00-09:00":            `invalid configuration: invalid issuance_window "09:00-09:00", start and end are equal`,
+			"Mon 09:00-17:00 weekly": `invalid configuration: invalid issuance_window "Mon 09:00-17:00 weekly", expected [days ]HH:MM-HH:MM`,
+		} {
+			_, err := parseIssuanceWindow(window)
+			assert.EqualError(t, err, expected, window)
+		}
+	})
+
+	t.Run("Check", func(t *testing.T) {
+		assert.NoError(t, checkIssuanceWindows(nil, at(0, "03:00")))
+		assert.NoError(t, checkIssuanceWindows([]string{"Mon 09:00-10:00", "Wed 02:00-04:00"}, at(0, "03:00")))
+		assert.EqualError(t, checkIssuanceWindows([]string{"Mon-Fri 09:00-17:00"}, at(0, "03:00")),
+			"issuance denied: keys for this role can only be issued during Mon-Fri 09:00-17:00 (UTC), it is now Wed 03:00")
+	})
+}
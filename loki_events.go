@@ -0,0 +1,213 @@
+package secretsengine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	grafanclient "github.com/grafana/grafana-api-golang-client"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// lokiEventsKeyStoragePath is where the Cloud API key the events are pushed
+// to Loki with is kept. It is created with the first event.
+const lokiEventsKeyStoragePath = "loki-events-key"
+
+const (
+	// lokiEventsKeyName names the key, prefixed with token_prefix. It does not
+	// follow the naming of issued keys, so it is never counted or adopted as one.
+	lokiEventsKeyName = "vault-plugin-events"
+	// lokiEventsKeyRole is the least privileged role able to push logs. It
+	// can push metrics and traces as well, but cannot read or manage anything.
+	lokiEventsKeyRole = "MetricsPublisher"
+	// lokiEventsJob is the job label of the pushed log lines.
+	lokiEventsJob = "vault-plugin-secrets-grafanacloud"
+	lokiPushPath  = "/loki/api/v1/push"
+)
+
+// lokiEventsKey is the dedicated key the events are pushed to Loki with.
+type lokiEventsKey struct {
+	Name  string `json:"name"`
+	ID    int64  `json:"id"`
+	Token string `json:"token"`
+}
+
+// lokiPush is the body of a Loki push request.
+type lokiPush struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+func lokiEventsKeyNameFor(config *Config) string {
+	if config.TokenPrefix != "" {
+		return config.TokenPrefix + "_" + lokiEventsKeyName
+	}
+
+	return lokiEventsKeyName
+}
+
+// getLokiEventsKey returns the key the events are pushed to Loki with,
+// creating it in the organisation if there is none yet.
+func (b *grafanaCloudBackend) getLokiEventsKey(ctx context.Context, s logical.Storage, config *Config) (*lokiEventsKey, error) {
+	b.lokiEventsLock.Lock()
+	defer b.lokiEventsLock.Unlock()
+
+	entry, err := s.Get(ctx, lokiEventsKeyStoragePath)
+	if err != nil {
+		return nil, NewInternalError("error reading Loki events key", err)
+	}
+
+	if entry != nil {
+		key := new(lokiEventsKey)
+		if err := entry.DecodeJSON(key); err != nil {
+			return nil, NewInternalError("error decoding Loki events key", err)
+		}
+
+		return key, nil
+	}
+
+	c, err := b.getClient(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := c.CreateCloudAPIKey(config.Organisation, &grafanclient.CreateCloudAPIKeyInput{
+		Name: lokiEventsKeyNameFor(config),
+		Role: lokiEventsKeyRole,
+	})
+	if err != nil {
+		return nil, NewInternalError("error creating Loki events key", err)
+	}
+
+	key := &lokiEventsKey{Name: created.Name, ID: int64(created.ID), Token: created.Token}
+
+	entry, err = logical.StorageEntryJSON(lokiEventsKeyStoragePath, key)
+	if err == nil {
+		err = s.Put(ctx, entry)
+	}
+
+	if err != nil {
+		// Without the record the key would leak, a new one is created next time.
+		if deleteErr := c.DeleteCloudAPIKey(config.Organisation, key.Name); deleteErr != nil {
+			b.Logger().Warn("failed to delete unrecorded Loki events key", "name", key.Name, "error", deleteErr)
+		}
+
+		return nil, NewInternalError("error writing Loki events key", err)
+	}
+
+	b.Logger().Info("created Loki events key", "name", key.Name)
+
+	return key, nil
+}
+
+// deleteLokiEventsKey deletes the key the events are pushed to Loki with,
+// if it was created.
+func (b *grafanaCloudBackend) deleteLokiEventsKey(ctx context.Context, s logical.Storage, config *Config) error {
+	b.lokiEventsLock.Lock()
+	defer b.lokiEventsLock.Unlock()
+
+	entry, err := s.Get(ctx, lokiEventsKeyStoragePath)
+	if err != nil || entry == nil {
+		return err
+	}
+
+	key := new(lokiEventsKey)
+	if err := entry.DecodeJSON(key); err != nil {
+		return NewInternalError("error decoding Loki events key", err)
+	}
+
+	c, err := b.getClient(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	if err := c.DeleteCloudAPIKey(config.Organisation, key.Name); err != nil {
+		return NewInternalError("error deleting Loki events key", err)
+	}
+
+	return s.Delete(ctx, lokiEventsKeyStoragePath)
+}
+
+// shipEvent pushes the event to the Loki endpoint of the config when
+// events_to_loki is enabled. Like webhooks, delivery happens in the
+// background and failures are only logged. The key is resolved in the
+// background as well, as creating it is a request to Grafana Cloud.
+func (b *grafanaCloudBackend) shipEvent(ctx context.Context, s logical.Storage, config *Config, event *webhookEvent, line []byte) {
+	if !config.EventsToLoki || config.LokiURL == "" || config.LokiUser == "" {
+		return
+	}
+
+	httpClient, err := config.httpClient()
+	if err != nil {
+		b.Logger().Warn("failed to push event to Loki", "event", event.Type, "error", err)
+		return
+	}
+
+	body, err := json.Marshal(&lokiPush{Streams: []lokiStream{{
+		Stream: map[string]string{
+			"job":          lokiEventsJob,
+			"organisation": config.Organisation,
+			"event":        event.Type,
+		},
+		Values: [][2]string{{strconv.FormatInt(event.Time.UnixNano(), 10), string(line)}},
+	}}})
+	if err != nil {
+		b.Logger().Warn("failed to encode Loki push", "event", event.Type, "error", err)
+		return
+	}
+
+	pushURL, user := config.LokiURL+lokiPushPath, config.LokiUser
+	id := correlationID(ctx)
+
+	b.webhooks.Add(1)
+
+	go func() {
+		defer b.webhooks.Done()
+
+		// The request, and so its context, may be over by now.
+		keyCtx, cancel := context.WithTimeout(withCorrelationID(context.Background(), id), webhookTimeout)
+		defer cancel()
+
+		key, err := b.getLokiEventsKey(keyCtx, s, config)
+		if err != nil {
+			b.Logger().Warn("failed to get the key to push events to Loki", "event", event.Type, "error", err)
+			return
+		}
+
+		if err := pushLoki(httpClient, pushURL, user, key.Token, body); err != nil {
+			b.Logger().Warn("failed to push event to Loki", "event", event.Type, "error", err)
+		}
+	}()
+}
+
+func pushLoki(httpClient *http.Client, pushURL, user, token string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, pushURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(user, token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return NewInternalError(fmt.Sprintf("loki responded with status %d", resp.StatusCode), nil)
+	}
+
+	return nil
+}
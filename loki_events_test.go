@@ -0,0 +1,174 @@
+package secretsengine
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client/clienttest"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLokiEvents(t *testing.T) {
+	server := clienttest.NewServer(organisation, key)
+	defer server.Close()
+
+	type push struct {
+		path     string
+		user     string
+		password string
+		body     lokiPush
+	}
+
+	var (
+		mu     sync.Mutex
+		pushes []push
+	)
+
+	loki := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := push{path: r.URL.Path}
+		p.user, p.password, _ = r.BasicAuth()
+
+		if err := json.NewDecoder(r.Body).Decode(&p.body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		pushes = append(pushes, p)
+		mu.Unlock()
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer loki.Close()
+
+	b, s := getTestBackend(t)
+
+	lastPush := func(t *testing.T) (push, webhookEvent) {
+		t.Helper()
+
+		b.webhooks.Wait()
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		require.NotEmpty(t, pushes)
+
+		last := pushes[len(pushes)-1]
+		require.Len(t, last.body.Streams, 1)
+		require.Len(t, last.body.Streams[0].Values, 1)
+
+		var event webhookEvent
+		require.NoError(t, json.Unmarshal([]byte(last.body.Streams[0].Values[0][1]), &event))
+
+		return last, event
+	}
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          server.URL + "/api",
+		"organisation": organisation,
+		"token_prefix": "cluster-a",
+	}))
+
+	_, err := testTokenRoleCreate(t, b, s, "cloud-role", map[string]interface{}{
+		"gc_role": "Viewer",
+	})
+	require.NoError(t, err)
+
+	t.Run("Requires Loki endpoint", func(t *testing.T) {
+		err := testConfigUpdate(b, s, map[string]interface{}{"events_to_loki": true})
+		require.EqualError(t, err, "invalid configuration: events_to_loki requires loki_user and loki_url")
+	})
+
+	require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{
+		"events_to_loki": true,
+		"loki_user":      "2",
+		"loki_url":       loki.URL,
+	}))
+
+	var secret *logical.Secret
+
+	t.Run("Issued", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/cloud-role",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+
+		secret = resp.Secret
+
+		// The key is created with the first event, in the background.
+		b.webhooks.Wait()
+
+		eventsKey := server.CloudKey("cluster-a_vault-plugin-events")
+		require.NotNil(t, eventsKey)
+		assert.Equal(t, lokiEventsKeyRole, eventsKey.Role)
+
+		p, event := lastPush(t)
+		assert.Equal(t, lokiPushPath, p.path)
+		assert.Equal(t, "2", p.user)
+		assert.Equal(t, eventsKey.Token, p.password)
+		assert.Equal(t, map[string]string{
+			"job":          lokiEventsJob,
+			"organisation": organisation,
+			"event":        webhookEventIssued,
+		}, p.body.Streams[0].Stream)
+		assert.Equal(t, webhookEventIssued, event.Type)
+		assert.Equal(t, resp.Data["key_name"], event.Data["key_name"])
+	})
+
+	t.Run("Revoked", func(t *testing.T) {
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Storage:   s,
+			Secret:    secret,
+		})
+		require.NoError(t, err)
+
+		_, event := lastPush(t)
+		assert.Equal(t, webhookEventRevoked, event.Type)
+		assert.Equal(t, secret.InternalData["name"], event.Data["key_name"])
+
+		// The events key is reused and only the events key is left.
+		require.Len(t, server.CloudKeys(), 1)
+	})
+
+	t.Run("Loki endpoint cannot be cleared", func(t *testing.T) {
+		// A write replaces every endpoint, a patch can clear one.
+		for op, data := range map[logical.Operation]map[string]interface{}{
+			logical.UpdateOperation: {"loki_user": "2"},
+			logical.PatchOperation:  {"loki_user": ""},
+		} {
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Operation: op,
+				Path:      "config/endpoints",
+				Storage:   s,
+				Data:      data,
+			})
+			require.NoError(t, err)
+			require.EqualError(t, resp.Error(), "invalid configuration: events_to_loki requires loki_user and loki_url", op)
+		}
+
+		config, err := getConfig(context.Background(), s)
+		require.NoError(t, err)
+		assert.Equal(t, "2", config.LokiUser)
+		assert.Equal(t, loki.URL, config.LokiURL)
+	})
+
+	t.Run("Disabling deletes the key", func(t *testing.T) {
+		require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{"events_to_loki": false}))
+		assert.Nil(t, server.CloudKey("cluster-a_vault-plugin-events"))
+
+		entry, err := s.Get(context.Background(), lokiEventsKeyStoragePath)
+		require.NoError(t, err)
+		assert.Nil(t, entry)
+	})
+}
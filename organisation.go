@@ -0,0 +1,57 @@
+package secretsengine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client"
+)
+
+// detectOrganisation returns the slug of the organisation the admin key of
+// the config belongs to, for configs written without one. Grafana Cloud has
+// no endpoint returning the organisation of a key, so it is taken from the
+// stacks the key has access to, which must all belong to the same one.
+func detectOrganisation(ctx context.Context, config *Config) (string, error) {
+	httpClient, err := config.httpClient()
+	if err != nil {
+		return "", err
+	}
+
+	c, err := client.New(config.URL, config.Key, httpClient, correlationOptions(ctx)...)
+	if err != nil {
+		return "", err
+	}
+
+	stacks, err := c.Stacks()
+	if err != nil {
+		return "", NewInvalidConfigurationError(fmt.Sprintf("missing organisation, it could not be detected from the key: %s",
+			err), err)
+	}
+
+	slugs := map[string]bool{}
+
+	for _, stack := range stacks.Items {
+		if stack.OrgSlug != "" {
+			slugs[stack.OrgSlug] = true
+		}
+	}
+
+	organisations := make([]string, 0, len(slugs))
+	for slug := range slugs {
+		organisations = append(organisations, slug)
+	}
+
+	sort.Strings(organisations)
+
+	switch len(organisations) {
+	case 1:
+		return organisations[0], nil
+	case 0:
+		return "", NewInvalidConfigurationError("missing organisation, it could not be detected as the key has access to no stack", nil)
+	}
+
+	return "", NewInvalidConfigurationError(fmt.Sprintf("missing organisation, the key has access to stacks of several organisations: %s",
+		strings.Join(organisations, ", ")), nil)
+}
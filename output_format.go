@@ -0,0 +1,42 @@
+package secretsengine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// outputFormatJSON returns the token, users and URLs as separate fields.
+	outputFormatJSON = "json"
+	// outputFormatEnv returns them as lines of an env file in the env field.
+	outputFormatEnv = "env"
+
+	envVarPrefix = "GRAFANA_CLOUD_"
+)
+
+// envResponseData replaces the fields of an issued key with an env field
+// holding one KEY='value' line per field, sorted by name, which can be
+// sourced by a shell or read as a dotenv file. key_name and key_id are kept
+// so that the lease can still be traced. The deprecated user field is left
+// out as it repeats prometheus_user.
+func envResponseData(data map[string]interface{}) map[string]interface{} {
+	lines := make([]string, 0, len(data))
+
+	for field, value := range data {
+		if field == "user" {
+			continue
+		}
+
+		quoted := strings.ReplaceAll(fmt.Sprint(value), "'", `'\''`)
+		lines = append(lines, fmt.Sprintf("%s%s='%s'", envVarPrefix, strings.ToUpper(field), quoted))
+	}
+
+	sort.Strings(lines)
+
+	return map[string]interface{}{
+		"env":      strings.Join(lines, "\n") + "\n",
+		"key_name": data["key_name"],
+		"key_id":   data["key_id"],
+	}
+}
@@ -0,0 +1,181 @@
+package secretsengine
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// pathAdopt extends the Vault API with an `/adopt`
+// endpoint for a role, which puts a key created
+// outside Vault under a lease of the role.
+func pathAdopt(b *grafanaCloudBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "adopt/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Name of the role whose TTLs apply to the lease",
+				Required:    true,
+			},
+			"key_name": {
+				Type:        framework.TypeString,
+				Description: "Name of the Cloud API key in the organisation to adopt",
+				Required:    true,
+			},
+			"force": {
+				Type:        framework.TypeBool,
+				Description: "Adopt the key even though its Cloud role differs from the gc_role of the role",
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathAdoptWrite,
+				Summary:  "Put an existing Cloud API key under a lease of a role.",
+			},
+		},
+		HelpSynopsis:    pathAdoptHelpSyn,
+		HelpDescription: pathAdoptHelpDesc,
+	}
+}
+
+const pathAdoptHelpSyn = `
+Put a Cloud API key created outside Vault under a Vault lease.
+`
+
+const pathAdoptHelpDesc = `
+This path looks up the named Cloud API key in the organisation and returns a
+lease for it with the TTLs of the role, so that a key created by hand, e.g. a
+break-glass key, is deleted when the lease expires or is revoked. The token
+of the key is not known to the plugin and is not returned. Keys already under
+a lease, keys named like those the plugin issues and the key of
+events_to_loki cannot be adopted. Keys whose Cloud role differs from
+the gc_role of the role are refused unless force is set, as revoking the
+lease of a key the mount depends on, such as its admin key, breaks it.
+`
+
+func (b *grafanaCloudBackend) pathAdoptWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	roleName := d.Get("name").(string)
+
+	keyName := d.Get("key_name").(string)
+	if keyName == "" {
+		return logical.ErrorResponse("missing key_name"), nil
+	}
+
+	role, err := b.getRole(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, NewInternalError("error retrieving role", err)
+	}
+
+	if role == nil {
+		return roleNotFoundResponse(roleName)
+	}
+
+	config, err := getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, NewInternalError("error reading secrets engine configuration", err)
+	}
+
+	if config == nil {
+		return logical.ErrorResponse("backend not configured"), nil
+	}
+
+	if err := checkMaintenanceMode(config); err != nil {
+		return errorResponse(err)
+	}
+
+	existing, err := getIssuanceRecord(ctx, req.Storage, keyName)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing != nil {
+		return logical.ErrorResponse(fmt.Sprintf("key %q is already under a lease of role %q", keyName, existing.Role)), nil
+	}
+
+	if keyName == lokiEventsKeyNameFor(config) {
+		return logical.ErrorResponse(fmt.Sprintf("key %q is the key of events_to_loki and cannot be adopted", keyName)), nil
+	}
+
+	// Their leases, if any, already delete them.
+	if isPluginKeyName(config, keyName) {
+		return logical.ErrorResponse(fmt.Sprintf("key %q is named like the keys the plugin issues and cannot be adopted", keyName)), nil
+	}
+
+	c, err := b.getClient(ctx, req.Storage)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	start := time.Now()
+	keys, err := c.ListCloudAPIKeys(config.Organisation)
+	b.observeLatency(apiOpListKeys, start)
+
+	if err != nil {
+		return errorResponse(NewInternalError("error listing Grafana Cloud keys", err))
+	}
+
+	var key *GrafanaCloudKey
+
+	var warnings []string
+
+	for _, k := range keys.Items {
+		if k.Name != keyName {
+			continue
+		}
+
+		key = &GrafanaCloudKey{
+			Name: k.Name,
+			ID:   int64(k.ID),
+		}
+
+		if k.Role != role.GrafanaCloudRole {
+			mismatch := fmt.Sprintf("the key has the %s role, role %q issues %s keys", k.Role, roleName, role.GrafanaCloudRole)
+			if !d.Get("force").(bool) {
+				return logical.ErrorResponse(mismatch + ", set force=true to adopt it anyway"), nil
+			}
+
+			warnings = append(warnings, mismatch)
+		}
+	}
+
+	if key == nil {
+		return logical.ErrorResponse(fmt.Sprintf("no Cloud API key named %q in organisation %s", keyName, config.Organisation)), nil
+	}
+
+	_, maxTTL := role.effectiveTTLs(b.System())
+	adoptedAt := time.Now().UTC()
+	expiresAt := adoptedAt.Add(maxTTL)
+
+	// Without the record the key could be adopted a second time.
+	if err := putIssuanceRecord(ctx, req.Storage, &issuanceRecord{
+		Name:        key.Name,
+		Role:        roleName,
+		IssuedAt:    adoptedAt,
+		ExpiresAt:   &expiresAt,
+		EntityID:    req.EntityID,
+		DisplayName: req.DisplayName,
+		Adopted:     true,
+	}); err != nil {
+		return nil, err
+	}
+
+	b.Logger().Info("adopted key", "name", key.Name, "role", roleName)
+
+	resp := b.Secret(grafanaCloudKeyType).Response(map[string]interface{}{
+		"key_name": key.Name,
+		"key_id":   key.ID,
+		"adopted":  true,
+	}, key.secretData(roleName).internalData())
+
+	for _, warning := range warnings {
+		resp.AddWarning(warning)
+	}
+
+	role.setLease(resp.Secret)
+
+	return resp, nil
+}
@@ -0,0 +1,124 @@
+package secretsengine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client"
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client/clienttest"
+	grafanclient "github.com/grafana/grafana-api-golang-client"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdopt(t *testing.T) {
+	server := clienttest.NewServer(organisation, key)
+	defer server.Close()
+
+	b, s := getTestBackend(t)
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          server.URL + "/api",
+		"organisation": organisation,
+	}))
+
+	_, err := testTokenRoleCreate(t, b, s, "break-glass", map[string]interface{}{
+		"gc_role": "Admin",
+		"ttl":     "1h",
+		"max_ttl": "4h",
+	})
+	require.NoError(t, err)
+
+	// Created by hand, outside Vault.
+	c, err := client.New(server.URL, key, nil)
+	require.NoError(t, err)
+
+	external, err := c.CreateCloudAPIKey(organisation, &grafanclient.CreateCloudAPIKeyInput{Name: "manual-admin", Role: "Admin"})
+	require.NoError(t, err)
+
+	adoptWith := func(t *testing.T, role string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "adopt/" + role,
+			Data:      data,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+
+		return resp
+	}
+
+	adopt := func(t *testing.T, role, keyName string) *logical.Response {
+		t.Helper()
+
+		return adoptWith(t, role, map[string]interface{}{"key_name": keyName})
+	}
+
+	var lease *logical.Secret
+
+	t.Run("Adopt", func(t *testing.T) {
+		resp := adopt(t, "break-glass", "manual-admin")
+		require.False(t, resp.IsError())
+		require.Empty(t, resp.Warnings)
+		require.Equal(t, "manual-admin", resp.Data["key_name"])
+		require.Equal(t, int64(external.ID), resp.Data["key_id"])
+		require.NotContains(t, resp.Data, "token")
+
+		require.NotNil(t, resp.Secret)
+		require.Equal(t, "1h0m0s", resp.Secret.TTL.String())
+		require.Equal(t, "4h0m0s", resp.Secret.MaxTTL.String())
+
+		record, err := getIssuanceRecord(context.Background(), s, "manual-admin")
+		require.NoError(t, err)
+		require.True(t, record.Adopted)
+		require.Equal(t, "break-glass", record.Role)
+
+		lease = resp.Secret
+	})
+
+	t.Run("Already adopted", func(t *testing.T) {
+		resp := adopt(t, "break-glass", "manual-admin")
+		require.EqualError(t, resp.Error(), `key "manual-admin" is already under a lease of role "break-glass"`)
+	})
+
+	t.Run("Unknown key", func(t *testing.T) {
+		resp := adopt(t, "break-glass", "missing")
+		require.EqualError(t, resp.Error(), `no Cloud API key named "missing" in organisation `+organisation)
+	})
+
+	t.Run("Revoking the lease deletes the key", func(t *testing.T) {
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Storage:   s,
+			Secret:    lease,
+		})
+		require.NoError(t, err)
+		require.Nil(t, server.CloudKey("manual-admin"))
+	})
+
+	t.Run("Role mismatch requires force", func(t *testing.T) {
+		_, err := c.CreateCloudAPIKey(organisation, &grafanclient.CreateCloudAPIKeyInput{Name: "manual-viewer", Role: "Viewer"})
+		require.NoError(t, err)
+
+		resp := adopt(t, "break-glass", "manual-viewer")
+		require.EqualError(t, resp.Error(), `the key has the Viewer role, role "break-glass" issues Admin keys, set force=true to adopt it anyway`)
+
+		resp = adoptWith(t, "break-glass", map[string]interface{}{"key_name": "manual-viewer", "force": true})
+		require.False(t, resp.IsError())
+		require.Equal(t, []string{`the key has the Viewer role, role "break-glass" issues Admin keys`}, resp.Warnings)
+	})
+
+	t.Run("Keys of the plugin", func(t *testing.T) {
+		for _, name := range []string{lokiEventsKeyName, "break-glass_0b9f4a3c-6f2e-4a7d-9c1b-2d3e4f5a6b7c"} {
+			_, err := c.CreateCloudAPIKey(organisation, &grafanclient.CreateCloudAPIKeyInput{Name: name, Role: "Admin"})
+			require.NoError(t, err)
+
+			resp := adoptWith(t, "break-glass", map[string]interface{}{"key_name": name, "force": true})
+			require.True(t, resp.IsError(), name)
+			require.NotNil(t, server.CloudKey(name))
+		}
+	})
+}
@@ -0,0 +1,157 @@
+package secretsengine
+
+import (
+	"context"
+	"crypto/hmac"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// pathAuditExport extends the Vault API with an `/audit/export`
+// endpoint returning the issuance history.
+func pathAuditExport(b *grafanaCloudBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "audit/export/?$",
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathAuditExportRead,
+				Summary:  "Export the issuance history.",
+			},
+		},
+		HelpSynopsis:    pathAuditExportHelpSyn,
+		HelpDescription: pathAuditExportHelpDesc,
+	}
+}
+
+const pathAuditExportHelpSyn = `
+Export the record of every key issued by the plugin.
+`
+
+const pathAuditExportHelpDesc = `
+This path returns the issuance records of the keys with an outstanding lease
+and of the keys revoked in the last 7 days, oldest first, for ingestion into
+SIEM tooling. Each record holds the role, the key name, the entity and
+display name of the requester, the HMAC of the token, when the key was
+issued and, once revoked, when it was revoked.
+`
+
+// pathAuditLookup extends the Vault API with an `/audit/lookup`
+// endpoint matching a leaked token to its issuance record.
+func pathAuditLookup(b *grafanaCloudBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "audit/lookup/?$",
+		Fields: map[string]*framework.FieldSchema{
+			"token": {
+				Type:        framework.TypeString,
+				Description: "Token to look up, e.g. one found in a leak",
+				Required:    true,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:      "Token",
+					Sensitive: true,
+				},
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathAuditLookupWrite,
+				Summary:  "Find the issuance record of a token.",
+			},
+		},
+		HelpSynopsis:    pathAuditLookupHelpSyn,
+		HelpDescription: pathAuditLookupHelpDesc,
+	}
+}
+
+const pathAuditLookupHelpSyn = `
+Find the issuance record of a token.
+`
+
+const pathAuditLookupHelpDesc = `
+The plugin never stores issued tokens, only their HMAC keyed with a secret
+of the mount. This path computes the HMAC of the given token and returns the
+matching record of the issuance history, identifying the role, the key and
+the requester a leaked token was issued to.
+`
+
+func (b *grafanaCloudBackend) pathAuditExportRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	records, err := issuanceHistory(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].IssuedAt.Before(records[j].IssuedAt)
+	})
+
+	exported := make([]map[string]interface{}, 0, len(records))
+
+	for _, record := range records {
+		exported = append(exported, record.toResponseData())
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"records": exported,
+		},
+	}, nil
+}
+
+func (b *grafanaCloudBackend) pathAuditLookupWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	token := d.Get("token").(string)
+	if token == "" {
+		return logical.ErrorResponse("missing token"), nil
+	}
+
+	hmacKey, err := b.getTokenHMACKey(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := issuanceHistory(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	want := tokenHMAC(hmacKey, token)
+
+	for _, record := range records {
+		if record.TokenHMAC != "" && hmac.Equal([]byte(record.TokenHMAC), []byte(want)) {
+			return &logical.Response{Data: record.toResponseData()}, nil
+		}
+	}
+
+	return logical.ErrorResponse("the token was not issued by this mount, or its record is older than the issuance history"), nil
+}
+
+// toResponseData returns the record as exported by the audit paths.
+func (r *issuanceRecord) toResponseData() map[string]interface{} {
+	data := map[string]interface{}{
+		"key_name":     r.Name,
+		"role":         r.Role,
+		"entity_id":    r.EntityID,
+		"display_name": r.DisplayName,
+		"issued_at":    r.IssuedAt.Format(time.RFC3339),
+		"revoked":      r.RevokedAt != nil,
+	}
+
+	if r.Adopted {
+		data["adopted"] = true
+	}
+
+	if r.ExpiresAt != nil {
+		data["expires_at"] = r.ExpiresAt.Format(time.RFC3339)
+	}
+
+	if r.TokenHMAC != "" {
+		data["token_hmac"] = r.TokenHMAC
+	}
+
+	if r.RevokedAt != nil {
+		data["revoked_at"] = r.RevokedAt.Format(time.RFC3339)
+	}
+
+	return data
+}
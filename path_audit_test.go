@@ -0,0 +1,139 @@
+package secretsengine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client/clienttest"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditExport(t *testing.T) {
+	server := clienttest.NewServer(organisation, key)
+	defer server.Close()
+
+	b, s := getTestBackend(t)
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          server.URL + "/api",
+		"organisation": organisation,
+	}))
+
+	_, err := testTokenRoleCreate(t, b, s, "metrics_publisher", map[string]interface{}{
+		"gc_role": "MetricsPublisher",
+	})
+	require.NoError(t, err)
+
+	issue := func(t *testing.T, entityID, displayName string) *logical.Response {
+		t.Helper()
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation:   logical.ReadOperation,
+			Path:        "creds/metrics_publisher",
+			Storage:     s,
+			EntityID:    entityID,
+			DisplayName: displayName,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+
+		return resp
+	}
+
+	revoked := issue(t, "entity-a", "approle-ci")
+	outstanding := issue(t, "entity-b", "token-ops")
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   s,
+		Secret:    revoked.Secret,
+	})
+	require.NoError(t, err)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "audit/export",
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	require.False(t, resp.IsError())
+
+	records := resp.Data["records"].([]map[string]interface{})
+	require.Len(t, records, 2)
+
+	byName := map[string]map[string]interface{}{}
+	for _, record := range records {
+		byName[record["key_name"].(string)] = record
+	}
+
+	first := byName[revoked.Data["key_name"].(string)]
+	require.NotNil(t, first)
+	assert.Equal(t, "metrics_publisher", first["role"])
+	assert.Equal(t, "entity-a", first["entity_id"])
+	assert.Equal(t, "approle-ci", first["display_name"])
+	assert.Equal(t, true, first["revoked"])
+	assert.NotEmpty(t, first["issued_at"])
+	assert.NotEmpty(t, first["revoked_at"])
+
+	second := byName[outstanding.Data["key_name"].(string)]
+	require.NotNil(t, second)
+	assert.Equal(t, "entity-b", second["entity_id"])
+	assert.Equal(t, "token-ops", second["display_name"])
+	assert.Equal(t, false, second["revoked"])
+	assert.NotContains(t, second, "revoked_at")
+
+	for _, record := range records {
+		assert.Len(t, record["token_hmac"], 64)
+		assert.NotEqual(t, revoked.Data["token"], record["token_hmac"])
+	}
+
+	lookup := func(t *testing.T, token string) *logical.Response {
+		t.Helper()
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "audit/lookup",
+			Data:      map[string]interface{}{"token": token},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+
+		return resp
+	}
+
+	t.Run("Lookup", func(t *testing.T) {
+		resp := lookup(t, revoked.Data["token"].(string))
+		require.False(t, resp.IsError())
+		assert.Equal(t, revoked.Data["key_name"], resp.Data["key_name"])
+		assert.Equal(t, "entity-a", resp.Data["entity_id"])
+		assert.Equal(t, true, resp.Data["revoked"])
+
+		resp = lookup(t, outstanding.Data["token"].(string))
+		require.False(t, resp.IsError())
+		assert.Equal(t, outstanding.Data["key_name"], resp.Data["key_name"])
+	})
+
+	t.Run("Lookup - unknown token", func(t *testing.T) {
+		resp := lookup(t, "glc_unknown")
+		require.True(t, resp.IsError())
+		require.EqualError(t, resp.Error(), "the token was not issued by this mount, or its record is older than the issuance history")
+	})
+
+	t.Run("Lookup - the HMAC key outlives the backend", func(t *testing.T) {
+		b2 := backend()
+		require.NoError(t, b2.Setup(context.Background(), logical.TestBackendConfig()))
+
+		resp, err := b2.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "audit/lookup",
+			Data:      map[string]interface{}{"token": outstanding.Data["token"]},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+		assert.Equal(t, outstanding.Data["key_name"], resp.Data["key_name"])
+	})
+}
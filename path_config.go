@@ -2,7 +2,11 @@ package secretsengine
 
 import (
 	"context"
+	"fmt"
 	"net/url"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -12,24 +16,142 @@ const (
 	configStoragePath = "config"
 )
 
-// grafanaConfig includes the minimum configuration required to instantiate a new GrafanaCloud client.
-type grafanaCloudConfig struct {
-	Organisation     string `json:"organisation"`
-	Key              string `json:"key"`
-	URL              string `json:"url"`
-	User             string `json:"user"`
-	PrometheusUser   string `json:"prometheus_user"`
-	PrometheusURL    string `json:"prometheus_url"`
-	LokiUser         string `json:"loki_user"`
-	LokiURL          string `json:"loki_url"`
-	TempoUser        string `json:"tempo_user"`
-	TempoURL         string `json:"tempo_url"`
-	AlertmanagerUser string `json:"alertmanager_user"`
-	AlertmanagerURL  string `json:"alertmanager_url"`
-	GraphiteUser     string `json:"graphite_user"`
-	GraphiteURL      string `json:"graphite_url"`
+// Config includes the minimum configuration required to instantiate a new GrafanaCloud client.
+// It is stored at the config path and is exported so that embedding plugins can inspect it.
+type Config struct {
+	Organisation string `json:"organisation"`
+	Key          string `json:"key"`
+	URL          string `json:"url"`
+	// SecondaryKey is used when Grafana Cloud rejects Key, to rotate the
+	// admin key without an issuance outage.
+	SecondaryKey string `json:"secondary_key,omitempty"`
+	// FallbackURL is used when URL keeps failing, see urlFailoverTransport.
+	FallbackURL string `json:"fallback_url,omitempty"`
+	// KeyExpiresAt is when the admin key expires, if it does.
+	KeyExpiresAt *time.Time `json:"key_expires_at,omitempty"`
+	// MaxOutstandingKeys caps the number of keys with an outstanding lease
+	// issued by the mount, zero means no limit.
+	MaxOutstandingKeys int `json:"max_outstanding_keys"`
+	// IssuanceRateWarn and IssuanceRateDeny are the number of keys a role
+	// may issue within IssuanceRateWindow before issuance is warned about
+	// or refused, zero disables them.
+	IssuanceRateWindow time.Duration `json:"issuance_rate_window"`
+	IssuanceRateWarn   int           `json:"issuance_rate_warn"`
+	IssuanceRateDeny   int           `json:"issuance_rate_deny"`
+	// TokenPrefix is prepended to the names of the keys issued by the mount.
+	TokenPrefix string `json:"token_prefix"`
+	// Environment is appended to the names of the keys issued by the mount.
+	Environment string `json:"environment,omitempty"`
+	// TLSMinVersion and TLSCipherSuites restrict the TLS connections made
+	// to Grafana Cloud, the Go defaults are used when unset.
+	TLSMinVersion   string   `json:"tls_min_version,omitempty"`
+	TLSCipherSuites []string `json:"tls_cipher_suites,omitempty"`
+	// CACertificate is a PEM bundle trusted in addition to the system roots,
+	// managed through the `/config/ca` path.
+	CACertificate string `json:"ca_certificate,omitempty"`
+	// StackCacheTTL is how long stack metadata is cached, see stackCacheTTL.
+	StackCacheTTL *time.Duration `json:"stack_cache_ttl,omitempty"`
+	// VerifyRevocation checks that revoked keys are gone, see verifyDeletion.
+	VerifyRevocation bool `json:"verify_revocation,omitempty"`
+	// WebhookURL receives credential lifecycle events signed with
+	// WebhookSecret, see notify.
+	WebhookURL    string `json:"webhook_url,omitempty"`
+	WebhookSecret string `json:"webhook_secret,omitempty"`
+	// MaintenanceMode refuses to issue keys, see checkMaintenanceMode.
+	MaintenanceMode bool `json:"maintenance_mode,omitempty"`
+	// SealWrapIssuanceRecords keeps the issuance records under
+	// sealWrappedIssuancePrefix.
+	SealWrapIssuanceRecords bool `json:"seal_wrap_issuance_records,omitempty"`
+	// DebugPaths serves the debug/ paths, see pathDebugClient.
+	DebugPaths bool `json:"debug_paths,omitempty"`
+	// EventsToLoki pushes the same events to the Loki endpoint, see shipEvent.
+	EventsToLoki bool `json:"events_to_loki,omitempty"`
+	// ValidGCRoles replaces, and AdditionalGCRoles extends, the built-in
+	// gc_role values accepted for roles.
+	ValidGCRoles      []string `json:"valid_gc_roles,omitempty"`
+	AdditionalGCRoles []string `json:"additional_gc_roles,omitempty"`
+	// Regions maps region slugs to the base URL of their regional API.
+	Regions map[string]string `json:"regions,omitempty"`
+	// ProtectedKeyPrefixes name the keys revoke-org-keys never deletes.
+	ProtectedKeyPrefixes []string `json:"protected_key_prefixes,omitempty"`
+	Endpoints
+
+	// legacyUserPending is set by getConfig when the stored config still
+	// has the deprecated user field, which it migrates in memory.
+	legacyUserPending bool
 }
 
+// cloudRoles returns the gc_role values accepted for Cloud API key roles.
+func (c *Config) cloudRoles() map[string]bool {
+	if c == nil || (len(c.ValidGCRoles) == 0 && len(c.AdditionalGCRoles) == 0) {
+		return grafanaCloudValidRoles
+	}
+
+	roles := map[string]bool{}
+
+	if len(c.ValidGCRoles) > 0 {
+		for _, role := range c.ValidGCRoles {
+			roles[role] = true
+		}
+	} else {
+		for role := range grafanaCloudValidRoles {
+			roles[role] = true
+		}
+	}
+
+	for _, role := range c.AdditionalGCRoles {
+		roles[role] = true
+	}
+
+	return roles
+}
+
+// nonEmptyStrings returns the values with surrounding whitespace removed,
+// dropping empty ones.
+func nonEmptyStrings(values []string) []string {
+	out := make([]string, 0, len(values))
+
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			out = append(out, value)
+		}
+	}
+
+	return out
+}
+
+// regionURL returns the API URL for the region,
+// falling back to the global API URL.
+func (c *Config) regionURL(region string) string {
+	if regionURL, ok := c.Regions[region]; ok {
+		return regionURL
+	}
+
+	return c.URL
+}
+
+// validate checks the settings that depend on each other, so every path
+// writing the config refuses to store an inconsistent one.
+func (c *Config) validate() error {
+	if c.EventsToLoki && (c.LokiUser == "" || c.LokiURL == "") {
+		return NewInvalidConfigurationError("events_to_loki requires loki_user and loki_url", nil)
+	}
+
+	return nil
+}
+
+// issuanceRateWindow returns the window issuance rates are measured over.
+func (c *Config) issuanceRateWindow() time.Duration {
+	if c.IssuanceRateWindow > 0 {
+		return c.IssuanceRateWindow
+	}
+
+	return defaultIssuanceRateWindow
+}
+
+//nolint:gochecknoglobals // compiled once, used to validate token_prefix.
+var tokenPrefixRegex = regexp.MustCompile(`^[A-Za-z0-9._-]*$`)
+
 // pathConfig extends the Vault API with a `/config`
 // endpoint for the backend. You can choose whether
 // or not certain attributes should be displayed,
@@ -37,150 +159,244 @@ type grafanaCloudConfig struct {
 // is marked as sensitive and will not be output
 // when you read the configuration.
 func pathConfig(b *grafanaCloudBackend) *framework.Path {
-	return &framework.Path{
-		Pattern: "config",
-		Fields: map[string]*framework.FieldSchema{
-			"key": {
-				Type:        framework.TypeString,
-				Description: "API key with Admin role to create user keys",
-				Required:    true,
-				DisplayAttrs: &framework.DisplayAttributes{
-					Name:      "Admin Key",
-					Sensitive: true,
-				},
+	fields := map[string]*framework.FieldSchema{
+		"key": {
+			Type:        framework.TypeString,
+			Description: "API key with Admin role to create user keys",
+			Required:    true,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Admin Key",
+				Sensitive: true,
+			},
+		},
+		"organisation": {
+			Type:        framework.TypeString,
+			Description: "The Organisation slug for the Grafana Cloud API. Detected from the stacks the key has access to when not set",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Organisation",
+				Sensitive: false,
+			},
+		},
+		"url": {
+			Type:        framework.TypeString,
+			Description: "The URL for the Grafana Cloud API",
+			Required:    true,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "URL",
+				Sensitive: false,
+			},
+		},
+		"fallback_url": {
+			Type: framework.TypeString,
+			Description: "URL of the Grafana Cloud API, such as a regional mirror or internal gateway, used after" +
+				" repeated failures of url. url is tried again after 5 minutes",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Fallback URL",
+			},
+		},
+		"secondary_key": {
+			Type:        framework.TypeString,
+			Description: "Admin API key used when Grafana Cloud rejects key, to rotate the admin key without an issuance outage",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Secondary Admin Key",
+				Sensitive: true,
+			},
+		},
+		"key_expires_at": {
+			Type: framework.TypeString,
+			Description: "When the admin key expires, as an RFC 3339 timestamp. Warnings are returned and logged from 14 days before." +
+				" Cleared when a new key is written without it",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Admin Key Expiry",
+			},
+		},
+		"token_prefix": {
+			Type: framework.TypeString,
+			Description: "Prefix prepended to the names of all issued keys, e.g. the name of the Vault cluster," +
+				" so the keys of different clusters sharing an organisation can be told apart",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Token Name Prefix",
+			},
+		},
+		"environment": {
+			Type:        framework.TypeString,
+			Description: "Label of the Vault environment, e.g. 'prod-eu', appended to the names of all issued keys",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Environment",
+			},
+		},
+		"tls_min_version": {
+			Type:        framework.TypeString,
+			Description: "Minimum TLS version for connections to Grafana Cloud, tls12 or tls13",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "TLS Minimum Version",
+			},
+		},
+		"tls_cipher_suites": {
+			Type: framework.TypeCommaStringSlice,
+			Description: "Cipher suites allowed for connections to Grafana Cloud, by their IANA name," +
+				" e.g. TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384. Only applies to TLS 1.2, TLS 1.3 suites are not configurable",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "TLS Cipher Suites",
+			},
+		},
+		"stack_cache_ttl": {
+			Type:        framework.TypeDurationSecond,
+			Description: "How long the metadata of stacks looked up by slug is cached. Defaults to 5m, 0 disables the cache",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Stack Cache TTL",
+			},
+		},
+		"verify_revocation": {
+			Type: framework.TypeBool,
+			Description: "After deleting a revoked key, check that it no longer exists and delete it again if it does." +
+				" Costs an extra request per revocation",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Verify Revocation",
 			},
-			"organisation": {
-				Type:        framework.TypeString,
-				Description: "The Organisation slug for the Grafana Cloud API",
-				Required:    true,
-				DisplayAttrs: &framework.DisplayAttributes{
-					Name:      "Organisation",
-					Sensitive: false,
-				},
+		},
+		"webhook_url": {
+			Type: framework.TypeString,
+			Description: "URL receiving a JSON event when a key is issued, when a key fails to be revoked and when" +
+				" the admin key is rotated. Events are signed with webhook_secret",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Webhook URL",
 			},
-			"url": {
-				Type:        framework.TypeString,
-				Description: "The URL for the Grafana Cloud API",
-				Required:    true,
-				DisplayAttrs: &framework.DisplayAttributes{
-					Name:      "URL",
-					Sensitive: false,
-				},
+		},
+		"webhook_secret": {
+			Type:        framework.TypeString,
+			Description: "Secret the HMAC-SHA256 signature of the events sent to webhook_url is keyed with",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Webhook Secret",
+				Sensitive: true,
 			},
-			"user": {
-				Type:        framework.TypeString,
-				Description: "(Deprecated) The User that is needed to interact with prometheus, if set this is returned alongside every issued credential",
-				Required:    false,
-				Deprecated:  true,
-				DisplayAttrs: &framework.DisplayAttributes{
-					Name:      "User",
-					Sensitive: true,
-				},
+		},
+		"maintenance_mode": {
+			Type: framework.TypeBool,
+			Description: "Refuse to issue keys on all creds paths, e.g. during an incident or a migration of the organisation." +
+				" Leases are still renewed and revoked",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Maintenance Mode",
 			},
-			"prometheus_user": {
-				Type: framework.TypeString,
-				Description: "The User that is needed to interact with prometheus, if set this is returned alongside every issued credential." +
-					" This will also set 'user' for backwards compatibility",
-				Required: false,
-				DisplayAttrs: &framework.DisplayAttributes{
-					Name:      "Prometheus User",
-					Sensitive: true,
-				},
+		},
+		"seal_wrap_issuance_records": {
+			Type: framework.TypeBool,
+			Description: "Seal wrap the issuance records, like the config and roles, on Vault versions and seals supporting it." +
+				" Existing records are moved when it is written",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Seal Wrap Issuance Records",
 			},
-			"prometheus_url": {
-				Type:        framework.TypeString,
-				Description: "The URL at which Prometheus can be accessed, if set this is returned alongside every issued credential",
-				Required:    false,
-				DisplayAttrs: &framework.DisplayAttributes{
-					Name:      "Prometheus URL",
-					Sensitive: true,
-				},
+		},
+		"debug_paths": {
+			Type:        framework.TypeBool,
+			Description: "Serve debug/client, which describes the cached Grafana Cloud clients and stacks to troubleshoot stale clients",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Debug Paths",
 			},
-			"loki_user": {
-				Type:        framework.TypeString,
-				Description: "The User that is needed to interact with loki, if set this is returned alongside every issued credential",
-				Required:    false,
-				DisplayAttrs: &framework.DisplayAttributes{
-					Name:      "Loki User",
-					Sensitive: true,
-				},
+		},
+		"events_to_loki": {
+			Type: framework.TypeBool,
+			Description: "Push the events sent to webhook_url as log lines to loki_url, with a Cloud API key the plugin" +
+				" creates for it, so that the activity of the mount shows in Grafana. Requires loki_user and loki_url",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Events to Loki",
 			},
-			"loki_url": {
-				Type:        framework.TypeString,
-				Description: "The URL at which Loki can be accessed, if set this is returned alongside every issued credential",
-				Required:    false,
-				DisplayAttrs: &framework.DisplayAttributes{
-					Name:      "Loki URL",
-					Sensitive: true,
-				},
+		},
+		"valid_gc_roles": {
+			Type: framework.TypeCommaStringSlice,
+			Description: "gc_role values accepted for roles, replacing the built-in" +
+				" Viewer, Admin, Editor, MetricsPublisher and PluginPublisher",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Valid Grafana Cloud Roles",
 			},
-			"tempo_user": {
-				Type:        framework.TypeString,
-				Description: "The User that is needed to interact with tempo, if set this is returned alongside every issued credential",
-				Required:    false,
-				DisplayAttrs: &framework.DisplayAttributes{
-					Name:      "Tempo User",
-					Sensitive: true,
-				},
+		},
+		"additional_gc_roles": {
+			Type: framework.TypeCommaStringSlice,
+			Description: "gc_role values accepted for roles in addition to the valid ones," +
+				" for roles added to Grafana Cloud since the plugin was released",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Additional Grafana Cloud Roles",
 			},
-			"tempo_url": {
-				Type:        framework.TypeString,
-				Description: "The URL at which Tempo can be accessed, if set this is returned alongside every issued credential",
-				Required:    false,
-				DisplayAttrs: &framework.DisplayAttributes{
-					Name:      "Tempo URL",
-					Sensitive: true,
-				},
+		},
+		"protected_key_prefixes": {
+			Type: framework.TypeCommaStringSlice,
+			Description: "Key name prefixes of keys managed outside the mount, e.g. by hand or by other tools," +
+				" which revoke-org-keys never deletes even when they are named like the keys of the plugin",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Protected Key Prefixes",
 			},
-			"alertmanager_user": {
-				Type:        framework.TypeString,
-				Description: "The User that is needed to interact with alertmanager, if set this is returned alongside every issued credential",
-				Required:    false,
-				DisplayAttrs: &framework.DisplayAttributes{
-					Name:      "Alertmanager User",
-					Sensitive: true,
-				},
+		},
+		"regions": {
+			Type: framework.TypeKVPairs,
+			Description: "Map of Grafana Cloud region slugs (e.g. 'prod-eu-west-0') to the base URL of their regional API," +
+				" used for region-scoped operations. Regions without an entry use url",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Regional API URLs",
 			},
-			"alertmanager_url": {
-				Type:        framework.TypeString,
-				Description: "The URL at which Alertmanager can be accessed, if set this is returned alongside every issued credential",
-				Required:    false,
-				DisplayAttrs: &framework.DisplayAttributes{
-					Name:      "Alertmanager URL",
-					Sensitive: true,
-				},
+		},
+		"max_outstanding_keys": {
+			Type: framework.TypeInt,
+			Description: "Maximum number of keys with an outstanding lease across all roles of the mount, further issuance is refused" +
+				" until some are revoked. 0 means no limit",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Max Outstanding Keys",
 			},
-			"graphite_user": {
-				Type:        framework.TypeString,
-				Description: "The User that is needed to interact with graphite, if set this is returned alongside every issued credential",
-				Required:    false,
-				DisplayAttrs: &framework.DisplayAttributes{
-					Name:      "Graphite User",
-					Sensitive: true,
-				},
+		},
+		"issuance_rate_window": {
+			Type:        framework.TypeDurationSecond,
+			Description: "Sliding window over which the issuance rate of each role is measured. Defaults to 1h",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Issuance Rate Window",
 			},
-			"graphite_url": {
-				Type:        framework.TypeString,
-				Description: "The URL at which Graphite can be accessed, if set this is returned alongside every issued credential",
-				Required:    false,
-				DisplayAttrs: &framework.DisplayAttributes{
-					Name:      "Graphite URL",
-					Sensitive: true,
-				},
+		},
+		"issuance_rate_warn": {
+			Type:        framework.TypeInt,
+			Description: "Number of keys a role may issue within issuance_rate_window before a warning is returned and logged. 0 disables it",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Issuance Rate Warning Threshold",
 			},
 		},
+		"issuance_rate_deny": {
+			Type:        framework.TypeInt,
+			Description: "Number of keys a role may issue within issuance_rate_window before issuance is refused. 0 disables it",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Issuance Rate Deny Threshold",
+			},
+		},
+	}
+
+	fields["force"] = &framework.FieldSchema{
+		Type:        framework.TypeBool,
+		Description: "On delete, remove the configuration even if roles or outstanding leases still depend on it",
+	}
+
+	for name, schema := range endpointFields() {
+		fields[name] = schema
+	}
+
+	return &framework.Path{
+		Pattern: "config",
+		Fields:  fields,
+		DisplayAttrs: &framework.DisplayAttributes{
+			ItemType: "Configuration",
+			Action:   "Configure",
+		},
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.ReadOperation: &framework.PathOperation{
 				Callback: b.pathConfigRead,
+				Summary:  "Read the Grafana Cloud configuration.",
 			},
 			logical.CreateOperation: &framework.PathOperation{
 				Callback: b.pathConfigWrite,
+				Summary:  "Configure the Grafana Cloud organisation and admin key.",
 			},
 			logical.UpdateOperation: &framework.PathOperation{
 				Callback: b.pathConfigWrite,
+				Summary:  "Configure the Grafana Cloud organisation and admin key.",
 			},
 			logical.DeleteOperation: &framework.PathOperation{
 				Callback: b.pathConfigDelete,
+				Summary:  "Delete the Grafana Cloud configuration.",
 			},
 		},
 		ExistenceCheck:  b.pathConfigExistenceCheck,
@@ -199,7 +415,7 @@ func (b *grafanaCloudBackend) pathConfigExistenceCheck(ctx context.Context, req
 	return out != nil, nil
 }
 
-func getConfig(ctx context.Context, s logical.Storage) (*grafanaCloudConfig, error) {
+func getConfig(ctx context.Context, s logical.Storage) (*Config, error) {
 	entry, err := s.Get(ctx, configStoragePath)
 	if err != nil {
 		return nil, NewInternalError("failed to fetch config", err)
@@ -209,11 +425,14 @@ func getConfig(ctx context.Context, s logical.Storage) (*grafanaCloudConfig, err
 		return nil, nil
 	}
 
-	config := new(grafanaCloudConfig)
+	config := new(Config)
 	if err := entry.DecodeJSON(&config); err != nil {
 		return nil, NewInvalidConfigurationError("error reading root configuration", err)
 	}
 
+	// Stored by the next config write or by initialize.
+	config.legacyUserPending = config.migrateLegacyUser()
+
 	// return the config, we are done
 	return config, nil
 }
@@ -224,27 +443,74 @@ func (b *grafanaCloudBackend) pathConfigRead(ctx context.Context, req *logical.R
 		return nil, NewInternalError("failed to fetch config", err)
 	}
 
-	return &logical.Response{
-		Data: map[string]interface{}{
-			"organisation":      config.Organisation,
-			"key":               config.Key,
-			"url":               config.URL,
-			"user":              config.User,
-			"prometheus_user":   config.PrometheusUser,
-			"prometheus_url":    config.PrometheusURL,
-			"loki_user":         config.LokiUser,
-			"loki_url":          config.LokiURL,
-			"tempo_user":        config.TempoUser,
-			"tempo_url":         config.TempoURL,
-			"alertmanager_user": config.AlertmanagerUser,
-			"alertmanager_url":  config.AlertmanagerURL,
-			"graphite_user":     config.GraphiteUser,
-			"graphite_url":      config.GraphiteURL,
-		},
-	}, nil
+	if config == nil {
+		return logical.ErrorResponse("backend not configured"), nil
+	}
+
+	respData := config.Endpoints.toResponseData()
+	respData["organisation"] = config.Organisation
+	respData["key"] = config.Key
+	respData["secondary_key"] = config.SecondaryKey
+	respData["url"] = config.URL
+	respData["fallback_url"] = config.FallbackURL
+	respData["key_expires_at"] = ""
+	respData["verify_revocation"] = config.VerifyRevocation
+	respData["webhook_url"] = config.WebhookURL
+	respData["webhook_secret"] = config.WebhookSecret
+	respData["events_to_loki"] = config.EventsToLoki
+	respData["maintenance_mode"] = config.MaintenanceMode
+	respData["seal_wrap_issuance_records"] = config.SealWrapIssuanceRecords
+	respData["debug_paths"] = config.DebugPaths
+	respData["token_prefix"] = config.TokenPrefix
+	respData["environment"] = config.Environment
+	respData["max_outstanding_keys"] = config.MaxOutstandingKeys
+	respData["issuance_rate_window"] = int64(config.IssuanceRateWindow.Seconds())
+	respData["issuance_rate_warn"] = config.IssuanceRateWarn
+	respData["issuance_rate_deny"] = config.IssuanceRateDeny
+
+	respData["tls_min_version"] = config.TLSMinVersion
+
+	respData["stack_cache_ttl"] = int64(config.stackCacheTTL().Seconds())
+
+	if config.KeyExpiresAt != nil {
+		respData["key_expires_at"] = config.KeyExpiresAt.Format(time.RFC3339)
+	}
+
+	if len(config.Regions) > 0 {
+		respData["regions"] = config.Regions
+	}
+
+	if len(config.ValidGCRoles) > 0 {
+		respData["valid_gc_roles"] = config.ValidGCRoles
+	}
+
+	if len(config.AdditionalGCRoles) > 0 {
+		respData["additional_gc_roles"] = config.AdditionalGCRoles
+	}
+
+	if len(config.TLSCipherSuites) > 0 {
+		respData["tls_cipher_suites"] = config.TLSCipherSuites
+	}
+
+	if len(config.ProtectedKeyPrefixes) > 0 {
+		respData["protected_key_prefixes"] = config.ProtectedKeyPrefixes
+	}
+
+	resp := &logical.Response{
+		Data: respData,
+	}
+
+	if config.legacyUserPending {
+		resp.AddWarning(legacyUserWarning)
+	}
+
+	if warning := config.adminKeyExpiryWarning(time.Now()); warning != "" {
+		resp.AddWarning(warning)
+	}
+
+	return resp, nil
 }
 
-//nolint:gocognit,gocyclo // func is long because it's writing each config option.
 func (b *grafanaCloudBackend) pathConfigWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	config, err := getConfig(ctx, req.Storage)
 	if err != nil {
@@ -255,112 +521,357 @@ func (b *grafanaCloudBackend) pathConfigWrite(ctx context.Context, req *logical.
 
 	if config == nil {
 		if !createOperation {
-			return nil, NewInvalidConfigurationError("config not found during update operation", nil)
+			return errorResponse(NewInvalidConfigurationError("config not found during update operation", nil))
 		}
-		config = new(grafanaCloudConfig)
+		config = new(Config)
 	}
 
 	if organisation, ok := data.GetOk("organisation"); ok {
 		config.Organisation = organisation.(string)
 	}
 
-	if config.Organisation == "" && createOperation {
-		return nil, NewInvalidConfigurationError("missing organisation", nil)
-	}
+	previousKey := config.Key
 
 	if key, ok := data.GetOk("key"); ok {
+		if key.(string) != config.Key {
+			config.KeyExpiresAt = nil
+		}
+
 		config.Key = key.(string)
 	}
 
+	if secondaryKey, ok := data.GetOk("secondary_key"); ok {
+		config.SecondaryKey = secondaryKey.(string)
+	}
+
+	if config.SecondaryKey != "" && config.SecondaryKey == config.Key {
+		return errorResponse(NewInvalidConfigurationError("secondary_key must differ from key", nil))
+	}
+
+	if expiresAt, ok := data.GetOk("key_expires_at"); ok {
+		config.KeyExpiresAt = nil
+
+		if expiresAt.(string) != "" {
+			t, err := time.Parse(time.RFC3339, expiresAt.(string))
+			if err != nil {
+				return errorResponse(NewInvalidConfigurationError("invalid key_expires_at, expected an RFC 3339 timestamp", err))
+			}
+
+			config.KeyExpiresAt = &t
+		}
+	}
+
 	if config.Key == "" && createOperation {
-		return nil, NewInvalidConfigurationError("missing key", nil)
+		return errorResponse(NewInvalidConfigurationError("missing key", nil))
 	}
 
 	if configuredURL, ok := data.GetOk("url"); ok {
-		config.URL = configuredURL.(string)
-		if u, err := url.ParseRequestURI(config.URL); err != nil || !u.IsAbs() {
-			return nil, NewInvalidConfigurationError("invalid url", err)
+		if config.URL, err = normalizeAPIURL(configuredURL.(string)); err != nil {
+			return errorResponse(err)
 		}
 	} else if !ok && createOperation {
-		return nil, NewInvalidConfigurationError("missing url", nil)
+		return errorResponse(NewInvalidConfigurationError("missing url", nil))
 	}
 
-	if user, ok := data.GetOk("user"); ok {
-		config.User = user.(string)
+	if fallbackURL, ok := data.GetOk("fallback_url"); ok {
+		config.FallbackURL = ""
+		if fallbackURL.(string) != "" {
+			if config.FallbackURL, err = normalizeBaseURL("fallback_url", fallbackURL.(string)); err != nil {
+				return errorResponse(err)
+			}
+		}
+	}
+
+	if config.FallbackURL != "" && config.FallbackURL == config.URL {
+		return errorResponse(NewInvalidConfigurationError("fallback_url must differ from url", nil))
 	}
 
-	if user, ok := data.GetOk("prometheus_user"); ok {
-		config.PrometheusUser = user.(string)
-		// Set user for backwards compatibility.
-		config.User = user.(string)
+	if verify, ok := data.GetOk("verify_revocation"); ok {
+		config.VerifyRevocation = verify.(bool)
 	}
 
-	if user, ok := data.GetOk("loki_user"); ok {
-		config.LokiUser = user.(string)
+	if webhookURL, ok := data.GetOk("webhook_url"); ok {
+		config.WebhookURL = ""
+		if webhookURL.(string) != "" {
+			if config.WebhookURL, err = normalizeURL("webhook_url", webhookURL.(string)); err != nil {
+				return errorResponse(err)
+			}
+		}
 	}
 
-	if user, ok := data.GetOk("tempo_user"); ok {
-		config.TempoUser = user.(string)
+	if webhookSecret, ok := data.GetOk("webhook_secret"); ok {
+		config.WebhookSecret = webhookSecret.(string)
 	}
 
-	if user, ok := data.GetOk("alertmanager_user"); ok {
-		config.AlertmanagerUser = user.(string)
+	if config.WebhookURL != "" && config.WebhookSecret == "" {
+		return errorResponse(NewInvalidConfigurationError("webhook_secret is required with webhook_url", nil))
 	}
 
-	if user, ok := data.GetOk("graphite_user"); ok {
-		config.GraphiteUser = user.(string)
+	if maintenance, ok := data.GetOk("maintenance_mode"); ok {
+		config.MaintenanceMode = maintenance.(bool)
 	}
 
-	if prometheusURL, ok := data.GetOk("prometheus_url"); ok {
-		config.PrometheusURL = prometheusURL.(string)
-		if u, err := url.ParseRequestURI(config.PrometheusURL); err != nil || !u.IsAbs() {
-			return nil, NewInvalidConfigurationError("invalid prometheus_url", err)
+	if debugPaths, ok := data.GetOk("debug_paths"); ok {
+		config.DebugPaths = debugPaths.(bool)
+	}
+
+	sealWrap, sealWrapSet := data.GetOk("seal_wrap_issuance_records")
+	if sealWrapSet {
+		config.SealWrapIssuanceRecords = sealWrap.(bool)
+	}
+
+	eventsWereShipped := config.EventsToLoki
+
+	if eventsToLoki, ok := data.GetOk("events_to_loki"); ok {
+		config.EventsToLoki = eventsToLoki.(bool)
+	}
+
+	if tokenPrefix, ok := data.GetOk("token_prefix"); ok {
+		if !tokenPrefixRegex.MatchString(tokenPrefix.(string)) {
+			return errorResponse(NewInvalidConfigurationError("token_prefix may only contain letters, digits, '-', '_' and '.'", nil))
 		}
+
+		config.TokenPrefix = tokenPrefix.(string)
 	}
 
-	if lokiURL, ok := data.GetOk("loki_url"); ok {
-		config.LokiURL = lokiURL.(string)
-		if u, err := url.ParseRequestURI(config.LokiURL); err != nil || !u.IsAbs() {
-			return nil, NewInvalidConfigurationError("invalid loki_url", err)
+	if environment, ok := data.GetOk("environment"); ok {
+		if !tokenPrefixRegex.MatchString(environment.(string)) {
+			return errorResponse(NewInvalidConfigurationError("environment may only contain letters, digits, '-', '_' and '.'", nil))
 		}
+
+		config.Environment = environment.(string)
 	}
 
-	if tempoURL, ok := data.GetOk("tempo_url"); ok {
-		config.TempoURL = tempoURL.(string)
-		if u, err := url.ParseRequestURI(config.TempoURL); err != nil || !u.IsAbs() {
-			return nil, NewInvalidConfigurationError("invalid tempo_url", err)
+	if maxOutstandingKeys, ok := data.GetOk("max_outstanding_keys"); ok {
+		if maxOutstandingKeys.(int) < 0 {
+			return errorResponse(NewInvalidConfigurationError("max_outstanding_keys cannot be negative", nil))
 		}
+
+		config.MaxOutstandingKeys = maxOutstandingKeys.(int)
 	}
 
-	if AlertmanagerURL, ok := data.GetOk("alertmanager_url"); ok {
-		config.AlertmanagerURL = AlertmanagerURL.(string)
-		if u, err := url.ParseRequestURI(config.AlertmanagerURL); err != nil || !u.IsAbs() {
-			return nil, NewInvalidConfigurationError("invalid alertmanager_url", err)
+	if window, ok := data.GetOk("issuance_rate_window"); ok {
+		config.IssuanceRateWindow = time.Duration(window.(int)) * time.Second
+	}
+
+	for field, threshold := range map[string]*int{
+		"issuance_rate_warn": &config.IssuanceRateWarn,
+		"issuance_rate_deny": &config.IssuanceRateDeny,
+	} {
+		if value, ok := data.GetOk(field); ok {
+			if value.(int) < 0 {
+				return errorResponse(NewInvalidConfigurationError(field+" cannot be negative", nil))
+			}
+
+			*threshold = value.(int)
 		}
 	}
 
-	if graphiteURL, ok := data.GetOk("graphite_url"); ok {
-		config.GraphiteURL = graphiteURL.(string)
-		if u, err := url.ParseRequestURI(config.GraphiteURL); err != nil || !u.IsAbs() {
-			return nil, NewInvalidConfigurationError("invalid graphite_url", err)
+	if tlsMinVersion, ok := data.GetOk("tls_min_version"); ok {
+		config.TLSMinVersion = tlsMinVersion.(string)
+	}
+
+	if tlsCipherSuites, ok := data.GetOk("tls_cipher_suites"); ok {
+		config.TLSCipherSuites = tlsCipherSuites.([]string)
+	}
+
+	if _, err := config.tlsConfig(); err != nil {
+		return errorResponse(err)
+	}
+
+	if cacheTTL, ok := data.GetOk("stack_cache_ttl"); ok {
+		if cacheTTL.(int) < 0 {
+			return errorResponse(NewInvalidConfigurationError("stack_cache_ttl cannot be negative", nil))
 		}
+
+		config.StackCacheTTL = new(time.Duration)
+		*config.StackCacheTTL = time.Duration(cacheTTL.(int)) * time.Second
 	}
 
-	entry, err := logical.StorageEntryJSON(configStoragePath, config)
-	if err != nil {
-		return nil, err
+	for field, values := range map[string]*[]string{
+		"valid_gc_roles":         &config.ValidGCRoles,
+		"additional_gc_roles":    &config.AdditionalGCRoles,
+		"protected_key_prefixes": &config.ProtectedKeyPrefixes,
+	} {
+		if value, ok := data.GetOk(field); ok {
+			*values = nonEmptyStrings(value.([]string))
+		}
+	}
+
+	if regions, ok := data.GetOk("regions"); ok {
+		config.Regions = map[string]string{}
+
+		for region, raw := range regions.(map[string]string) {
+			regionURL, err := normalizeAPIURL(raw)
+			if err != nil {
+				return errorResponse(NewInvalidConfigurationError(fmt.Sprintf("invalid url for region %q", region), err))
+			}
+
+			config.Regions[region] = regionURL
+		}
 	}
 
-	if err := req.Storage.Put(ctx, entry); err != nil {
+	if err := config.Endpoints.update(data); err != nil {
+		return errorResponse(err)
+	}
+
+	var detectedOrganisation string
+
+	if config.Organisation == "" {
+		if detectedOrganisation, err = detectOrganisation(ctx, config); err != nil {
+			return errorResponse(err)
+		}
+
+		config.Organisation = detectedOrganisation
+	}
+
+	if err := config.validate(); err != nil {
+		return errorResponse(err)
+	}
+
+	if err := putConfig(ctx, req.Storage, config); err != nil {
 		return nil, err
 	}
 
 	b.reset()
 
-	return nil, nil
+	warnings := config.Endpoints.userWarnings()
+
+	if detectedOrganisation != "" {
+		warnings = append(warnings, "organisation not set, detected "+detectedOrganisation+" from the stacks the key has access to")
+	}
+
+	// Moved on every write of the flag, so that a failed move can be retried.
+	if sealWrapSet {
+		moved, err := moveIssuanceRecords(ctx, req.Storage, config.SealWrapIssuanceRecords)
+		if err != nil {
+			return nil, err
+		}
+
+		if moved > 0 {
+			b.Logger().Info("moved issuance records", "count", moved, "seal_wrapped", config.SealWrapIssuanceRecords)
+		}
+	}
+
+	if eventsWereShipped && !config.EventsToLoki {
+		if err := b.deleteLokiEventsKey(ctx, req.Storage, config); err != nil {
+			b.Logger().Warn("failed to delete Loki events key", "error", err)
+			warnings = append(warnings, "failed to delete the key events were pushed to Loki with, delete "+
+				lokiEventsKeyNameFor(config)+" in Grafana Cloud: "+err.Error())
+		}
+	}
+
+	if previousKey != "" && config.Key != previousKey {
+		event := map[string]interface{}{"key_expires_at": ""}
+		if config.KeyExpiresAt != nil {
+			event["key_expires_at"] = config.KeyExpiresAt.Format(time.RFC3339)
+		}
+
+		b.notifyConfig(ctx, req.Storage, config, webhookEventAdminKeyRotated, event)
+	}
+
+	return warningsResponse(warnings), nil
 }
 
+// normalizeURL validates raw as an absolute URL and returns its canonical
+// form, with a lower case scheme and host and no trailing slashes.
+func normalizeURL(field, raw string) (string, error) {
+	u, err := url.ParseRequestURI(raw)
+	if err != nil || !u.IsAbs() {
+		return "", NewInvalidConfigurationError("invalid "+field, err)
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = strings.TrimRight(u.RawPath, "/")
+
+	return u.String(), nil
+}
+
+// normalizeAPIURL returns the canonical form of the Grafana Cloud API URL.
+// A trailing /api is removed as the API client prefixes every request path with it.
+func normalizeAPIURL(raw string) (string, error) {
+	return normalizeBaseURL("url", raw)
+}
+
+// normalizeBaseURL is normalizeAPIURL for the URL in field.
+func normalizeBaseURL(field, raw string) (string, error) {
+	normalized, err := normalizeURL(field, raw)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(normalized)
+	if err != nil {
+		return "", NewInvalidConfigurationError("invalid "+field, err)
+	}
+
+	const apiSuffix = "/api"
+	if strings.HasSuffix(strings.ToLower(u.Path), apiSuffix) {
+		u.Path = u.Path[:len(u.Path)-len(apiSuffix)]
+		u.RawPath = ""
+	}
+
+	return u.String(), nil
+}
+
+const legacyUserWarning = "the stored config still has the deprecated user field, it is returned as prometheus_user" +
+	" and removed from storage when the plugin is next initialized or the config is written"
+
+// initialize migrates configs stored by older versions once the plugin is mounted.
+func (b *grafanaCloudBackend) initialize(ctx context.Context, req *logical.InitializationRequest) error {
+	config, err := getConfig(ctx, req.Storage)
+	if err != nil || config == nil || !config.legacyUserPending {
+		return err
+	}
+
+	if err := putConfig(ctx, req.Storage, config); err != nil {
+		return NewInternalError("error migrating config", err)
+	}
+
+	b.Logger().Info("migrated the deprecated user config field to prometheus_user")
+
+	return nil
+}
+
+func putConfig(ctx context.Context, s logical.Storage, config *Config) error {
+	entry, err := logical.StorageEntryJSON(configStoragePath, config)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+// pathConfigDelete refuses to delete the config while roles or outstanding
+// leases exist, as their keys could no longer be revoked, unless forced.
 func (b *grafanaCloudBackend) pathConfigDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if !data.Get("force").(bool) {
+		roles, err := req.Storage.List(ctx, "roles/")
+		if err != nil {
+			return nil, NewInternalError("error listing roles", err)
+		}
+
+		issued, err := listIssuanceRecords(ctx, req.Storage)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(roles) > 0 || len(issued) > 0 {
+			return logical.ErrorResponse(fmt.Sprintf(
+				"config is still used by %d roles and %d outstanding leases, their keys cannot be revoked without it;"+
+					" delete the roles and revoke the leases first or set force=true", len(roles), len(issued))), nil
+		}
+	}
+
+	// The key is deleted with the admin key, which goes with the config.
+	if config, err := getConfig(ctx, req.Storage); err == nil && config != nil {
+		if err := b.deleteLokiEventsKey(ctx, req.Storage, config); err != nil {
+			b.Logger().Warn("failed to delete Loki events key", "error", err)
+		}
+	}
+
 	err := req.Storage.Delete(ctx, configStoragePath)
 
 	if err == nil {
@@ -0,0 +1,190 @@
+package secretsengine
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// pathConfigCA extends the Vault API with a `/config/ca`
+// endpoint for the backend, so the trusted CA bundle can
+// be rotated without access to the admin key.
+func pathConfigCA(b *grafanaCloudBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/ca",
+		Fields: map[string]*framework.FieldSchema{
+			"ca_certificate": {
+				Type:        framework.TypeString,
+				Description: "PEM encoded CA certificates trusted, in addition to the system roots, for connections to Grafana Cloud",
+				Required:    true,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:     "CA Certificate",
+					EditType: "textarea",
+				},
+			},
+		},
+		DisplayAttrs: &framework.DisplayAttributes{
+			ItemType: "CA",
+			Action:   "Configure",
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathConfigCARead,
+				Summary:  "Read the trusted CA bundle.",
+			},
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathConfigCAWrite,
+				Summary:  "Replace the trusted CA bundle.",
+			},
+			logical.DeleteOperation: &framework.PathOperation{
+				Callback: b.pathConfigCADelete,
+				Summary:  "Remove the trusted CA bundle.",
+			},
+		},
+		HelpSynopsis:    pathConfigCAHelpSynopsis,
+		HelpDescription: pathConfigCAHelpDescription,
+	}
+}
+
+// caCertificates returns the certificates of a PEM bundle, which must hold
+// at least one.
+func caCertificates(bundle string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+
+	rest := []byte(bundle)
+
+	for {
+		var block *pem.Block
+
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, NewInvalidConfigurationError("invalid certificate in ca_certificate", err)
+		}
+
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, NewInvalidConfigurationError("ca_certificate contains no PEM encoded certificate", nil)
+	}
+
+	return certs, nil
+}
+
+func (b *grafanaCloudBackend) pathConfigCARead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, NewInternalError("failed to fetch config", err)
+	}
+
+	if config == nil {
+		return logical.ErrorResponse("backend not configured"), nil
+	}
+
+	if config.CACertificate == "" {
+		return nil, nil
+	}
+
+	certs, err := caCertificates(config.CACertificate)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	certificates := make([]map[string]interface{}, 0, len(certs))
+	for _, cert := range certs {
+		certificates = append(certificates, map[string]interface{}{
+			"subject":   cert.Subject.String(),
+			"not_after": cert.NotAfter.UTC().Format(time.RFC3339),
+		})
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"ca_certificate": config.CACertificate,
+			"certificates":   certificates,
+		},
+	}, nil
+}
+
+func (b *grafanaCloudBackend) pathConfigCAWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, NewInternalError("failed to fetch config", err)
+	}
+
+	if config == nil {
+		return logical.ErrorResponse("backend not configured"), nil
+	}
+
+	bundle := data.Get("ca_certificate").(string)
+
+	certs, err := caCertificates(bundle)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	config.CACertificate = bundle
+
+	if err := putConfig(ctx, req.Storage, config); err != nil {
+		return nil, err
+	}
+
+	b.reset()
+
+	var warnings []string
+
+	for _, cert := range certs {
+		if time.Now().After(cert.NotAfter) {
+			warnings = append(warnings, "certificate "+cert.Subject.String()+" has expired")
+		}
+	}
+
+	return warningsResponse(warnings), nil
+}
+
+func (b *grafanaCloudBackend) pathConfigCADelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, NewInternalError("failed to fetch config", err)
+	}
+
+	if config == nil || config.CACertificate == "" {
+		return nil, nil
+	}
+
+	config.CACertificate = ""
+
+	if err := putConfig(ctx, req.Storage, config); err != nil {
+		return nil, err
+	}
+
+	b.reset()
+
+	return nil, nil
+}
+
+// pathConfigCAHelpSynopsis summarizes the help text for the CA configuration.
+const pathConfigCAHelpSynopsis = `Configure the CA bundle trusted for connections to Grafana Cloud.`
+
+// pathConfigCAHelpDescription describes the help text for the CA configuration.
+const pathConfigCAHelpDescription = `
+The PEM encoded CA certificates trusted, in addition to the system roots, for
+the connections the plugin makes to Grafana Cloud, e.g. through a proxy which
+intercepts TLS. It is kept apart from the config path so that certificate
+rotations can be delegated without access to the admin key. A write replaces
+the bundle, a delete removes it. Reads return the subject and expiry of each
+certificate.
+`
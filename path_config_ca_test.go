@@ -0,0 +1,85 @@
+package secretsengine
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigCA(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	bundle := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	b, s := getTestBackend(t)
+
+	request := func(t *testing.T, operation logical.Operation, data map[string]interface{}) *logical.Response {
+		t.Helper()
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: operation,
+			Path:      "config/ca",
+			Data:      data,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+
+		return resp
+	}
+
+	t.Run("Not configured", func(t *testing.T) {
+		resp := request(t, logical.UpdateOperation, map[string]interface{}{"ca_certificate": bundle})
+		assert.EqualError(t, resp.Error(), "backend not configured")
+	})
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          configURL,
+		"organisation": organisation,
+	}))
+
+	t.Run("Invalid bundle", func(t *testing.T) {
+		resp := request(t, logical.UpdateOperation, map[string]interface{}{"ca_certificate": "not a certificate"})
+		assert.EqualError(t, resp.Error(), "invalid configuration: ca_certificate contains no PEM encoded certificate")
+	})
+
+	t.Run("Write and read", func(t *testing.T) {
+		resp := request(t, logical.UpdateOperation, map[string]interface{}{"ca_certificate": bundle})
+		require.Nil(t, resp)
+
+		resp = request(t, logical.ReadOperation, nil)
+		require.False(t, resp.IsError())
+		assert.Equal(t, bundle, resp.Data["ca_certificate"])
+
+		certificates := resp.Data["certificates"].([]map[string]interface{})
+		require.Len(t, certificates, 1)
+		assert.Equal(t, server.Certificate().Subject.String(), certificates[0]["subject"])
+
+		config, err := getConfig(context.Background(), s)
+		require.NoError(t, err)
+
+		tlsConfig, err := config.tlsConfig()
+		require.NoError(t, err)
+		assert.NotNil(t, tlsConfig.RootCAs)
+	})
+
+	t.Run("Config writes keep the bundle", func(t *testing.T) {
+		require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{"token_prefix": "cluster-a"}))
+
+		resp := request(t, logical.ReadOperation, nil)
+		assert.Equal(t, bundle, resp.Data["ca_certificate"])
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		request(t, logical.DeleteOperation, nil)
+
+		assert.Nil(t, request(t, logical.ReadOperation, nil))
+	})
+}
@@ -0,0 +1,456 @@
+package secretsengine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// Endpoints holds the users and URLs of the hosted
+// services which are returned alongside every issued credential.
+type Endpoints struct {
+	// LegacyUser is the deprecated `user` field as stored by older versions,
+	// see migrateLegacyUser. The `user` field is now an alias of prometheus_user.
+	LegacyUser       string `json:"user,omitempty"`
+	PrometheusUser   string `json:"prometheus_user"`
+	PrometheusURL    string `json:"prometheus_url"`
+	LokiUser         string `json:"loki_user"`
+	LokiURL          string `json:"loki_url"`
+	TempoUser        string `json:"tempo_user"`
+	TempoURL         string `json:"tempo_url"`
+	AlertmanagerUser string `json:"alertmanager_user"`
+	AlertmanagerURL  string `json:"alertmanager_url"`
+	GraphiteUser     string `json:"graphite_user"`
+	GraphiteURL      string `json:"graphite_url"`
+}
+
+// endpointFields returns the field schemas of the endpoint users and URLs,
+// shared by the `/config` and `/config/endpoints` paths.
+func endpointFields() map[string]*framework.FieldSchema {
+	return map[string]*framework.FieldSchema{
+		"endpoints": {
+			Type: framework.TypeMap,
+			Description: "The users and URLs of all services as a single object mapping the service name " +
+				"(prometheus, loki, tempo, alertmanager, graphite) to an object with 'user' and 'url' keys." +
+				" Individual *_user/*_url fields take precedence over values set here",
+			Required: false,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:     "Endpoints",
+				Group:    displayGroupEndpoints,
+				EditType: "kv",
+			},
+		},
+		"user": {
+			Type:        framework.TypeString,
+			Description: "(Deprecated) The User that is needed to interact with prometheus, if set this is returned alongside every issued credential",
+			Required:    false,
+			Deprecated:  true,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "User",
+				Sensitive: true,
+				Group:     displayGroupEndpoints,
+			},
+		},
+		"prometheus_user": {
+			Type: framework.TypeString,
+			Description: "The User that is needed to interact with prometheus, if set this is returned alongside every issued credential." +
+				" This will also set 'user' for backwards compatibility",
+			Required: false,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Prometheus User",
+				Sensitive: true,
+				Group:     displayGroupEndpoints,
+			},
+		},
+		"prometheus_url": {
+			Type:        framework.TypeString,
+			Description: "The URL at which Prometheus can be accessed, if set this is returned alongside every issued credential",
+			Required:    false,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Prometheus URL",
+				Sensitive: true,
+				Group:     displayGroupEndpoints,
+			},
+		},
+		"loki_user": {
+			Type:        framework.TypeString,
+			Description: "The User that is needed to interact with loki, if set this is returned alongside every issued credential",
+			Required:    false,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Loki User",
+				Sensitive: true,
+				Group:     displayGroupEndpoints,
+			},
+		},
+		"loki_url": {
+			Type:        framework.TypeString,
+			Description: "The URL at which Loki can be accessed, if set this is returned alongside every issued credential",
+			Required:    false,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Loki URL",
+				Sensitive: true,
+				Group:     displayGroupEndpoints,
+			},
+		},
+		"tempo_user": {
+			Type:        framework.TypeString,
+			Description: "The User that is needed to interact with tempo, if set this is returned alongside every issued credential",
+			Required:    false,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Tempo User",
+				Sensitive: true,
+				Group:     displayGroupEndpoints,
+			},
+		},
+		"tempo_url": {
+			Type:        framework.TypeString,
+			Description: "The URL at which Tempo can be accessed, if set this is returned alongside every issued credential",
+			Required:    false,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Tempo URL",
+				Sensitive: true,
+				Group:     displayGroupEndpoints,
+			},
+		},
+		"alertmanager_user": {
+			Type:        framework.TypeString,
+			Description: "The User that is needed to interact with alertmanager, if set this is returned alongside every issued credential",
+			Required:    false,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Alertmanager User",
+				Sensitive: true,
+				Group:     displayGroupEndpoints,
+			},
+		},
+		"alertmanager_url": {
+			Type:        framework.TypeString,
+			Description: "The URL at which Alertmanager can be accessed, if set this is returned alongside every issued credential",
+			Required:    false,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Alertmanager URL",
+				Sensitive: true,
+				Group:     displayGroupEndpoints,
+			},
+		},
+		"graphite_user": {
+			Type:        framework.TypeString,
+			Description: "The User that is needed to interact with graphite, if set this is returned alongside every issued credential",
+			Required:    false,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Graphite User",
+				Sensitive: true,
+				Group:     displayGroupEndpoints,
+			},
+		},
+		"graphite_url": {
+			Type:        framework.TypeString,
+			Description: "The URL at which Graphite can be accessed, if set this is returned alongside every issued credential",
+			Required:    false,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Graphite URL",
+				Sensitive: true,
+				Group:     displayGroupEndpoints,
+			},
+		},
+	}
+}
+
+// pathConfigEndpoints extends the Vault API with a `/config/endpoints`
+// endpoint for the backend, so the endpoint users and URLs can be
+// managed without access to the admin key.
+func pathConfigEndpoints(b *grafanaCloudBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/endpoints",
+		Fields:  endpointFields(),
+		DisplayAttrs: &framework.DisplayAttributes{
+			ItemType: "Endpoints",
+			Action:   "Configure",
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathConfigEndpointsRead,
+				Summary:  "Read the endpoint users and URLs.",
+			},
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathConfigEndpointsWrite,
+				Summary:  "Replace the endpoint users and URLs.",
+			},
+			logical.PatchOperation: &framework.PathOperation{
+				Callback: b.pathConfigEndpointsWrite,
+				Summary:  "Update the given endpoint users and URLs.",
+			},
+		},
+		HelpSynopsis:    pathConfigEndpointsHelpSynopsis,
+		HelpDescription: pathConfigEndpointsHelpDescription,
+	}
+}
+
+// toResponseData returns response data for the endpoints.
+func (e *Endpoints) toResponseData() map[string]interface{} {
+	return map[string]interface{}{
+		"user":              e.PrometheusUser,
+		"prometheus_user":   e.PrometheusUser,
+		"prometheus_url":    e.PrometheusURL,
+		"loki_user":         e.LokiUser,
+		"loki_url":          e.LokiURL,
+		"tempo_user":        e.TempoUser,
+		"tempo_url":         e.TempoURL,
+		"alertmanager_user": e.AlertmanagerUser,
+		"alertmanager_url":  e.AlertmanagerURL,
+		"graphite_user":     e.GraphiteUser,
+		"graphite_url":      e.GraphiteURL,
+	}
+}
+
+// endpointRef points at the user and URL of a single service.
+type endpointRef struct {
+	user *string
+	url  *string
+}
+
+// services returns the endpoints keyed by service name, as accepted by the `endpoints` field.
+func (e *Endpoints) services() map[string]endpointRef {
+	return map[string]endpointRef{
+		"prometheus":   {user: &e.PrometheusUser, url: &e.PrometheusURL},
+		"loki":         {user: &e.LokiUser, url: &e.LokiURL},
+		"tempo":        {user: &e.TempoUser, url: &e.TempoURL},
+		"alertmanager": {user: &e.AlertmanagerUser, url: &e.AlertmanagerURL},
+		"graphite":     {user: &e.GraphiteUser, url: &e.GraphiteURL},
+	}
+}
+
+// updateFromMap sets the endpoints present in the bulk `endpoints` field.
+func (e *Endpoints) updateFromMap(endpoints map[string]interface{}) error {
+	services := e.services()
+
+	for service, raw := range endpoints {
+		ref, ok := services[service]
+		if !ok {
+			return NewInvalidConfigurationError(fmt.Sprintf("unknown service %q in endpoints", service), nil)
+		}
+
+		values, ok := raw.(map[string]interface{})
+		if !ok {
+			return NewInvalidConfigurationError(fmt.Sprintf("endpoints.%s must be an object", service), nil)
+		}
+
+		for name, value := range values {
+			str, ok := value.(string)
+			if !ok {
+				return NewInvalidConfigurationError(fmt.Sprintf("endpoints.%s.%s must be a string", service, name), nil)
+			}
+
+			switch name {
+			case "user":
+				*ref.user = str
+			case "url":
+				normalized, err := normalizeURL(fmt.Sprintf("endpoints.%s.url", service), str)
+				if err != nil {
+					return err
+				}
+				*ref.url = normalized
+			default:
+				return NewInvalidConfigurationError(fmt.Sprintf("unknown key %q in endpoints.%s", name, service), nil)
+			}
+		}
+	}
+
+	return nil
+}
+
+// userWarnings returns a warning for every configured user that is not a
+// numeric instance ID. Grafana Cloud only accepts instance IDs as basic auth
+// users, anything else (e.g. an email or stack slug) fails at request time.
+func (e *Endpoints) userWarnings() []string {
+	users := map[string]string{}
+	for service, ref := range e.services() {
+		users[service+"_user"] = *ref.user
+	}
+
+	var warnings []string
+
+	for field, user := range users {
+		if user == "" {
+			continue
+		}
+
+		if _, err := strconv.ParseUint(user, 10, 64); err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s %q is not a numeric Grafana Cloud instance ID, requests using it will fail to authenticate", field, user))
+		}
+	}
+
+	sort.Strings(warnings)
+
+	return warnings
+}
+
+// migrateLegacyUser copies the deprecated user into prometheus_user where
+// unset and drops it, reporting whether anything changed. Older versions
+// stored both and returned user alongside credentials.
+func (e *Endpoints) migrateLegacyUser() bool {
+	if e.LegacyUser == "" {
+		return false
+	}
+
+	if e.PrometheusUser == "" {
+		e.PrometheusUser = e.LegacyUser
+	}
+
+	e.LegacyUser = ""
+
+	return true
+}
+
+// warningsResponse returns a response carrying warnings, or nil when there are none.
+func warningsResponse(warnings []string) *logical.Response {
+	if len(warnings) == 0 {
+		return nil
+	}
+
+	resp := &logical.Response{}
+	for _, warning := range warnings {
+		resp.AddWarning(warning)
+	}
+
+	return resp
+}
+
+// update sets the endpoints present in data, leaving the others untouched.
+//
+//nolint:gocognit,gocyclo // func is long because it's writing each endpoint.
+func (e *Endpoints) update(data *framework.FieldData) error {
+	if endpoints, ok := data.GetOk("endpoints"); ok {
+		if err := e.updateFromMap(endpoints.(map[string]interface{})); err != nil {
+			return err
+		}
+	}
+
+	// user is the deprecated alias of prometheus_user, which takes precedence.
+	if user, ok := data.GetOk("user"); ok {
+		e.PrometheusUser = user.(string)
+	}
+
+	if user, ok := data.GetOk("prometheus_user"); ok {
+		e.PrometheusUser = user.(string)
+	}
+
+	if user, ok := data.GetOk("loki_user"); ok {
+		e.LokiUser = user.(string)
+	}
+
+	if user, ok := data.GetOk("tempo_user"); ok {
+		e.TempoUser = user.(string)
+	}
+
+	if user, ok := data.GetOk("alertmanager_user"); ok {
+		e.AlertmanagerUser = user.(string)
+	}
+
+	if user, ok := data.GetOk("graphite_user"); ok {
+		e.GraphiteUser = user.(string)
+	}
+
+	if prometheusURL, ok := data.GetOk("prometheus_url"); ok {
+		normalized, err := normalizeURL("prometheus_url", prometheusURL.(string))
+		if err != nil {
+			return err
+		}
+		e.PrometheusURL = normalized
+	}
+
+	if lokiURL, ok := data.GetOk("loki_url"); ok {
+		normalized, err := normalizeURL("loki_url", lokiURL.(string))
+		if err != nil {
+			return err
+		}
+		e.LokiURL = normalized
+	}
+
+	if tempoURL, ok := data.GetOk("tempo_url"); ok {
+		normalized, err := normalizeURL("tempo_url", tempoURL.(string))
+		if err != nil {
+			return err
+		}
+		e.TempoURL = normalized
+	}
+
+	if AlertmanagerURL, ok := data.GetOk("alertmanager_url"); ok {
+		normalized, err := normalizeURL("alertmanager_url", AlertmanagerURL.(string))
+		if err != nil {
+			return err
+		}
+		e.AlertmanagerURL = normalized
+	}
+
+	if graphiteURL, ok := data.GetOk("graphite_url"); ok {
+		normalized, err := normalizeURL("graphite_url", graphiteURL.(string))
+		if err != nil {
+			return err
+		}
+		e.GraphiteURL = normalized
+	}
+
+	return nil
+}
+
+func (b *grafanaCloudBackend) pathConfigEndpointsRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, NewInternalError("failed to fetch config", err)
+	}
+
+	if config == nil {
+		return logical.ErrorResponse("backend not configured"), nil
+	}
+
+	return &logical.Response{
+		Data: config.Endpoints.toResponseData(),
+	}, nil
+}
+
+// pathConfigEndpointsWrite replaces the endpoints on update and merges
+// them with the stored ones on patch.
+func (b *grafanaCloudBackend) pathConfigEndpointsWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, NewInternalError("failed to fetch config", err)
+	}
+
+	if config == nil {
+		return logical.ErrorResponse("backend not configured"), nil
+	}
+
+	if req.Operation == logical.UpdateOperation {
+		config.Endpoints = Endpoints{}
+	}
+
+	if err := config.Endpoints.update(data); err != nil {
+		return errorResponse(err)
+	}
+
+	if err := config.validate(); err != nil {
+		return errorResponse(err)
+	}
+
+	if err := putConfig(ctx, req.Storage, config); err != nil {
+		return nil, err
+	}
+
+	return warningsResponse(config.Endpoints.userWarnings()), nil
+}
+
+// pathConfigEndpointsHelpSynopsis summarizes the help text for the endpoints configuration.
+const pathConfigEndpointsHelpSynopsis = `Configure the endpoints returned alongside issued credentials.`
+
+// pathConfigEndpointsHelpDescription describes the help text for the endpoints configuration.
+const pathConfigEndpointsHelpDescription = `
+The users and URLs of the hosted Prometheus, Loki, Tempo, Alertmanager and
+Graphite instances which are returned alongside every issued credential.
+They can also be set on the config path, this path allows them to be
+managed without access to the admin key. A write replaces all endpoints,
+a patch only changes the provided ones.
+`
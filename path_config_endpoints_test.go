@@ -0,0 +1,120 @@
+package secretsengine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigEndpoints(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	t.Run("Write Endpoints - not configured", func(t *testing.T) {
+		resp, err := testConfigEndpointsRequest(b, s, logical.UpdateOperation, map[string]interface{}{
+			"loki_user": testLokiUser,
+		})
+
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	err := testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          configURL,
+		"organisation": organisation,
+	})
+	require.NoError(t, err)
+
+	t.Run("Write Endpoints - pass", func(t *testing.T) {
+		resp, err := testConfigEndpointsRequest(b, s, logical.UpdateOperation, map[string]interface{}{
+			"prometheus_user": testPrometheusUser,
+			"prometheus_url":  testPrometheusURL,
+			"loki_user":       testLokiUser,
+			"loki_url":        testLokiURL,
+		})
+
+		require.NoError(t, err)
+		require.Nil(t, resp)
+	})
+
+	t.Run("Write Endpoints - invalid url", func(t *testing.T) {
+		resp, err := testConfigEndpointsRequest(b, s, logical.UpdateOperation, map[string]interface{}{
+			"tempo_url": "/t",
+		})
+
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Write Endpoints - non-numeric user warns", func(t *testing.T) {
+		resp, err := testConfigEndpointsRequest(b, s, logical.PatchOperation, map[string]interface{}{
+			"loki_user": "me@example.com",
+		})
+
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.False(t, resp.IsError())
+		require.Equal(t, []string{
+			`loki_user "me@example.com" is not a numeric Grafana Cloud instance ID, requests using it will fail to authenticate`,
+		}, resp.Warnings)
+
+		resp, err = testConfigEndpointsRequest(b, s, logical.PatchOperation, map[string]interface{}{
+			"loki_user": testLokiUser,
+		})
+
+		require.NoError(t, err)
+		require.Nil(t, resp)
+	})
+
+	t.Run("Patch Endpoints - pass", func(t *testing.T) {
+		resp, err := testConfigEndpointsRequest(b, s, logical.PatchOperation, map[string]interface{}{
+			"tempo_user": testTempoUser,
+			"tempo_url":  testTempoURL,
+		})
+
+		require.NoError(t, err)
+		require.Nil(t, resp)
+	})
+
+	t.Run("Read Endpoints - patched", func(t *testing.T) {
+		resp, err := testConfigEndpointsRequest(b, s, logical.ReadOperation, nil)
+
+		require.NoError(t, err)
+		require.Equal(t, testPrometheusUser, resp.Data["user"])
+		require.Equal(t, testPrometheusUser, resp.Data["prometheus_user"])
+		require.Equal(t, testLokiURL, resp.Data["loki_url"])
+		require.Equal(t, testTempoURL, resp.Data["tempo_url"])
+		require.NotContains(t, resp.Data, "key")
+	})
+
+	t.Run("Write Endpoints - replaces", func(t *testing.T) {
+		_, err := testConfigEndpointsRequest(b, s, logical.UpdateOperation, map[string]interface{}{
+			"graphite_user": testGraphiteUser,
+		})
+		require.NoError(t, err)
+
+		resp, err := testConfigEndpointsRequest(b, s, logical.ReadOperation, nil)
+		require.NoError(t, err)
+		require.Equal(t, testGraphiteUser, resp.Data["graphite_user"])
+		require.Equal(t, "", resp.Data["loki_url"])
+	})
+
+	t.Run("Read Configuration - key untouched", func(t *testing.T) {
+		config, err := getConfig(context.Background(), s)
+
+		require.NoError(t, err)
+		require.Equal(t, key, config.Key)
+		require.Equal(t, testGraphiteUser, config.GraphiteUser)
+	})
+}
+
+func testConfigEndpointsRequest(b logical.Backend, s logical.Storage, op logical.Operation, d map[string]interface{}) (*logical.Response, error) {
+	return b.HandleRequest(context.Background(), &logical.Request{
+		Operation: op,
+		Path:      "config/endpoints",
+		Data:      d,
+		Storage:   s,
+	})
+}
@@ -4,16 +4,21 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client/clienttest"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const (
-	key           = "1234567"
-	configURL     = "http://localhost:19090/"
-	organisation  = "testorg"
-	organisation1 = "testorg1"
+	key       = "1234567"
+	configURL = "http://localhost:19090/"
+	// configURL as stored after normalization.
+	normalizedConfigURL = "http://localhost:19090"
+	organisation        = "testorg"
+	organisation1       = "testorg1"
 )
 
 func TestConfig(t *testing.T) {
@@ -117,20 +122,38 @@ func TestConfig(t *testing.T) {
 
 		t.Run("Read Configuration - pass", func(t *testing.T) {
 			err := testConfigRead(b, reqStorage, map[string]interface{}{
-				"key":               key,
-				"url":               configURL,
-				"organisation":      organisation,
-				"user":              "",
-				"prometheus_user":   "",
-				"prometheus_url":    "",
-				"loki_user":         "",
-				"loki_url":          "",
-				"tempo_user":        "",
-				"tempo_url":         "",
-				"alertmanager_user": "",
-				"alertmanager_url":  "",
-				"graphite_user":     "",
-				"graphite_url":      "",
+				"key":                        key,
+				"url":                        normalizedConfigURL,
+				"organisation":               organisation,
+				"verify_revocation":          false,
+				"events_to_loki":             false,
+				"maintenance_mode":           false,
+				"seal_wrap_issuance_records": false,
+				"debug_paths":                false,
+				"webhook_url":                "",
+				"webhook_secret":             "",
+				"max_outstanding_keys":       0,
+				"secondary_key":              "",
+				"fallback_url":               "",
+				"key_expires_at":             "",
+				"token_prefix":               "",
+				"environment":                "",
+				"tls_min_version":            "",
+				"stack_cache_ttl":            int64(300),
+				"issuance_rate_window":       int64(0),
+				"issuance_rate_warn":         0,
+				"issuance_rate_deny":         0,
+				"user":                       "",
+				"prometheus_user":            "",
+				"prometheus_url":             "",
+				"loki_user":                  "",
+				"loki_url":                   "",
+				"tempo_user":                 "",
+				"tempo_url":                  "",
+				"alertmanager_user":          "",
+				"alertmanager_url":           "",
+				"graphite_user":              "",
+				"graphite_url":               "",
 			})
 			assert.NoError(t, err)
 		})
@@ -163,20 +186,38 @@ func TestConfig(t *testing.T) {
 
 		t.Run("Read Updated Configuration (set users and urls) - pass", func(t *testing.T) {
 			err := testConfigRead(b, reqStorage, map[string]interface{}{
-				"key":               key,
-				"url":               "http://grafanacloud:19090",
-				"organisation":      organisation1,
-				"user":              "1",
-				"prometheus_user":   "",
-				"prometheus_url":    "http://prometheus",
-				"loki_user":         "2",
-				"loki_url":          "http://loki",
-				"tempo_user":        "3",
-				"tempo_url":         "http://tempo",
-				"alertmanager_user": "4",
-				"alertmanager_url":  "http://alertmanager",
-				"graphite_user":     "5",
-				"graphite_url":      "http://graphite",
+				"key":                        key,
+				"url":                        "http://grafanacloud:19090",
+				"organisation":               organisation1,
+				"verify_revocation":          false,
+				"events_to_loki":             false,
+				"maintenance_mode":           false,
+				"seal_wrap_issuance_records": false,
+				"debug_paths":                false,
+				"webhook_url":                "",
+				"webhook_secret":             "",
+				"max_outstanding_keys":       0,
+				"secondary_key":              "",
+				"fallback_url":               "",
+				"key_expires_at":             "",
+				"token_prefix":               "",
+				"environment":                "",
+				"tls_min_version":            "",
+				"stack_cache_ttl":            int64(300),
+				"issuance_rate_window":       int64(0),
+				"issuance_rate_warn":         0,
+				"issuance_rate_deny":         0,
+				"user":                       "1",
+				"prometheus_user":            "1",
+				"prometheus_url":             "http://prometheus",
+				"loki_user":                  "2",
+				"loki_url":                   "http://loki",
+				"tempo_user":                 "3",
+				"tempo_url":                  "http://tempo",
+				"alertmanager_user":          "4",
+				"alertmanager_url":           "http://alertmanager",
+				"graphite_user":              "5",
+				"graphite_url":               "http://graphite",
 			})
 			assert.NoError(t, err)
 		})
@@ -193,20 +234,38 @@ func TestConfig(t *testing.T) {
 
 		t.Run("Read Updated Configuration (set prometheus_user) - pass", func(t *testing.T) {
 			err := testConfigRead(b, reqStorage, map[string]interface{}{
-				"key":               key,
-				"url":               "http://grafanacloud:19090",
-				"organisation":      organisation1,
-				"user":              "6",
-				"prometheus_user":   "6",
-				"prometheus_url":    "http://prometheus",
-				"loki_user":         "2",
-				"loki_url":          "http://loki",
-				"tempo_user":        "3",
-				"tempo_url":         "http://tempo",
-				"alertmanager_user": "4",
-				"alertmanager_url":  "http://alertmanager",
-				"graphite_user":     "5",
-				"graphite_url":      "http://graphite",
+				"key":                        key,
+				"url":                        "http://grafanacloud:19090",
+				"organisation":               organisation1,
+				"verify_revocation":          false,
+				"events_to_loki":             false,
+				"maintenance_mode":           false,
+				"seal_wrap_issuance_records": false,
+				"debug_paths":                false,
+				"webhook_url":                "",
+				"webhook_secret":             "",
+				"max_outstanding_keys":       0,
+				"secondary_key":              "",
+				"fallback_url":               "",
+				"key_expires_at":             "",
+				"token_prefix":               "",
+				"environment":                "",
+				"tls_min_version":            "",
+				"stack_cache_ttl":            int64(300),
+				"issuance_rate_window":       int64(0),
+				"issuance_rate_warn":         0,
+				"issuance_rate_deny":         0,
+				"user":                       "6",
+				"prometheus_user":            "6",
+				"prometheus_url":             "http://prometheus",
+				"loki_user":                  "2",
+				"loki_url":                   "http://loki",
+				"tempo_user":                 "3",
+				"tempo_url":                  "http://tempo",
+				"alertmanager_user":          "4",
+				"alertmanager_url":           "http://alertmanager",
+				"graphite_user":              "5",
+				"graphite_url":               "http://graphite",
 			})
 			assert.NoError(t, err)
 		})
@@ -302,3 +361,386 @@ func testConfigRead(b logical.Backend, s logical.Storage, expected map[string]in
 
 	return nil
 }
+
+func TestConfigBulkEndpoints(t *testing.T) {
+	b, reqStorage := getTestBackend(t)
+
+	t.Run("Create Configuration - unknown service", func(t *testing.T) {
+		err := testConfigCreate(b, reqStorage, map[string]interface{}{
+			"key":          key,
+			"url":          configURL,
+			"organisation": organisation,
+			"endpoints": map[string]interface{}{
+				"mimir": map[string]interface{}{"user": "1"},
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("Create Configuration - invalid endpoint url", func(t *testing.T) {
+		err := testConfigCreate(b, reqStorage, map[string]interface{}{
+			"key":          key,
+			"url":          configURL,
+			"organisation": organisation,
+			"endpoints": map[string]interface{}{
+				"loki": map[string]interface{}{"url": "/l"},
+			},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("Create Configuration (bulk endpoints) - pass", func(t *testing.T) {
+		err := testConfigCreate(b, reqStorage, map[string]interface{}{
+			"key":          key,
+			"url":          configURL,
+			"organisation": organisation,
+			"endpoints": map[string]interface{}{
+				"prometheus": map[string]interface{}{"user": testPrometheusUser, "url": testPrometheusURL},
+				"loki":       map[string]interface{}{"user": testLokiUser, "url": testLokiURL},
+				"graphite":   map[string]interface{}{"user": testGraphiteUser},
+			},
+			"graphite_url": testGraphiteURL,
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Read Configuration (bulk endpoints) - pass", func(t *testing.T) {
+		err := testConfigRead(b, reqStorage, map[string]interface{}{
+			"key":                        key,
+			"url":                        normalizedConfigURL,
+			"organisation":               organisation,
+			"verify_revocation":          false,
+			"events_to_loki":             false,
+			"maintenance_mode":           false,
+			"seal_wrap_issuance_records": false,
+			"debug_paths":                false,
+			"webhook_url":                "",
+			"webhook_secret":             "",
+			"max_outstanding_keys":       0,
+			"secondary_key":              "",
+			"fallback_url":               "",
+			"key_expires_at":             "",
+			"token_prefix":               "",
+			"environment":                "",
+			"tls_min_version":            "",
+			"stack_cache_ttl":            int64(300),
+			"issuance_rate_window":       int64(0),
+			"issuance_rate_warn":         0,
+			"issuance_rate_deny":         0,
+			"user":                       testPrometheusUser,
+			"prometheus_user":            testPrometheusUser,
+			"prometheus_url":             testPrometheusURL,
+			"loki_user":                  testLokiUser,
+			"loki_url":                   testLokiURL,
+			"tempo_user":                 "",
+			"tempo_url":                  "",
+			"alertmanager_user":          "",
+			"alertmanager_url":           "",
+			"graphite_user":              testGraphiteUser,
+			"graphite_url":               testGraphiteURL,
+		})
+		assert.NoError(t, err)
+	})
+}
+
+func TestConfigNotConfigured(t *testing.T) {
+	b, reqStorage := getTestBackend(t)
+
+	t.Run("Read Configuration - not configured", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      configStoragePath,
+			Storage:   reqStorage,
+		})
+
+		assert.NoError(t, err)
+		assert.True(t, resp.IsError())
+		assert.EqualError(t, resp.Error(), "backend not configured")
+	})
+
+	t.Run("Revoke Key - not configured", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Storage:   reqStorage,
+			Secret: &logical.Secret{
+				InternalData: map[string]interface{}{
+					"secret_type": grafanaCloudKeyType,
+					"name":        "role_key",
+				},
+			},
+		})
+
+		assert.Nil(t, resp)
+		assert.Error(t, err)
+	})
+}
+
+func TestConfigRegions(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	err := testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          configURL,
+		"organisation": organisation,
+		"regions":      []string{"prod-eu-west-0=https://eu.grafana.example/api", "prod-us-central-0=https://us.grafana.example/"},
+	})
+	assert.NoError(t, err)
+
+	config, err := getConfig(context.Background(), s)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"prod-eu-west-0":    "https://eu.grafana.example",
+		"prod-us-central-0": "https://us.grafana.example",
+	}, config.Regions)
+	assert.Equal(t, "https://eu.grafana.example", config.regionURL("prod-eu-west-0"))
+	assert.Equal(t, normalizedConfigURL, config.regionURL("prod-ap-southeast-0"))
+
+	err = testConfigUpdate(b, s, map[string]interface{}{
+		"regions": map[string]interface{}{"prod-eu-west-0": "eu.grafana.example"},
+	})
+	assert.EqualError(t, err, `invalid configuration: invalid url for region "prod-eu-west-0"`)
+}
+
+func TestConfigDeleteInUse(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	err := testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          configURL,
+		"organisation": organisation,
+	})
+	assert.NoError(t, err)
+
+	_, err = testTokenRoleCreate(t, b, s, "in-use", map[string]interface{}{
+		"gc_role": "Viewer",
+	})
+	assert.NoError(t, err)
+
+	t.Run("Delete Configuration - roles exist", func(t *testing.T) {
+		err := testConfigDelete(b, s)
+		assert.EqualError(t, err, "config is still used by 1 roles and 0 outstanding leases, their keys cannot be revoked without it;"+
+			" delete the roles and revoke the leases first or set force=true")
+	})
+
+	err = s.Delete(context.Background(), "roles/in-use")
+	assert.NoError(t, err)
+
+	err = putIssuanceRecord(context.Background(), s, &issuanceRecord{Name: "in-use_key", Role: "in-use"})
+	assert.NoError(t, err)
+
+	t.Run("Delete Configuration - leases outstanding", func(t *testing.T) {
+		err := testConfigDelete(b, s)
+		assert.EqualError(t, err, "config is still used by 0 roles and 1 outstanding leases, their keys cannot be revoked without it;"+
+			" delete the roles and revoke the leases first or set force=true")
+	})
+
+	t.Run("Delete Configuration - forced", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.DeleteOperation,
+			Path:      configStoragePath,
+			Storage:   s,
+			Data:      map[string]interface{}{"force": true},
+		})
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+
+		config, err := getConfig(context.Background(), s)
+		assert.NoError(t, err)
+		assert.Nil(t, config)
+	})
+}
+
+func TestNormalizeURL(t *testing.T) {
+	t.Run("API URL", func(t *testing.T) {
+		for in, expected := range map[string]string{
+			"https://grafana.com/api":            "https://grafana.com",
+			"https://grafana.com/api/":           "https://grafana.com",
+			"HTTPS://Grafana.com/API//":          "https://grafana.com",
+			"https://grafana.com":                "https://grafana.com",
+			"https://gateway.internal/grafana/":  "https://gateway.internal/grafana",
+			"https://gateway.internal/v1/api":    "https://gateway.internal/v1",
+			"https://gateway.internal/apiserver": "https://gateway.internal/apiserver",
+		} {
+			normalized, err := normalizeAPIURL(in)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, normalized, in)
+		}
+	})
+
+	t.Run("Endpoint URL keeps /api", func(t *testing.T) {
+		normalized, err := normalizeURL("prometheus_url", "https://Prometheus-prod.grafana.net/api/prom/")
+		assert.NoError(t, err)
+		assert.Equal(t, "https://prometheus-prod.grafana.net/api/prom", normalized)
+	})
+
+	t.Run("Invalid URL", func(t *testing.T) {
+		_, err := normalizeURL("loki_url", "/loki")
+		assert.EqualError(t, err, "invalid configuration: invalid loki_url")
+	})
+}
+
+func TestConfigLegacyUserMigration(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	legacy := map[string]interface{}{
+		"organisation":    organisation,
+		"key":             key,
+		"url":             normalizedConfigURL,
+		"user":            "7",
+		"prometheus_user": "",
+		"loki_user":       "2",
+	}
+	entry, err := logical.StorageEntryJSON(configStoragePath, legacy)
+	require.NoError(t, err)
+	require.NoError(t, s.Put(context.Background(), entry))
+
+	readConfig := func(t *testing.T) *logical.Response {
+		t.Helper()
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      configStoragePath,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+
+		return resp
+	}
+
+	resp := readConfig(t)
+	assert.Equal(t, "7", resp.Data["prometheus_user"])
+	assert.Equal(t, "7", resp.Data["user"])
+	assert.Equal(t, []string{legacyUserWarning}, resp.Warnings)
+
+	require.NoError(t, b.Initialize(context.Background(), &logical.InitializationRequest{Storage: s}))
+
+	stored, err := s.Get(context.Background(), configStoragePath)
+	require.NoError(t, err)
+
+	var raw map[string]interface{}
+	require.NoError(t, stored.DecodeJSON(&raw))
+	assert.NotContains(t, raw, "user")
+	assert.Equal(t, "7", raw["prometheus_user"])
+	assert.Equal(t, "2", raw["loki_user"])
+
+	resp = readConfig(t)
+	assert.Equal(t, "7", resp.Data["prometheus_user"])
+	assert.Empty(t, resp.Warnings)
+}
+
+func TestConfigSealWrapIssuanceRecords(t *testing.T) {
+	ctx := context.Background()
+	b, s := getTestBackend(t)
+
+	require.Contains(t, b.SpecialPaths().SealWrapStorage, sealWrappedIssuancePrefix)
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          configURL,
+		"organisation": organisation,
+	}))
+
+	require.NoError(t, putIssuanceRecord(ctx, s, &issuanceRecord{Name: "outstanding", Role: "role"}))
+	require.NoError(t, putIssuanceRecord(ctx, s, &issuanceRecord{Name: "revoked", Role: "role"}))
+	require.NoError(t, revokeIssuanceRecord(ctx, s, "revoked", time.Now()))
+
+	stored := func(t *testing.T) []string {
+		t.Helper()
+
+		var keys []string
+
+		for _, prefix := range []string{issuedStoragePrefix, revokedStoragePrefix, "sealed/issued/", "sealed/revoked/"} {
+			names, err := s.List(ctx, prefix)
+			require.NoError(t, err)
+
+			for _, name := range names {
+				keys = append(keys, prefix+name)
+			}
+		}
+
+		return keys
+	}
+
+	assert.Equal(t, []string{"issued/outstanding", "revoked/revoked"}, stored(t))
+
+	t.Run("Enabled", func(t *testing.T) {
+		require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{"seal_wrap_issuance_records": true}))
+		assert.Equal(t, []string{"sealed/issued/outstanding", "sealed/revoked/revoked"}, stored(t))
+
+		require.NoError(t, putIssuanceRecord(ctx, s, &issuanceRecord{Name: "new", Role: "role"}))
+		require.NoError(t, revokeIssuanceRecord(ctx, s, "outstanding", time.Now()))
+		assert.Equal(t, []string{"sealed/issued/new", "sealed/revoked/outstanding", "sealed/revoked/revoked"}, stored(t))
+
+		records, err := issuanceHistory(ctx, s)
+		require.NoError(t, err)
+		assert.Len(t, records, 3)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{"seal_wrap_issuance_records": false}))
+		assert.Equal(t, []string{"issued/new", "revoked/outstanding", "revoked/revoked"}, stored(t))
+
+		record, err := getIssuanceRecord(ctx, s, "new")
+		require.NoError(t, err)
+		assert.Equal(t, "role", record.Role)
+	})
+}
+
+func TestConfigDetectOrganisation(t *testing.T) {
+	server := clienttest.NewServer(organisation, key)
+	defer server.Close()
+
+	create := func(t *testing.T, adminKey string) (*logical.Response, logical.Storage, *grafanaCloudBackend) {
+		t.Helper()
+
+		b, s := getTestBackend(t)
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      configStoragePath,
+			Data:      map[string]interface{}{"key": adminKey, "url": server.URL + "/api"},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+
+		return resp, s, b
+	}
+
+	t.Run("No stack", func(t *testing.T) {
+		resp, _, _ := create(t, key)
+		require.EqualError(t, resp.Error(), "invalid configuration: missing organisation, it could not be detected as the key has access to no stack")
+	})
+
+	server.AddStack("teststack")
+
+	t.Run("Detected", func(t *testing.T) {
+		resp, s, b := create(t, key)
+		require.False(t, resp.IsError())
+		assert.Equal(t, []string{"organisation not set, detected " + organisation + " from the stacks the key has access to"}, resp.Warnings)
+
+		config, err := getConfig(context.Background(), s)
+		require.NoError(t, err)
+		assert.Equal(t, organisation, config.Organisation)
+
+		// Updates keep the stored organisation.
+		require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{"token_prefix": "vault"}))
+
+		config, err = getConfig(context.Background(), s)
+		require.NoError(t, err)
+		assert.Equal(t, organisation, config.Organisation)
+	})
+
+	t.Run("Key rejected", func(t *testing.T) {
+		resp, _, _ := create(t, "wrong-key")
+		require.EqualError(t, resp.Error(), "invalid configuration: missing organisation, it could not be detected from the key: 401: Unauthorized")
+	})
+
+	t.Run("Several organisations", func(t *testing.T) {
+		server.AddStack("otherstack").OrgSlug = "other-org"
+
+		resp, _, _ := create(t, key)
+		require.EqualError(t, resp.Error(),
+			"invalid configuration: missing organisation, the key has access to stacks of several organisations: other-org, "+organisation)
+	})
+}
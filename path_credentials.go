@@ -2,28 +2,67 @@ package secretsengine
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
+// keyNameConflictRetries is how many times a key is created again with a new
+// name when Grafana Cloud reports that its name is already taken.
+const keyNameConflictRetries = 3
+
 // pathCredentials extends the Vault API with a `/creds`
 // endpoint for a role. You can choose whether
 // or not certain attributes should be displayed,
-// required, and named.
+// required, and named. `/token` is an alias of `/creds`.
 func pathCredentials(b *grafanaCloudBackend) *framework.Path {
 	return &framework.Path{
-		Pattern: "creds/" + framework.GenericNameRegex("name"),
+		Pattern: "(creds|token)/" + framework.GenericNameRegex("name"),
 		Fields: map[string]*framework.FieldSchema{
 			"name": {
 				Type:        framework.TypeLowerCaseString,
 				Description: "Name of the role",
 				Required:    true,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name: "Role Name",
+				},
+			},
+			"dry_run": {
+				Type: framework.TypeBool,
+				Description: "Run the checks made before issuing a key and return the name, gc_role and TTLs of the key" +
+					" that would be issued, without creating it",
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name: "Dry Run",
+				},
 			},
+			"output_format": {
+				Type: framework.TypeString,
+				Description: "Format of the issued key: json returns the token, users and URLs as separate fields," +
+					" env returns them as KEY='value' lines ready to be sourced in the env field",
+				Default:       outputFormatJSON,
+				AllowedValues: []interface{}{outputFormatJSON, outputFormatEnv},
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name: "Output Format",
+				},
+			},
+		},
+		DisplayAttrs: &framework.DisplayAttributes{
+			ItemType: "Credential",
+			Action:   "Generate",
 		},
 		Operations: map[logical.Operation]framework.OperationHandler{
-			logical.ReadOperation:   &framework.PathOperation{Callback: b.pathCredentialsRead},
-			logical.UpdateOperation: &framework.PathOperation{Callback: b.pathCredentialsRead},
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathCredentialsRead,
+				Summary:  "Generate a key for a role.",
+			},
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathCredentialsRead,
+				Summary:  "Generate a key for a role.",
+			},
 		},
 		HelpSynopsis:    pathCredentialsHelpSyn,
 		HelpDescription: pathCredentialsHelpDesc,
@@ -38,115 +77,230 @@ Generate a Grafana Cloud API key from a specific Vault role.
 //nolint:gosec // help string, not credential.
 const pathCredentialsHelpDesc = `
 This path generates a Grafana Cloud API key based on a particular role.
+It is also available as token/<name>. With dry_run=true, the role, the
+config, the issuance limits and the status of the stack are checked and the
+key that would be issued is described, but no key is created. With
+output_format=env, the token, users and URLs are returned as lines of an env
+file in the env field, e.g. GRAFANA_CLOUD_TOKEN and
+GRAFANA_CLOUD_PROMETHEUS_URL, for Vault Agent templates and CI jobs.
 `
 
-func (b *grafanaCloudBackend) createKey(ctx context.Context, s logical.Storage, roleName string, roleEntry *grafanaCloudRoleEntry) (*GrafanaCloudKey, error) {
-	client, err := b.getClient(ctx, s)
-	if err != nil {
-		return nil, err
+// createKey issues a key for the role, returning warnings to add to the response.
+func (b *grafanaCloudBackend) createKey(ctx context.Context, s logical.Storage, roleName string,
+	roleEntry *RoleEntry,
+) (*GrafanaCloudKey, []string, error) {
+	if err := checkIssuanceWindows(roleEntry.IssuanceWindows, time.Now()); err != nil {
+		return nil, nil, err
 	}
 
 	config, err := getConfig(ctx, s)
 	if err != nil {
-		return nil, NewInternalError("error reading secrets engine configuration", err)
+		return nil, nil, NewInternalError("error reading secrets engine configuration", err)
+	}
+
+	if config == nil {
+		return nil, nil, NewInvalidConfigurationError("backend not configured", nil)
 	}
 
-	var token *GrafanaCloudKey
-	token, err = createKey(ctx, client, config.Organisation, roleName, config, roleEntry.GrafanaCloudRole)
+	// Checked before the clients, whose errors would otherwise hide it.
+	if err := checkMaintenanceMode(config); err != nil {
+		return nil, nil, err
+	}
 
+	client, err := b.getClient(ctx, s)
 	if err != nil {
-		return nil, NewInternalError("error creating Grafana Cloud token", err)
+		return nil, nil, err
 	}
 
-	if token == nil {
-		return nil, NewInternalError("error creating Grafana Cloud token", nil)
+	if roleEntry.URL != "" {
+		if client, err = b.clientFor(ctx, s, config, roleEntry.URL); err != nil {
+			return nil, nil, err
+		}
 	}
 
-	return token, nil
-}
+	if err := checkOutstandingKeys(ctx, s, config); err != nil {
+		return nil, nil, err
+	}
 
-func (b *grafanaCloudBackend) createUserCreds(ctx context.Context, req *logical.Request, roleName string,
-	role *grafanaCloudRoleEntry,
-) (*logical.Response, error) {
-	key, err := b.createKey(ctx, req.Storage, roleName, role)
+	warning, err := b.issuanceRate.check(roleName, time.Now(), config)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	responseData := map[string]interface{}{
-		"token": key.Token,
+	var warnings []string
+	if warning != "" {
+		b.Logger().Warn("high key issuance rate", "role", roleName, "warning", warning)
+		warnings = append(warnings, warning)
 	}
 
-	if key.User != "" {
-		responseData["user"] = key.User
+	token, err := b.withUniqueKeyName(config, roleName, func(tokenName string) (*GrafanaCloudKey, error) {
+		return createKey(ctx, client, config.Organisation, tokenName, config, roleEntry.GrafanaCloudRole)
+	})
+	if err != nil {
+		return nil, nil, NewInternalError("error creating Grafana Cloud token", err)
 	}
 
-	if key.PrometheusUser != "" {
-		responseData["prometheus_user"] = key.PrometheusUser
+	if token == nil {
+		return nil, nil, NewInternalError("error creating Grafana Cloud token", nil)
 	}
 
-	if key.LokiUser != "" {
-		responseData["loki_user"] = key.LokiUser
+	token.URL = roleEntry.URL
+
+	return token, warnings, nil
+}
+
+// withUniqueKeyName calls create with a new key name until the name does not
+// conflict with an existing key, at most keyNameConflictRetries times more.
+// The random part of the name makes a conflict unlikely, but a 409 would
+// otherwise fail the request for a key that can simply be named differently.
+func (b *grafanaCloudBackend) withUniqueKeyName(config *Config, roleName string,
+	create func(tokenName string) (*GrafanaCloudKey, error),
+) (*GrafanaCloudKey, error) {
+	for attempt := 0; ; attempt++ {
+		tokenName := keyName(config, roleName)
+
+		start := time.Now()
+		key, err := create(tokenName)
+		b.observeLatency(apiOpCreateKey, start)
+		if err == nil || attempt >= keyNameConflictRetries || !errors.Is(err, client.ErrConflict) {
+			return key, err
+		}
+
+		b.Logger().Warn("key name already taken, retrying with a new one", "name", tokenName, "error", err)
 	}
+}
 
-	if key.TempoUser != "" {
-		responseData["tempo_user"] = key.TempoUser
+func (b *grafanaCloudBackend) createUserCreds(ctx context.Context, req *logical.Request, roleName string,
+	role *RoleEntry, dryRun bool,
+) (*logical.Response, error) {
+	if err := checkBoundCIDRs(role.BoundCIDRs, req.Connection); err != nil {
+		return errorResponse(err)
+	}
+
+	if err := checkBoundEntityMetadata(role.BoundEntityMetadata, b.System(), req.EntityID); err != nil {
+		return errorResponse(err)
 	}
 
-	if key.AlertmanagerUser != "" {
-		responseData["alertmanager_user"] = key.AlertmanagerUser
+	if dryRun {
+		return b.dryRunCreds(ctx, req, roleName, role)
 	}
 
-	if key.GraphiteUser != "" {
-		responseData["graphite_user"] = key.GraphiteUser
+	unlock, err := b.lockOutstandingKeys(ctx, req.Storage)
+	if err != nil {
+		return nil, err
 	}
 
-	if key.PrometheusURL != "" {
-		responseData["prometheus_url"] = key.PrometheusURL
+	key, warnings, err := b.createKey(ctx, req.Storage, roleName, role)
+	if err != nil {
+		unlock()
+
+		return errorResponse(err)
 	}
 
-	if key.LokiURL != "" {
-		responseData["loki_url"] = key.LokiURL
+	// Counted once created, failures and the keys of verify do not count.
+	if config, err := getConfig(ctx, req.Storage); err == nil && config != nil {
+		b.issuanceRate.count(roleName, time.Now(), config)
 	}
 
-	if key.TempoURL != "" {
-		responseData["tempo_url"] = key.TempoURL
+	// key_name and key_id identify the key as shown in Grafana Cloud, so a
+	// credential can be traced without the token.
+	responseData := key.connectionData()
+	responseData["token"] = key.Token
+	responseData["key_name"] = key.Name
+	responseData["key_id"] = key.ID
+
+	_, maxTTL := role.effectiveTTLs(b.System())
+	issuedAt := time.Now().UTC()
+	expiresAt := issuedAt.Add(maxTTL)
+
+	record := &issuanceRecord{
+		Name:        key.Name,
+		Role:        roleName,
+		URL:         key.URL,
+		IssuedAt:    issuedAt,
+		ExpiresAt:   &expiresAt,
+		EntityID:    req.EntityID,
+		DisplayName: req.DisplayName,
 	}
 
-	if key.AlertmanagerURL != "" {
-		responseData["alertmanager_url"] = key.AlertmanagerURL
+	if hmacKey, err := b.getTokenHMACKey(ctx, req.Storage); err != nil {
+		b.Logger().Warn("failed to compute the HMAC of the issued token", "name", key.Name, "error", err)
+	} else {
+		record.TokenHMAC = tokenHMAC(hmacKey, key.Token)
 	}
 
-	if key.GraphiteURL != "" {
-		responseData["graphite_url"] = key.GraphiteURL
+	// The key is usable and will be revoked through its lease either way,
+	// a missing record only hides it from the outstanding lease checks and
+	// the issuance history.
+	if err := putIssuanceRecord(ctx, req.Storage, record); err != nil {
+		b.Logger().Warn("failed to record issued key", "name", key.Name, "error", err)
 	}
 
+	unlock()
+
+	b.notify(ctx, req.Storage, webhookEventIssued, map[string]interface{}{
+		"key_name":     key.Name,
+		"key_id":       key.ID,
+		"role":         roleName,
+		"entity_id":    req.EntityID,
+		"display_name": req.DisplayName,
+	})
+
 	resp := b.Secret(grafanaCloudKeyType).Response(
 		responseData,
-		map[string]interface{}{"name": key.Name})
+		key.secretData(roleName).internalData())
 
-	if role.TTL > 0 {
-		resp.Secret.TTL = role.TTL
+	for _, warning := range warnings {
+		resp.AddWarning(warning)
 	}
 
-	if role.MaxTTL > 0 {
-		resp.Secret.MaxTTL = role.MaxTTL
+	for _, warning := range role.ttlClampWarnings(b.System().MaxLeaseTTL()) {
+		resp.AddWarning(warning)
 	}
 
+	role.setLease(resp.Secret)
+
 	return resp, nil
 }
 
+// setLease applies the TTLs and renewability of the role to the lease of a key.
+func (r *RoleEntry) setLease(secret *logical.Secret) {
+	if r.TTL > 0 {
+		secret.TTL = r.TTL
+	}
+
+	if r.MaxTTL > 0 {
+		secret.MaxTTL = r.MaxTTL
+	}
+
+	secret.Renewable = !r.NonRenewable
+}
+
 func (b *grafanaCloudBackend) pathCredentialsRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	roleName := d.Get("name").(string)
 
+	outputFormat := d.Get("output_format").(string)
+	if outputFormat != outputFormatJSON && outputFormat != outputFormatEnv {
+		return logical.ErrorResponse(fmt.Sprintf("output_format must be %s or %s", outputFormatJSON, outputFormatEnv)), nil
+	}
+
 	roleEntry, err := b.getRole(ctx, req.Storage, roleName)
 	if err != nil {
 		return nil, NewInternalError("error retrieving role", err)
 	}
 
 	if roleEntry == nil {
-		return nil, NewInternalError("error retrieving role: role is nil", nil)
+		return roleNotFoundResponse(roleName)
+	}
+
+	dryRun := d.Get("dry_run").(bool)
+
+	resp, err := b.createUserCreds(ctx, req, roleName, roleEntry, dryRun)
+	if err != nil || resp.IsError() || dryRun || outputFormat != outputFormatEnv {
+		return resp, err
 	}
 
-	return b.createUserCreds(ctx, req, roleName, roleEntry)
+	resp.Data = envResponseData(resp.Data)
+
+	return resp, nil
 }
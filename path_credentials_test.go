@@ -2,13 +2,19 @@ package secretsengine
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client/clienttest"
 	log "github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/vault/sdk/helper/logging"
 	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -80,3 +86,524 @@ func TestAcceptanceAPIKey(t *testing.T) {
 	t.Run("read api key cred", acceptanceTestEnv.ReadAPIKey)
 	t.Run("cleanup api keys", acceptanceTestEnv.CleanupAPIKeys)
 }
+
+func TestCredentialsErrors(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	readCreds := func(t *testing.T, role string) *logical.Response {
+		t.Helper()
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/" + role,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+
+		return resp
+	}
+
+	t.Run("Read Credentials - missing role", func(t *testing.T) {
+		resp := readCreds(t, "missing")
+
+		require.True(t, resp.IsError())
+		require.EqualError(t, resp.Error(), `role "missing" not found`)
+	})
+
+	t.Run("Read Credentials - not configured", func(t *testing.T) {
+		_, err := testTokenRoleCreate(t, b, s, "test-role", map[string]interface{}{
+			"gc_role": gcRole,
+		})
+		require.NoError(t, err)
+
+		resp := readCreds(t, "test-role")
+
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "backend not configured")
+	})
+
+	t.Run("Read Credentials - missing role does not list existing", func(t *testing.T) {
+		resp := readCreds(t, "test-rol")
+
+		require.True(t, resp.IsError())
+		require.EqualError(t, resp.Error(), `role "test-rol" not found`)
+	})
+
+	t.Run("Read Credentials - outside issuance window", func(t *testing.T) {
+		now := time.Now().UTC()
+		window := now.Add(2*time.Hour).Format("15:04") + "-" + now.Add(3*time.Hour).Format("15:04")
+
+		_, err := testTokenRoleCreate(t, b, s, "windowed-role", map[string]interface{}{
+			"gc_role":         gcRole,
+			"issuance_window": window,
+		})
+		require.NoError(t, err)
+
+		resp := readCreds(t, "windowed-role")
+
+		require.True(t, resp.IsError())
+		require.Contains(t, resp.Error().Error(), "issuance denied: keys for this role can only be issued during "+window+" (UTC)")
+		require.Equal(t, map[string]interface{}{"deny_reason": "issuance_window"}, resp.Data["data"])
+	})
+
+	t.Run("Write Role - invalid issuance window", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "windowed-role", map[string]interface{}{
+			"gc_role":         gcRole,
+			"issuance_window": "weekdays",
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+}
+
+func TestCloudAPIKeyCredentials(t *testing.T) {
+	server := clienttest.NewServer(organisation, key)
+	defer server.Close()
+
+	b, s := getTestBackend(t)
+
+	err := testConfigCreate(b, s, map[string]interface{}{
+		"key":             key,
+		"url":             server.URL + "/api",
+		"organisation":    organisation,
+		"prometheus_user": testPrometheusUser,
+		"prometheus_url":  testPrometheusURL,
+	})
+	require.NoError(t, err)
+
+	_, err = testTokenRoleCreate(t, b, s, "cloud-role", map[string]interface{}{
+		"gc_role": "MetricsPublisher",
+	})
+	require.NoError(t, err)
+
+	readCreds := func(t *testing.T) *logical.Response {
+		t.Helper()
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/cloud-role",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+
+		return resp
+	}
+
+	revoke := func(secret *logical.Secret) error {
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			ID:        "revoke-request",
+			Operation: logical.RevokeOperation,
+			Storage:   s,
+			Secret:    secret,
+		})
+
+		return err
+	}
+
+	t.Run("Issue Cloud API Key", func(t *testing.T) {
+		resp := readCreds(t)
+
+		name := resp.Secret.InternalData["name"].(string)
+		cloudKey := server.CloudKey(name)
+		require.NotNil(t, cloudKey)
+		require.Equal(t, "MetricsPublisher", cloudKey.Role)
+		require.Equal(t, cloudKey.Token, resp.Data["token"])
+		require.Equal(t, name, resp.Data["key_name"])
+		require.Equal(t, int64(cloudKey.ID), resp.Data["key_id"])
+		require.Equal(t, testPrometheusUser, resp.Data["prometheus_user"])
+		require.Equal(t, testPrometheusURL, resp.Data["prometheus_url"])
+		require.Equal(t, testPrometheusUser, resp.Data["prometheus_tenant_id"])
+		require.Equal(t, "X-Scope-OrgID", resp.Data["tenant_header"])
+		require.NotContains(t, resp.Data, "loki_tenant_id")
+
+		require.NoError(t, revoke(resp.Secret))
+		require.Empty(t, server.CloudKeys())
+	})
+
+	t.Run("Issue Cloud API Key - token alias", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "token/cloud-role",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+		require.NotEmpty(t, resp.Data["token"])
+		require.Equal(t, "cloud-role", resp.Secret.InternalData["role"])
+
+		require.NoError(t, revoke(resp.Secret))
+		require.Empty(t, server.CloudKeys())
+	})
+
+	t.Run("Env output format", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/cloud-role",
+			Data:      map[string]interface{}{"output_format": "env"},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+
+		name := resp.Secret.InternalData["name"].(string)
+		cloudKey := server.CloudKey(name)
+		require.NotNil(t, cloudKey)
+
+		require.Equal(t, name, resp.Data["key_name"])
+		require.NotContains(t, resp.Data, "token")
+		require.Equal(t, fmt.Sprintf("GRAFANA_CLOUD_KEY_ID='%d'\n", cloudKey.ID)+
+			"GRAFANA_CLOUD_KEY_NAME='"+name+"'\n"+
+			"GRAFANA_CLOUD_PROMETHEUS_TENANT_ID='"+testPrometheusUser+"'\n"+
+			"GRAFANA_CLOUD_PROMETHEUS_URL='"+testPrometheusURL+"'\n"+
+			"GRAFANA_CLOUD_PROMETHEUS_USER='"+testPrometheusUser+"'\n"+
+			"GRAFANA_CLOUD_TENANT_HEADER='X-Scope-OrgID'\n"+
+			"GRAFANA_CLOUD_TOKEN='"+cloudKey.Token+"'\n", resp.Data["env"])
+
+		require.NoError(t, revoke(resp.Secret))
+	})
+
+	t.Run("Unknown output format", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/cloud-role",
+			Data:      map[string]interface{}{"output_format": "yaml"},
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.EqualError(t, resp.Error(), "output_format must be json or env")
+		require.Empty(t, server.CloudKeys())
+	})
+
+	t.Run("Issue Cloud API Key - name conflict", func(t *testing.T) {
+		createPath := "/api/orgs/" + organisation + "/api-keys"
+		server.InjectErrorTimes(http.MethodPost, createPath, http.StatusConflict, "API key with this name already exists", 2)
+
+		countCreates := func() int {
+			count := 0
+
+			for _, r := range server.Requests() {
+				if r.Method == http.MethodPost && r.Path == createPath {
+					count++
+				}
+			}
+
+			return count
+		}
+
+		before := countCreates()
+
+		resp := readCreds(t)
+		require.Equal(t, 3, countCreates()-before, "retried with new names until one was free")
+		require.NotNil(t, server.CloudKey(resp.Secret.InternalData["name"].(string)))
+		require.NoError(t, revoke(resp.Secret))
+
+		server.InjectError(http.MethodPost, createPath, http.StatusConflict, "API key with this name already exists")
+		defer server.ClearErrors()
+
+		before = countCreates()
+
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			ID:        "creds-request",
+			Operation: logical.ReadOperation,
+			Path:      "creds/cloud-role",
+			Storage:   s,
+		})
+		require.EqualError(t, err, "internal error: error creating Grafana Cloud token: 409: API key with this name already exists"+
+			" (correlation_id: creds-request)")
+		require.Equal(t, keyNameConflictRetries+1, countCreates()-before)
+	})
+
+	t.Run("Issue Cloud API Key - token prefix", func(t *testing.T) {
+		require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{
+			"token_prefix": "cluster-a",
+		}))
+		defer func() {
+			require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{
+				"token_prefix": "",
+			}))
+		}()
+
+		resp := readCreds(t)
+		require.True(t, strings.HasPrefix(resp.Data["key_name"].(string), "cluster-a_cloud-role_"))
+		require.NotNil(t, server.CloudKey(resp.Data["key_name"].(string)))
+
+		require.NoError(t, revoke(resp.Secret))
+	})
+
+	t.Run("Issue Cloud API Key - environment", func(t *testing.T) {
+		require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{
+			"environment": "prod-eu",
+		}))
+		defer func() {
+			require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{
+				"environment": "",
+			}))
+		}()
+
+		resp := readCreds(t)
+		name := resp.Data["key_name"].(string)
+		require.True(t, strings.HasPrefix(name, "cloud-role_"))
+		require.True(t, strings.HasSuffix(name, "_prod-eu"))
+		require.NotNil(t, server.CloudKey(name))
+
+		require.NoError(t, revoke(resp.Secret))
+	})
+
+	t.Run("Issue Cloud API Key - max outstanding keys", func(t *testing.T) {
+		require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{
+			"max_outstanding_keys": 1,
+		}))
+		defer func() {
+			require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{
+				"max_outstanding_keys": 0,
+			}))
+		}()
+
+		resp := readCreds(t)
+
+		denied, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/cloud-role",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.EqualError(t, denied.Error(), "issuance denied: 1 keys are outstanding, the limit set by max_outstanding_keys is 1")
+		require.Equal(t, map[string]interface{}{"deny_reason": "max_outstanding_keys"}, denied.Data["data"])
+		require.Len(t, server.CloudKeys(), 1)
+
+		require.NoError(t, revoke(resp.Secret))
+
+		resp = readCreds(t)
+		require.NoError(t, revoke(resp.Secret))
+	})
+
+	t.Run("Issue Cloud API Key - max outstanding keys, concurrently", func(t *testing.T) {
+		require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{
+			"max_outstanding_keys": 2,
+		}))
+		defer func() {
+			require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{
+				"max_outstanding_keys": 0,
+			}))
+		}()
+
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			secrets []*logical.Secret
+		)
+
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				resp, err := b.HandleRequest(context.Background(), &logical.Request{
+					Operation: logical.ReadOperation,
+					Path:      "creds/cloud-role",
+					Storage:   s,
+				})
+				if err == nil && !resp.IsError() {
+					mu.Lock()
+					secrets = append(secrets, resp.Secret)
+					mu.Unlock()
+				}
+			}()
+		}
+
+		wg.Wait()
+
+		require.Len(t, secrets, 2)
+		require.Len(t, server.CloudKeys(), 2)
+
+		for _, secret := range secrets {
+			require.NoError(t, revoke(secret))
+		}
+	})
+
+	t.Run("Issue Cloud API Key - upstream unavailable", func(t *testing.T) {
+		server.InjectError(http.MethodPost, "/api/orgs/"+organisation+"/api-keys", http.StatusBadGateway, "bad gateway")
+		defer server.ClearErrors()
+
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			ID:        "creds-request",
+			Operation: logical.ReadOperation,
+			Path:      "creds/cloud-role",
+			Storage:   s,
+		})
+
+		var coded logical.HTTPCodedError
+		require.ErrorAs(t, err, &coded)
+		require.Equal(t, http.StatusServiceUnavailable, coded.Code())
+		require.EqualError(t, err, "internal error: error creating Grafana Cloud token: 502: bad gateway;"+
+			" Grafana Cloud is unavailable, retry in 30 seconds (correlation_id: creds-request)")
+	})
+
+	t.Run("Issue Cloud API Key - non-renewable role", func(t *testing.T) {
+		_, err := testTokenRoleCreate(t, b, s, "non-renewable-role", map[string]interface{}{
+			"gc_role":   "MetricsPublisher",
+			"renewable": false,
+		})
+		require.NoError(t, err)
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/non-renewable-role",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+		require.False(t, resp.Secret.Renewable)
+
+		renewResp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RenewOperation,
+			Storage:   s,
+			Secret:    resp.Secret,
+		})
+		require.NoError(t, err)
+		require.EqualError(t, renewResp.Error(), `role "non-renewable-role" does not allow renewal, issue a new key instead`)
+
+		require.NoError(t, revoke(resp.Secret))
+	})
+
+	t.Run("Revoke Cloud API Key - upstream error", func(t *testing.T) {
+		resp := readCreds(t)
+		name := resp.Secret.InternalData["name"].(string)
+
+		server.InjectError(http.MethodDelete, "/api/orgs/"+organisation+"/api-keys/"+name, http.StatusServiceUnavailable, "try again later")
+		defer server.ClearErrors()
+
+		err := revoke(resp.Secret)
+		require.EqualError(t, err, "internal error: error deleting Grafana Cloud key: 503: try again later (correlation_id: revoke-request)")
+		require.Equal(t, []string{name}, server.CloudKeys())
+
+		// The lease is still outstanding, so its record is kept.
+		issued, err := listIssuanceRecords(context.Background(), s)
+		require.NoError(t, err)
+		require.Equal(t, []string{name}, issued)
+	})
+
+	deletes := func(name string) int {
+		count := 0
+
+		for _, r := range server.Requests() {
+			if r.Method == http.MethodDelete && r.Path == "/api/orgs/"+organisation+"/api-keys/"+name {
+				count++
+			}
+		}
+
+		return count
+	}
+
+	t.Run("Revoke Cloud API Key - verified, misleading error", func(t *testing.T) {
+		require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{"verify_revocation": true}))
+		defer func() {
+			require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{"verify_revocation": false}))
+		}()
+
+		resp := readCreds(t)
+		name := resp.Secret.InternalData["name"].(string)
+
+		server.InjectMisleadingError(http.MethodDelete, "/api/orgs/"+organisation+"/api-keys/"+name, http.StatusBadGateway, "bad gateway")
+		defer server.ClearErrors()
+
+		require.NoError(t, revoke(resp.Secret))
+		require.Nil(t, server.CloudKey(name))
+		require.Equal(t, 1, deletes(name))
+	})
+
+	t.Run("Revoke Cloud API Key - verified, key still exists", func(t *testing.T) {
+		require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{"verify_revocation": true}))
+		defer func() {
+			require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{"verify_revocation": false}))
+		}()
+
+		resp := readCreds(t)
+		name := resp.Secret.InternalData["name"].(string)
+
+		server.InjectError(http.MethodDelete, "/api/orgs/"+organisation+"/api-keys/"+name, http.StatusServiceUnavailable, "try again later")
+
+		err := revoke(resp.Secret)
+		require.EqualError(t, err, "internal error: error deleting Grafana Cloud key: 503: try again later (correlation_id: revoke-request)")
+		require.NotNil(t, server.CloudKey(name))
+		require.Equal(t, 2, deletes(name), "the deletion is retried once")
+
+		server.ClearErrors()
+
+		require.NoError(t, revoke(resp.Secret))
+		require.Nil(t, server.CloudKey(name))
+	})
+}
+
+func TestMaintenanceMode(t *testing.T) {
+	server := clienttest.NewServer(organisation, key)
+	defer server.Close()
+
+	b, s := getTestBackend(t)
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          server.URL + "/api",
+		"organisation": organisation,
+	}))
+
+	_, err := testTokenRoleCreate(t, b, s, "cloud-role", map[string]interface{}{
+		"gc_role": "MetricsPublisher",
+	})
+	require.NoError(t, err)
+
+	readCreds := func(t *testing.T, data map[string]interface{}) *logical.Response {
+		t.Helper()
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      "creds/cloud-role",
+			Data:      data,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+
+		return resp
+	}
+
+	issued := readCreds(t, nil)
+	require.False(t, issued.IsError())
+
+	require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{"maintenance_mode": true}))
+
+	t.Run("Issuance is refused", func(t *testing.T) {
+		for _, data := range []map[string]interface{}{nil, {"dry_run": true}} {
+			resp := readCreds(t, data)
+			require.EqualError(t, resp.Error(), "issuance denied: issuance is temporarily disabled, the mount is in maintenance mode")
+			require.Equal(t, map[string]interface{}{"deny_reason": denyReasonMaintenanceMode}, resp.Data["data"])
+		}
+
+		require.Len(t, server.CloudKeys(), 1)
+	})
+
+	t.Run("Leases are renewed and revoked", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RenewOperation,
+			Storage:   s,
+			Secret:    issued.Secret,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Storage:   s,
+			Secret:    issued.Secret,
+		})
+		require.NoError(t, err)
+		require.Empty(t, server.CloudKeys())
+	})
+
+	t.Run("Issuance resumes", func(t *testing.T) {
+		require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{"maintenance_mode": false}))
+		require.False(t, readCreds(t, nil).IsError())
+	})
+}
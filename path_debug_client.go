@@ -0,0 +1,118 @@
+package secretsengine
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// pathDebugClient extends the Vault API with a `/debug/client`
+// endpoint describing the cached Grafana Cloud clients and
+// stacks, served when debug_paths is enabled in the config.
+func pathDebugClient(b *grafanaCloudBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "debug/client",
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathDebugClientRead,
+				Summary:  "Describe the cached Grafana Cloud clients and stacks.",
+			},
+		},
+		HelpSynopsis:    pathDebugClientHelpSyn,
+		HelpDescription: pathDebugClientHelpDesc,
+	}
+}
+
+const pathDebugClientHelpSyn = `
+Describe the cached Grafana Cloud clients and stacks.
+`
+
+const pathDebugClientHelpDesc = `
+Requires debug_paths to be enabled in the config. This path returns the state
+of the node it is served by: the client of the admin key and those used
+against the url of roles, each with its base URL, authentication and age, and
+the stacks cached for stack_cache_ttl with their status, region and age. A
+client is stale when it no longer matches the stored config, which happens
+when the config changed without the caches being reset. Keys are never
+returned and no request is made to Grafana Cloud.
+`
+
+func (b *grafanaCloudBackend) pathDebugClientRead(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	config, err := getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		return logical.ErrorResponse("backend not configured"), nil
+	}
+
+	if !config.DebugPaths {
+		return logical.ErrorResponse("debug paths are disabled, set debug_paths=true in the config"), nil
+	}
+
+	now := time.Now()
+
+	b.lock.RLock()
+	cached := b.client
+
+	urlClients := map[string]interface{}{}
+	for apiURL, urlClient := range b.urlClients {
+		urlClients[apiURL] = urlClient.debugData(now, apiURL, clientAuthAdminKey, config.Key != "")
+	}
+	b.lock.RUnlock()
+
+	var adminClient map[string]interface{}
+
+	if cached != nil {
+		auth := clientAuthAdminKey
+		if config.SecondaryKey != "" {
+			auth = clientAuthAdminKeyFallback
+		}
+
+		baseURL, _ := normalizeAPIURL(config.URL)
+		adminClient = cached.debugData(now, baseURL, auth, true)
+	}
+
+	stacks := map[string]interface{}{}
+	for slug, entry := range b.stacks.entries() {
+		stacks[slug] = map[string]interface{}{
+			"status":      entry.stack.Status,
+			"region":      entry.stack.RegionSlug,
+			"url":         entry.stack.URL,
+			"fetched_at":  entry.fetchedAt.UTC().Format(time.RFC3339),
+			"age_seconds": int64(now.Sub(entry.fetchedAt).Seconds()),
+			"expired":     now.Sub(entry.fetchedAt) >= config.stackCacheTTL(),
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"client":          adminClient,
+			"url_clients":     urlClients,
+			"stacks":          stacks,
+			"stack_cache_ttl": int64(config.stackCacheTTL().Seconds()),
+		},
+	}, nil
+}
+
+// debugData describes the client for debug/client. It is stale when its
+// base URL or authentication differ from those the config now yields, or
+// when the config no longer has its key.
+func (c *cachedClient) debugData(now time.Time, baseURL, auth string, keyConfigured bool) map[string]interface{} {
+	data := map[string]interface{}{
+		"base_url":    c.baseURL,
+		"auth":        c.auth,
+		"created_at":  c.createdAt.UTC().Format(time.RFC3339),
+		"age_seconds": int64(now.Sub(c.createdAt).Seconds()),
+		"stale":       c.baseURL != baseURL || c.auth != auth || !keyConfigured,
+	}
+
+	if c.failover != nil {
+		data["failed_over"] = c.failover.failedOver()
+	}
+
+	return data
+}
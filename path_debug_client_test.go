@@ -0,0 +1,90 @@
+package secretsengine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client/clienttest"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugClient(t *testing.T) {
+	server := clienttest.NewServer(organisation, key)
+	defer server.Close()
+
+	server.AddStack("teststack")
+
+	b, s := getTestBackend(t)
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          server.URL + "/api",
+		"organisation": organisation,
+	}))
+
+	read := func(t *testing.T) *logical.Response {
+		t.Helper()
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "debug/client",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+
+		return resp
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		require.EqualError(t, read(t).Error(), "debug paths are disabled, set debug_paths=true in the config")
+	})
+
+	require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{"debug_paths": true}))
+
+	t.Run("Nothing cached", func(t *testing.T) {
+		resp := read(t)
+		require.False(t, resp.IsError())
+		assert.Nil(t, resp.Data["client"])
+		assert.Empty(t, resp.Data["stacks"])
+	})
+
+	_, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "stack-info/teststack",
+		Storage:   s,
+	})
+	require.NoError(t, err)
+
+	baseURL, err := normalizeAPIURL(server.URL + "/api")
+	require.NoError(t, err)
+
+	t.Run("Cached", func(t *testing.T) {
+		resp := read(t)
+		require.False(t, resp.IsError())
+
+		adminClient := resp.Data["client"].(map[string]interface{})
+		assert.Equal(t, baseURL, adminClient["base_url"])
+		assert.Equal(t, clientAuthAdminKey, adminClient["auth"])
+		assert.Equal(t, false, adminClient["stale"])
+		assert.NotContains(t, adminClient, "failed_over")
+
+		stack := resp.Data["stacks"].(map[string]interface{})["teststack"].(map[string]interface{})
+		assert.Equal(t, "active", stack["status"])
+		assert.Equal(t, false, stack["expired"])
+	})
+
+	t.Run("Stale", func(t *testing.T) {
+		// Changed in storage without the caches being reset.
+		config, err := getConfig(context.Background(), s)
+		require.NoError(t, err)
+
+		config.URL = "https://grafana.example"
+		require.NoError(t, putConfig(context.Background(), s, config))
+
+		adminClient := read(t).Data["client"].(map[string]interface{})
+		assert.Equal(t, baseURL, adminClient["base_url"])
+		assert.Equal(t, true, adminClient["stale"])
+	})
+}
@@ -0,0 +1,97 @@
+package secretsengine
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// pathDeprecations extends the Vault API with a `/deprecations`
+// endpoint listing the config and roles relying on
+// deprecated features.
+func pathDeprecations(b *grafanaCloudBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "deprecations/?$",
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathDeprecationsRead,
+				Summary:  "List the config and roles relying on deprecated features.",
+			},
+		},
+		HelpSynopsis:    pathDeprecationsHelpSyn,
+		HelpDescription: pathDeprecationsHelpDesc,
+	}
+}
+
+const pathDeprecationsHelpSyn = `
+List the config and roles relying on deprecated features.
+`
+
+const pathDeprecationsHelpDesc = `
+This path returns what has to change before deprecated features are removed:
+the config when it is still stored with the legacy user field, and roles
+whose gc_role, e.g. Admin, is no longer accepted by the config. Each entry
+says what to migrate to. No request is made to Grafana Cloud.
+`
+
+func (b *grafanaCloudBackend) pathDeprecationsRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	configDeprecations := []string{}
+
+	if config != nil && config.legacyUserPending {
+		configDeprecations = append(configDeprecations, "the config is stored with the legacy user field, "+
+			"write the config or reload the plugin to store it as prometheus_user")
+	}
+
+	names, err := req.Storage.List(ctx, "roles/")
+	if err != nil {
+		return nil, NewInternalError("error listing roles", err)
+	}
+
+	count := len(configDeprecations)
+	roles := map[string]interface{}{}
+
+	for _, name := range names {
+		role, err := b.getRole(ctx, req.Storage, name)
+		if err != nil {
+			return nil, err
+		}
+
+		if role == nil {
+			continue
+		}
+
+		deprecations := roleDeprecations(role, config)
+		if len(deprecations) > 0 {
+			roles[name] = deprecations
+			count += len(deprecations)
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"count":  count,
+			"config": configDeprecations,
+			"roles":  roles,
+		},
+	}, nil
+}
+
+// roleDeprecations returns the deprecated features the role relies on, with
+// what to migrate to.
+func roleDeprecations(role *RoleEntry, config *Config) []string {
+	var deprecations []string
+
+	if !config.cloudRoles()[role.GrafanaCloudRole] {
+		deprecations = append(deprecations, fmt.Sprintf("gc_role %s is no longer accepted by the config, "+
+			"issuing keys for the role fails until it is changed to one of the valid gc_role values", role.GrafanaCloudRole))
+	}
+
+	return deprecations
+}
@@ -0,0 +1,79 @@
+package secretsengine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client/clienttest"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeprecations(t *testing.T) {
+	server := clienttest.NewServer(organisation, key)
+	defer server.Close()
+
+	b, s := getTestBackend(t)
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          server.URL + "/api",
+		"organisation": organisation,
+	}))
+
+	roles := map[string]map[string]interface{}{
+		"metrics": {"gc_role": "MetricsPublisher"},
+		"admin":   {"gc_role": "Admin"},
+	}
+
+	for name, data := range roles {
+		resp, err := testTokenRoleCreate(t, b, s, name, data)
+		require.NoError(t, err)
+		require.False(t, resp.IsError(), name)
+	}
+
+	read := func(t *testing.T) *logical.Response {
+		t.Helper()
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "deprecations",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+
+		return resp
+	}
+
+	t.Run("Nothing deprecated", func(t *testing.T) {
+		resp := read(t)
+		assert.Equal(t, 0, resp.Data["count"])
+		assert.Empty(t, resp.Data["config"])
+		assert.Empty(t, resp.Data["roles"])
+	})
+
+	t.Run("Admin denied and legacy user", func(t *testing.T) {
+		require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{
+			"valid_gc_roles": "Viewer,MetricsPublisher",
+		}))
+
+		config, err := getConfig(context.Background(), s)
+		require.NoError(t, err)
+
+		config.LegacyUser = "7"
+
+		entry, err := logical.StorageEntryJSON(configStoragePath, config)
+		require.NoError(t, err)
+		require.NoError(t, s.Put(context.Background(), entry))
+
+		resp := read(t)
+		assert.Equal(t, 2, resp.Data["count"])
+		assert.Len(t, resp.Data["config"], 1)
+		assert.Equal(t, map[string]interface{}{
+			"admin": []string{"gc_role Admin is no longer accepted by the config, " +
+				"issuing keys for the role fails until it is changed to one of the valid gc_role values"},
+		}, resp.Data["roles"])
+	})
+}
@@ -0,0 +1,73 @@
+package secretsengine
+
+import (
+	"context"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// pathEndpoints extends the Vault API with an `/endpoints`
+// endpoint returning the connection details of a role's
+// credentials without issuing a key.
+func pathEndpoints(b *grafanaCloudBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "endpoints/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Name of the role",
+				Required:    true,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name: "Role Name",
+				},
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathEndpointsRead,
+				Summary:  "Read the users and URLs returned with keys for a role.",
+			},
+		},
+		HelpSynopsis:    pathEndpointsHelpSyn,
+		HelpDescription: pathEndpointsHelpDesc,
+	}
+}
+
+const pathEndpointsHelpSyn = `
+Read the users and URLs returned with keys for a role, without issuing a key.
+`
+
+const pathEndpointsHelpDesc = `
+This path returns the users and URLs that creds/<name> returns
+alongside the token, without creating a key. It suits deployments that
+template connection details ahead of time and read keys at runtime.
+`
+
+func (b *grafanaCloudBackend) pathEndpointsRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	roleName := d.Get("name").(string)
+
+	role, err := b.getRole(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, NewInternalError("error retrieving role", err)
+	}
+
+	if role == nil {
+		return roleNotFoundResponse(roleName)
+	}
+
+	config, err := getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, NewInternalError("error reading secrets engine configuration", err)
+	}
+
+	if config == nil {
+		return logical.ErrorResponse("backend not configured"), nil
+	}
+
+	// The same details createKey sets on the keys it issues.
+	key := &GrafanaCloudKey{}
+	key.setEndpoints(&config.Endpoints)
+
+	return &logical.Response{Data: key.connectionData()}, nil
+}
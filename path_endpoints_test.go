@@ -0,0 +1,60 @@
+package secretsengine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointsRead(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	readEndpoints := func(t *testing.T, role string) *logical.Response {
+		t.Helper()
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "endpoints/" + role,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+
+		return resp
+	}
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":             key,
+		"url":             "https://grafana.com/api",
+		"organisation":    organisation,
+		"prometheus_user": testPrometheusUser,
+		"prometheus_url":  testPrometheusURL,
+		"loki_user":       "67890",
+		"loki_url":        "https://logs.grafana.net",
+	}))
+
+	resp, err := testTokenRoleCreate(t, b, s, "cloud", map[string]interface{}{"gc_role": "Viewer"})
+	require.NoError(t, err)
+	require.False(t, resp.IsError())
+
+	t.Run("Role", func(t *testing.T) {
+		resp := readEndpoints(t, "Cloud")
+		require.Equal(t, map[string]interface{}{
+			"user":                 testPrometheusUser,
+			"prometheus_user":      testPrometheusUser,
+			"prometheus_url":       testPrometheusURL,
+			"loki_user":            "67890",
+			"loki_url":             "https://logs.grafana.net",
+			"prometheus_tenant_id": testPrometheusUser,
+			"loki_tenant_id":       "67890",
+			"tenant_header":        "X-Scope-OrgID",
+		}, resp.Data)
+		require.Nil(t, resp.Secret, "no key is issued")
+	})
+
+	t.Run("Unknown role", func(t *testing.T) {
+		resp := readEndpoints(t, "missing")
+		require.EqualError(t, resp.Error(), `role "missing" not found`)
+	})
+}
@@ -0,0 +1,139 @@
+package secretsengine
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// pathHealth extends the Vault API with a `/health`
+// endpoint checking the dependencies of the backend,
+// for monitoring probes.
+func pathHealth(b *grafanaCloudBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "health",
+		Fields: map[string]*framework.FieldSchema{
+			"stack_slug": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Slug of a stack whose API should also be checked",
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathHealthRead,
+				Summary:  "Check the storage, admin key and Grafana Cloud API used by the backend.",
+			},
+		},
+		HelpSynopsis:    pathHealthHelpSyn,
+		HelpDescription: pathHealthHelpDesc,
+	}
+}
+
+const pathHealthHelpSyn = `
+Check the dependencies of the secrets engine.
+`
+
+const pathHealthHelpDesc = `
+This path checks that the plugin storage can be read, that the Grafana
+Cloud API can be reached and that the configured admin key is accepted by
+it. With stack_slug set, the health endpoint of that stack's API is checked
+too. Every check is reported with its outcome, and "healthy" is true only
+if all of them passed. No key is issued.
+`
+
+func (b *grafanaCloudBackend) pathHealthRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	checks := map[string]interface{}{}
+	healthy := true
+
+	report := func(name string, result *verifyResult) {
+		checks[name] = result.toResponseData()
+		healthy = healthy && result.OK
+	}
+
+	config, err := getConfig(ctx, req.Storage)
+	if err == nil {
+		_, err = req.Storage.List(ctx, "roles/")
+	}
+
+	report("storage", errorResult(err))
+
+	switch {
+	case err != nil:
+	case config == nil:
+		report("config", errorResult(NewInvalidConfigurationError("backend not configured", nil)))
+	default:
+		b.checkGrafanaCloud(ctx, req.Storage, config, d.Get("stack_slug").(string), report)
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"healthy": healthy,
+			"checks":  checks,
+		},
+	}
+
+	if config != nil {
+		if warning := config.adminKeyExpiryWarning(time.Now()); warning != "" {
+			resp.AddWarning(warning)
+		}
+	}
+
+	return resp, nil
+}
+
+// checkGrafanaCloud reports the checks that need the Grafana Cloud API.
+func (b *grafanaCloudBackend) checkGrafanaCloud(ctx context.Context, s logical.Storage, config *Config,
+	stackSlug string, report func(string, *verifyResult),
+) {
+	c, err := b.getClient(ctx, s)
+	if err != nil {
+		report("grafana_cloud_api", errorResult(err))
+		return
+	}
+
+	httpClient, err := b.getHTTPClient(ctx, s)
+	if err != nil {
+		report("grafana_cloud_api", errorResult(err))
+		return
+	}
+
+	// Any response proves the API is reachable, the admin key is checked separately.
+	reachable := (&verifyProbe{method: http.MethodGet, url: config.URL + "/api/"}).run(ctx, httpClient, "")
+	if reachable.StatusCode > 0 && reachable.StatusCode < http.StatusInternalServerError {
+		reachable = &verifyResult{OK: true, StatusCode: reachable.StatusCode}
+	}
+
+	report("grafana_cloud_api", reachable)
+
+	_, err = c.ListCloudAPIKeys(config.Organisation)
+	if err == nil {
+		err = config.adminKeyExpiryError(time.Now())
+	}
+
+	report("admin_key", errorResult(err))
+
+	if stackSlug == "" {
+		return
+	}
+
+	stack, err := c.StackBySlug(stackSlug)
+	if err != nil {
+		report("stack_api", errorResult(fmt.Errorf("error reading stack %s: %w", stackSlug, err)))
+		return
+	}
+
+	report("stack_api", (&verifyProbe{method: http.MethodGet, url: joinURLPath(stack.URL, "/api/health")}).run(ctx, httpClient, ""))
+}
+
+// errorResult returns the outcome of a check that failed with err, if not nil.
+func errorResult(err error) *verifyResult {
+	if err != nil {
+		return &verifyResult{Error: err.Error()}
+	}
+
+	return &verifyResult{OK: true}
+}
@@ -0,0 +1,83 @@
+package secretsengine
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client/clienttest"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealth(t *testing.T) {
+	server := clienttest.NewServer(organisation, key)
+	defer server.Close()
+
+	server.AddStack("teststack")
+
+	b, s := getTestBackend(t)
+
+	readHealth := func(t *testing.T, data map[string]interface{}) map[string]interface{} {
+		t.Helper()
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "health",
+			Storage:   s,
+			Data:      data,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+
+		return resp.Data
+	}
+
+	t.Run("Not configured", func(t *testing.T) {
+		data := readHealth(t, nil)
+
+		assert.Equal(t, false, data["healthy"])
+		assert.Equal(t, map[string]interface{}{
+			"storage": map[string]interface{}{"ok": true, "status_code": 0},
+			"config":  map[string]interface{}{"ok": false, "status_code": 0, "error": "invalid configuration: backend not configured"},
+		}, data["checks"])
+	})
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          server.URL + "/api",
+		"organisation": organisation,
+	}))
+
+	t.Run("Healthy", func(t *testing.T) {
+		data := readHealth(t, map[string]interface{}{"stack_slug": "teststack"})
+
+		assert.Equal(t, true, data["healthy"])
+		checks := data["checks"].(map[string]interface{})
+		assert.Len(t, checks, 4)
+		assert.Equal(t, map[string]interface{}{"ok": true, "status_code": http.StatusOK}, checks["stack_api"])
+		assert.Equal(t, map[string]interface{}{"ok": true, "status_code": 0}, checks["admin_key"])
+		assert.Equal(t, true, checks["grafana_cloud_api"].(map[string]interface{})["ok"])
+	})
+
+	t.Run("Admin key rejected", func(t *testing.T) {
+		server.InjectError(http.MethodGet, "/api/orgs/"+organisation+"/api-keys", http.StatusUnauthorized, "invalid API key")
+		defer server.ClearErrors()
+
+		data := readHealth(t, nil)
+
+		assert.Equal(t, false, data["healthy"])
+		checks := data["checks"].(map[string]interface{})
+		assert.Len(t, checks, 3)
+		assert.Equal(t, map[string]interface{}{"ok": false, "status_code": 0, "error": "401: invalid API key"}, checks["admin_key"])
+		assert.Equal(t, true, checks["grafana_cloud_api"].(map[string]interface{})["ok"])
+	})
+
+	t.Run("Unknown stack", func(t *testing.T) {
+		data := readHealth(t, map[string]interface{}{"stack_slug": "missing"})
+
+		assert.Equal(t, false, data["healthy"])
+		assert.Equal(t, false, data["checks"].(map[string]interface{})["stack_api"].(map[string]interface{})["ok"])
+	})
+}
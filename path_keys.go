@@ -0,0 +1,82 @@
+package secretsengine
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// pathKeys extends the Vault API with a `/keys`
+// endpoint listing the keys in the organisation
+// that were issued by the plugin.
+func pathKeys(b *grafanaCloudBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "keys/?$",
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ListOperation: &framework.PathOperation{
+				Callback: b.pathKeysList,
+				Summary:  "List the Grafana Cloud API keys issued by the plugin.",
+			},
+		},
+		HelpSynopsis:    pathKeysHelpSyn,
+		HelpDescription: pathKeysHelpDesc,
+	}
+}
+
+const pathKeysHelpSyn = `
+List the Grafana Cloud API keys in the organisation issued by the plugin.
+`
+
+const pathKeysHelpDesc = `
+This path lists the Cloud API keys in the organisation named like the keys
+the plugin issues, [<token_prefix>_]<role>_<uuid>[_<environment>], with the
+role parsed from the name and the Grafana Cloud role of the key. Keys with an
+outstanding lease also report when they were issued and their age in
+seconds, keys without one may have been left behind by a failed revocation.
+`
+
+func (b *grafanaCloudBackend) pathKeysList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		return logical.ErrorResponse("backend not configured"), nil
+	}
+
+	keys, err := b.listPluginKeys(ctx, req.Storage, config)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	now := time.Now()
+	names := make([]string, 0, len(keys))
+	keyInfo := make(map[string]interface{}, len(keys))
+
+	for _, key := range keys {
+		info := map[string]interface{}{
+			"role":    roleFromKeyName(config, key.Name),
+			"gc_role": key.Role,
+			"leased":  false,
+		}
+
+		record, err := getIssuanceRecord(ctx, req.Storage, key.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if record != nil {
+			info["leased"] = true
+			info["issued_at"] = record.IssuedAt.Format(time.RFC3339)
+			info["age"] = int64(now.Sub(record.IssuedAt).Seconds())
+		}
+
+		names = append(names, key.Name)
+		keyInfo[key.Name] = info
+	}
+
+	return logical.ListResponseWithInfo(names, keyInfo), nil
+}
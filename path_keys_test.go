@@ -0,0 +1,75 @@
+package secretsengine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client/clienttest"
+	grafanclient "github.com/grafana/grafana-api-golang-client"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeysList(t *testing.T) {
+	server := clienttest.NewServer(organisation, key)
+	defer server.Close()
+
+	b, s := getTestBackend(t)
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          server.URL + "/api",
+		"organisation": organisation,
+		"token_prefix": "cluster-a",
+	}))
+
+	_, err := testTokenRoleCreate(t, b, s, "metrics_publisher", map[string]interface{}{
+		"gc_role": "MetricsPublisher",
+	})
+	require.NoError(t, err)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/metrics_publisher",
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	require.False(t, resp.IsError())
+
+	issued := resp.Data["key_name"].(string)
+	orphan := "cluster-a_old-role_0b7e7a6c-3f0e-4a8b-9d3c-5c1f2f6b9e10"
+
+	c, err := grafanclient.New(server.URL, grafanclient.Config{APIKey: key})
+	require.NoError(t, err)
+
+	for _, name := range []string{orphan, "manual"} {
+		_, err = c.CreateCloudAPIKey(organisation, &grafanclient.CreateCloudAPIKeyInput{Name: name, Role: "Viewer"})
+		require.NoError(t, err)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "keys/",
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	require.False(t, resp.IsError())
+
+	assert.ElementsMatch(t, []string{issued, orphan}, resp.Data["keys"])
+
+	keyInfo := resp.Data["key_info"].(map[string]interface{})
+
+	issuedInfo := keyInfo[issued].(map[string]interface{})
+	assert.Equal(t, "metrics_publisher", issuedInfo["role"])
+	assert.Equal(t, "MetricsPublisher", issuedInfo["gc_role"])
+	assert.Equal(t, true, issuedInfo["leased"])
+	assert.NotEmpty(t, issuedInfo["issued_at"])
+	assert.Contains(t, issuedInfo, "age")
+
+	assert.Equal(t, map[string]interface{}{
+		"role":    "old-role",
+		"gc_role": "Viewer",
+		"leased":  false,
+	}, keyInfo[orphan])
+}
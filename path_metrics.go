@@ -0,0 +1,64 @@
+package secretsengine
+
+import (
+	"context"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// pathMetrics extends the Vault API with a `/metrics`
+// endpoint returning the latency percentiles of the
+// requests made to Grafana Cloud.
+func pathMetrics(b *grafanaCloudBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "metrics/?$",
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathMetricsRead,
+				Summary:  "Return the latency percentiles of the requests made to Grafana Cloud.",
+			},
+		},
+		HelpSynopsis:    pathMetricsHelpSyn,
+		HelpDescription: pathMetricsHelpDesc,
+	}
+}
+
+const pathMetricsHelpSyn = `
+Return the latency percentiles of the requests made to Grafana Cloud.
+`
+
+const pathMetricsHelpDesc = `
+This path returns, for each operation made against Grafana Cloud (create_key,
+delete_key and list_keys), the p50, p95 and p99 latency in milliseconds over
+the latest 1000 requests since the plugin started, and how many requests they
+cover. The latencies are measured around the requests alone, so a slow
+issuance with fast requests is slow within the plugin or Vault. The same
+latencies are emitted to Vault telemetry as grafanacloud.api.latency with an
+operation label. Each node of a cluster reports its own requests.
+`
+
+func (b *grafanaCloudBackend) pathMetricsRead(_ context.Context, _ *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	operations := map[string]interface{}{}
+
+	for _, op := range apiOps {
+		count, p := b.latencies.percentiles(op)
+		operations[op] = map[string]interface{}{
+			"count":  count,
+			"p50_ms": milliseconds(p[0]),
+			"p95_ms": milliseconds(p[1]),
+			"p99_ms": milliseconds(p[2]),
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"operations": operations,
+		},
+	}, nil
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,61 @@
+package secretsengine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client/clienttest"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	server := clienttest.NewServer(organisation, key)
+	defer server.Close()
+
+	b, s := getTestBackend(t)
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          server.URL + "/api",
+		"organisation": organisation,
+	}))
+
+	_, err := testTokenRoleCreate(t, b, s, "metrics", map[string]interface{}{"gc_role": "MetricsPublisher"})
+	require.NoError(t, err)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/metrics",
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	require.False(t, resp.IsError())
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   s,
+		Secret:    resp.Secret,
+	})
+	require.NoError(t, err)
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "metrics",
+		Storage:   s,
+	})
+	require.NoError(t, err)
+
+	operations := resp.Data["operations"].(map[string]interface{})
+	require.Len(t, operations, 3)
+
+	for op, count := range map[string]int{apiOpCreateKey: 1, apiOpDeleteKey: 1, apiOpListKeys: 0} {
+		latency := operations[op].(map[string]interface{})
+		assert.Equal(t, count, latency["count"], op)
+
+		if count > 0 {
+			assert.Greater(t, latency["p99_ms"], 0.0, op)
+		}
+	}
+}
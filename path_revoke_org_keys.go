@@ -0,0 +1,228 @@
+package secretsengine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// revokeOrgKeysPath requires sudo, see PathsSpecial.Root.
+const revokeOrgKeysPath = "revoke-org-keys"
+
+// pathRevokeOrgKeys extends the Vault API with a `/revoke-org-keys`
+// endpoint deleting every key issued by the plugin in the
+// organisation, for when the mount is compromised.
+func pathRevokeOrgKeys(b *grafanaCloudBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: revokeOrgKeysPath,
+		Fields: map[string]*framework.FieldSchema{
+			"confirm": {
+				Type:        framework.TypeString,
+				Description: "The slug of the organisation, typed out to confirm that every key issued by the plugin in it is deleted",
+				Required:    true,
+			},
+			"include_unprefixed": {
+				Type: framework.TypeBool,
+				Description: "Delete the keys anyway when no token_prefix is configured, " +
+					"in which case the keys of other mounts sharing the organisation are deleted as well",
+				Default: false,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathRevokeOrgKeysWrite,
+				Summary:  "Delete every key issued by the plugin in the organisation.",
+			},
+		},
+		HelpSynopsis:    pathRevokeOrgKeysHelpSyn,
+		HelpDescription: pathRevokeOrgKeysHelpDesc,
+	}
+}
+
+const pathRevokeOrgKeysHelpSyn = `
+Delete every key issued by the plugin in the organisation.
+`
+
+const pathRevokeOrgKeysHelpDesc = `
+Break-glass response to a compromise of the mount. Every Cloud API key of
+the organisation named like the keys the plugin issues or adopted under a
+lease is deleted upstream. Vault does not let plugins revoke leases, so the
+leases of the deleted keys are left in place: they can no longer be renewed
+and their revocation succeeds without contacting Grafana Cloud. Revoke them
+with "vault lease revoke -prefix" on each of the lease_prefixes returned,
+e.g. grafanacloud/creds/, grafanacloud/token/ and grafanacloud/adopt/. The
+path requires sudo and confirm must be set to the slug of the organisation.
+Without a token_prefix, the keys of other mounts sharing the organisation
+cannot be told apart, and the path refuses to run unless include_unprefixed
+is set. Keys starting with one of the protected_key_prefixes of the config
+are never deleted and are returned in protected_keys. Keys that fail to be
+deleted are returned in errors, and the other keys are still deleted.
+`
+
+// leasePaths are the paths returning leases, in the order they are reported.
+//
+//nolint:gochecknoglobals // shared by the response and its tests.
+var leasePaths = []string{"creds/", "token/", "adopt/"}
+
+// revokedByOrgKeys marks the records of the keys deleted by revoke-org-keys.
+const revokedByOrgKeys = "revoke-org-keys"
+
+func (b *grafanaCloudBackend) pathRevokeOrgKeysWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		return logical.ErrorResponse("backend not configured"), nil
+	}
+
+	if d.Get("confirm").(string) != config.Organisation {
+		return logical.ErrorResponse("confirm must be set to the slug of the organisation to delete every key issued by the plugin in it"), nil
+	}
+
+	if config.TokenPrefix == "" && !d.Get("include_unprefixed").(bool) {
+		return logical.ErrorResponse("no token_prefix is configured, so the keys of other mounts sharing the organisation cannot be told apart, " +
+			"set include_unprefixed=true to delete them as well"), nil
+	}
+
+	c, err := b.getClient(ctx, req.Storage)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	b.Logger().Warn("revoking every key issued by the plugin in the organisation",
+		"organisation", config.Organisation, "display_name", req.DisplayName, "entity_id", req.EntityID)
+
+	adopted, err := adoptedKeyNames(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	cloudKeys, protected, failures := b.revokeOrgCloudKeys(c, config, adopted)
+
+	now := time.Now().UTC()
+	leases := 0
+
+	for _, name := range cloudKeys {
+		record, err := getIssuanceRecord(ctx, req.Storage, name)
+		if err != nil {
+			return nil, err
+		}
+
+		if record == nil {
+			continue
+		}
+
+		record.RevokedBy = revokedByOrgKeys
+		if err := putIssuanceRecord(ctx, req.Storage, record); err != nil {
+			return nil, err
+		}
+
+		if err := revokeIssuanceRecord(ctx, req.Storage, name, now); err != nil {
+			return nil, err
+		}
+
+		leases++
+	}
+
+	leasePrefixes := make([]string, 0, len(leasePaths))
+	for _, path := range leasePaths {
+		leasePrefixes = append(leasePrefixes, req.MountPoint+path)
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"cloud_keys_deleted": cloudKeys,
+			"protected_keys":     protected,
+			"leases":             leases,
+			"lease_prefixes":     leasePrefixes,
+			"errors":             failures,
+		},
+	}
+
+	if config.TokenPrefix == "" {
+		resp.AddWarning("no token_prefix is configured, keys issued by other mounts sharing the organisation were deleted as well")
+	}
+
+	if leases > 0 {
+		resp.AddWarning(fmt.Sprintf("the %d leases of the deleted keys can no longer be renewed, revoke them with vault lease revoke -prefix on each of: %s",
+			leases, strings.Join(leasePrefixes, ", ")))
+	}
+
+	return resp, nil
+}
+
+// adoptedKeyNames returns the names of the adopted keys with an outstanding lease.
+func adoptedKeyNames(ctx context.Context, s logical.Storage) (map[string]bool, error) {
+	records, err := readIssuanceRecords(ctx, s, issuedStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	adopted := map[string]bool{}
+
+	for _, record := range records {
+		if record.Adopted {
+			adopted[record.Name] = true
+		}
+	}
+
+	return adopted, nil
+}
+
+// revokeOrgCloudKeys deletes the Cloud API keys of the organisation named
+// like the keys the plugin issues or adopted, returning the names of those
+// deleted, those left alone as they match protected_key_prefixes, and the
+// errors of those which could not be deleted. A failed deletion does not
+// stop the others.
+func (b *grafanaCloudBackend) revokeOrgCloudKeys(c client.API, config *Config, adopted map[string]bool) ([]string, []string, []string) {
+	deleted := []string{}
+	protected := []string{}
+	failures := []string{}
+
+	keys, err := c.ListCloudAPIKeys(config.Organisation)
+	if err != nil {
+		return deleted, protected, append(failures, fmt.Sprintf("error listing Grafana Cloud keys: %s", err))
+	}
+
+	for _, key := range keys.Items {
+		if !isPluginKeyName(config, key.Name) && !adopted[key.Name] {
+			continue
+		}
+
+		if isProtectedKeyName(config, key.Name) {
+			protected = append(protected, key.Name)
+
+			continue
+		}
+
+		if err := c.DeleteCloudAPIKey(config.Organisation, key.Name); err != nil {
+			b.Logger().Error("failed to delete key", "key", key.Name, "error", err)
+			failures = append(failures, fmt.Sprintf("error deleting Grafana Cloud key %s: %s", key.Name, err))
+
+			continue
+		}
+
+		deleted = append(deleted, key.Name)
+	}
+
+	return deleted, protected, failures
+}
+
+// isProtectedKeyName reports whether name starts with one of the
+// protected_key_prefixes of the config.
+func isProtectedKeyName(config *Config, name string) bool {
+	for _, prefix := range config.ProtectedKeyPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,278 @@
+package secretsengine
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client"
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client/clienttest"
+	grafanclient "github.com/grafana/grafana-api-golang-client"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevokeOrgKeys(t *testing.T) {
+	server := clienttest.NewServer(organisation, key)
+	defer server.Close()
+
+	b, s := getTestBackend(t)
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          server.URL + "/api",
+		"organisation": organisation,
+		"token_prefix": "cluster-a",
+	}))
+
+	_, err := testTokenRoleCreate(t, b, s, "metrics", map[string]interface{}{"gc_role": "MetricsPublisher"})
+	require.NoError(t, err)
+
+	lease := func(t *testing.T, operation logical.Operation, path string, data map[string]interface{}) *logical.Secret {
+		t.Helper()
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: operation,
+			Path:      path,
+			Data:      data,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+
+		return resp.Secret
+	}
+
+	cloudLease := lease(t, logical.ReadOperation, "creds/metrics", nil)
+	tokenLease := lease(t, logical.ReadOperation, "token/metrics", nil)
+
+	// Created by hand, outside Vault.
+	c, err := client.New(server.URL, key, nil)
+	require.NoError(t, err)
+
+	_, err = c.CreateCloudAPIKey(organisation, &grafanclient.CreateCloudAPIKeyInput{Name: "manual-admin", Role: "Admin"})
+	require.NoError(t, err)
+
+	_, err = c.CreateCloudAPIKey(organisation, &grafanclient.CreateCloudAPIKeyInput{Name: "manual-metrics", Role: "MetricsPublisher"})
+	require.NoError(t, err)
+
+	adoptedLease := lease(t, logical.UpdateOperation, "adopt/metrics", map[string]interface{}{"key_name": "manual-metrics"})
+	leases := []*logical.Secret{cloudLease, tokenLease, adoptedLease}
+
+	revokeOrgKeys := func(t *testing.T, confirm string) *logical.Response {
+		t.Helper()
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation:  logical.UpdateOperation,
+			Path:       "revoke-org-keys",
+			Data:       map[string]interface{}{"confirm": confirm},
+			Storage:    s,
+			MountPoint: "grafanacloud/",
+		})
+		require.NoError(t, err)
+
+		return resp
+	}
+
+	t.Run("Requires confirmation", func(t *testing.T) {
+		resp := revokeOrgKeys(t, "yes")
+		require.EqualError(t, resp.Error(), "confirm must be set to the slug of the organisation to delete every key issued by the plugin in it")
+		require.Len(t, server.CloudKeys(), 4)
+	})
+
+	t.Run("Deletes the keys of the plugin", func(t *testing.T) {
+		resp := revokeOrgKeys(t, organisation)
+		require.False(t, resp.IsError())
+
+		assert.ElementsMatch(t, []string{
+			cloudLease.InternalData["name"].(string),
+			tokenLease.InternalData["name"].(string),
+			"manual-metrics",
+		}, resp.Data["cloud_keys_deleted"])
+		assert.Equal(t, 3, resp.Data["leases"])
+		assert.Equal(t, []string{"grafanacloud/creds/", "grafanacloud/token/", "grafanacloud/adopt/"}, resp.Data["lease_prefixes"])
+		assert.Empty(t, resp.Data["errors"])
+		assert.Equal(t, []string{"the 3 leases of the deleted keys can no longer be renewed, revoke them with vault lease revoke -prefix on each of: " +
+			"grafanacloud/creds/, grafanacloud/token/, grafanacloud/adopt/"}, resp.Warnings)
+
+		assert.Equal(t, []string{"manual-admin"}, server.CloudKeys())
+
+		issued, err := listIssuanceRecords(context.Background(), s)
+		require.NoError(t, err)
+		assert.Empty(t, issued)
+	})
+
+	t.Run("Leases cannot be renewed", func(t *testing.T) {
+		// Leases of creds/, token/ and adopt/ alike.
+		for _, lease := range leases {
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.RenewOperation,
+				Storage:   s,
+				Secret:    lease,
+			})
+			require.NoError(t, err)
+			require.EqualError(t, resp.Error(), `key "`+lease.InternalData["name"].(string)+`" was deleted by revoke-org-keys, revoke the lease`)
+		}
+	})
+
+	t.Run("Leases are revoked", func(t *testing.T) {
+		for _, lease := range leases {
+			_, err := b.HandleRequest(context.Background(), &logical.Request{
+				Operation: logical.RevokeOperation,
+				Storage:   s,
+				Secret:    lease,
+			})
+			require.NoError(t, err)
+		}
+	})
+}
+
+func TestRevokeOrgKeysFailures(t *testing.T) {
+	server := clienttest.NewServer(organisation, key)
+	defer server.Close()
+
+	b, s := getTestBackend(t)
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          server.URL + "/api",
+		"organisation": organisation,
+		"token_prefix": "cluster-a",
+	}))
+
+	_, err := testTokenRoleCreate(t, b, s, "metrics", map[string]interface{}{"gc_role": "MetricsPublisher"})
+	require.NoError(t, err)
+
+	var names []string
+
+	for i := 0; i < 3; i++ {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/metrics",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+
+		names = append(names, resp.Secret.InternalData["name"].(string))
+	}
+
+	server.InjectError(http.MethodDelete, "/api/orgs/"+organisation+"/api-keys/"+names[0], http.StatusServiceUnavailable, "try again later")
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation:  logical.UpdateOperation,
+		Path:       "revoke-org-keys",
+		Data:       map[string]interface{}{"confirm": organisation},
+		Storage:    s,
+		MountPoint: "grafanacloud/",
+	})
+	require.NoError(t, err)
+	require.False(t, resp.IsError())
+
+	assert.ElementsMatch(t, names[1:], resp.Data["cloud_keys_deleted"])
+	assert.Equal(t, 2, resp.Data["leases"])
+	require.Len(t, resp.Data["errors"], 1)
+	assert.Contains(t, resp.Data["errors"].([]string)[0], "error deleting Grafana Cloud key "+names[0])
+	assert.Equal(t, []string{names[0]}, server.CloudKeys())
+}
+
+func TestRevokeOrgKeysWithoutTokenPrefix(t *testing.T) {
+	server := clienttest.NewServer(organisation, key)
+	defer server.Close()
+
+	b, s := getTestBackend(t)
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          server.URL + "/api",
+		"organisation": organisation,
+	}))
+
+	_, err := testTokenRoleCreate(t, b, s, "metrics", map[string]interface{}{"gc_role": "MetricsPublisher"})
+	require.NoError(t, err)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/metrics",
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	require.False(t, resp.IsError())
+
+	revokeOrgKeys := func(t *testing.T, data map[string]interface{}) *logical.Response {
+		t.Helper()
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation:  logical.UpdateOperation,
+			Path:       "revoke-org-keys",
+			Data:       data,
+			Storage:    s,
+			MountPoint: "grafanacloud/",
+		})
+		require.NoError(t, err)
+
+		return resp
+	}
+
+	t.Run("Is refused", func(t *testing.T) {
+		resp := revokeOrgKeys(t, map[string]interface{}{"confirm": organisation})
+		require.EqualError(t, resp.Error(), "no token_prefix is configured, so the keys of other mounts sharing the organisation cannot be told apart, "+
+			"set include_unprefixed=true to delete them as well")
+		require.Len(t, server.CloudKeys(), 1)
+	})
+
+	t.Run("Deletes the keys with include_unprefixed", func(t *testing.T) {
+		resp := revokeOrgKeys(t, map[string]interface{}{"confirm": organisation, "include_unprefixed": true})
+		require.False(t, resp.IsError())
+		assert.Len(t, resp.Data["cloud_keys_deleted"], 1)
+		assert.Contains(t, resp.Warnings, "no token_prefix is configured, keys issued by other mounts sharing the organisation were deleted as well")
+		assert.Empty(t, server.CloudKeys())
+	})
+}
+
+func TestRevokeOrgKeysProtectedPrefixes(t *testing.T) {
+	server := clienttest.NewServer(organisation, key)
+	defer server.Close()
+
+	b, s := getTestBackend(t)
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":                    key,
+		"url":                    server.URL + "/api",
+		"organisation":           organisation,
+		"token_prefix":           "cluster-a",
+		"protected_key_prefixes": "cluster-a_keep",
+	}))
+
+	names := map[string]string{}
+
+	for _, role := range []string{"metrics", "keep"} {
+		_, err := testTokenRoleCreate(t, b, s, role, map[string]interface{}{"gc_role": "MetricsPublisher"})
+		require.NoError(t, err)
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/" + role,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+
+		names[role] = resp.Secret.InternalData["name"].(string)
+	}
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation:  logical.UpdateOperation,
+		Path:       "revoke-org-keys",
+		Data:       map[string]interface{}{"confirm": organisation},
+		Storage:    s,
+		MountPoint: "grafanacloud/",
+	})
+	require.NoError(t, err)
+	require.False(t, resp.IsError())
+
+	assert.Equal(t, []string{names["metrics"]}, resp.Data["cloud_keys_deleted"])
+	assert.Equal(t, []string{names["keep"]}, resp.Data["protected_keys"])
+	assert.Equal(t, []string{names["keep"]}, server.CloudKeys())
+}
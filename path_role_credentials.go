@@ -0,0 +1,72 @@
+package secretsengine
+
+import (
+	"context"
+	"sort"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// pathRoleCredentials extends the Vault API with a
+// `/roles/<name>/credentials` endpoint listing the
+// keys with an outstanding lease issued for a role.
+func pathRoleCredentials(b *grafanaCloudBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "roles/" + framework.GenericNameRegex("name") + "/credentials/?$",
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Name of the role",
+				Required:    true,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ListOperation: &framework.PathOperation{
+				Callback: b.pathRoleCredentialsList,
+				Summary:  "List the outstanding keys of a role.",
+			},
+		},
+		HelpSynopsis:    pathRoleCredentialsHelpSyn,
+		HelpDescription: pathRoleCredentialsHelpDesc,
+	}
+}
+
+const pathRoleCredentialsHelpSyn = `
+List the keys with an outstanding lease issued for a role.
+`
+
+const pathRoleCredentialsHelpDesc = `
+This path lists the names of the keys issued for the role whose lease has
+not been revoked yet, oldest first, from the issuance records of the plugin.
+Each key reports when it was issued, when its lease reaches its max TTL, and
+the entity and display name of the requester. No request is made to
+Grafana Cloud.
+`
+
+func (b *grafanaCloudBackend) pathRoleCredentialsList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	roleName := d.Get("name").(string)
+
+	records, err := readIssuanceRecords(ctx, req.Storage, issuedStoragePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].IssuedAt.Before(records[j].IssuedAt)
+	})
+
+	names := []string{}
+	keyInfo := map[string]interface{}{}
+
+	for _, record := range records {
+		if record.Role != roleName {
+			continue
+		}
+
+		names = append(names, record.Name)
+		keyInfo[record.Name] = record.toResponseData()
+	}
+
+	return logical.ListResponseWithInfo(names, keyInfo), nil
+}
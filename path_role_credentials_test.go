@@ -0,0 +1,91 @@
+package secretsengine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client/clienttest"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoleCredentials(t *testing.T) {
+	server := clienttest.NewServer(organisation, key)
+	defer server.Close()
+
+	b, s := getTestBackend(t)
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          server.URL + "/api",
+		"organisation": organisation,
+	}))
+
+	for _, role := range []string{"metrics", "logs"} {
+		_, err := testTokenRoleCreate(t, b, s, role, map[string]interface{}{
+			"gc_role": "MetricsPublisher",
+			"max_ttl": "1h",
+		})
+		require.NoError(t, err)
+	}
+
+	issue := func(t *testing.T, role, entityID string) *logical.Response {
+		t.Helper()
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation:   logical.ReadOperation,
+			Path:        "creds/" + role,
+			Storage:     s,
+			EntityID:    entityID,
+			DisplayName: "token-" + entityID,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+
+		return resp
+	}
+
+	list := func(t *testing.T, role string) *logical.Response {
+		t.Helper()
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ListOperation,
+			Path:      "roles/" + role + "/credentials",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+
+		return resp
+	}
+
+	first := issue(t, "metrics", "entity-a")
+	second := issue(t, "metrics", "entity-b")
+	issue(t, "logs", "entity-c")
+
+	_, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   s,
+		Secret:    second.Secret,
+	})
+	require.NoError(t, err)
+
+	resp := list(t, "metrics")
+	require.Equal(t, []string{first.Data["key_name"].(string)}, resp.Data["keys"])
+
+	info := resp.Data["key_info"].(map[string]interface{})[first.Data["key_name"].(string)].(map[string]interface{})
+	assert.Equal(t, "metrics", info["role"])
+	assert.Equal(t, "entity-a", info["entity_id"])
+	assert.Equal(t, "token-entity-a", info["display_name"])
+
+	issuedAt, err := time.Parse(time.RFC3339, info["issued_at"].(string))
+	require.NoError(t, err)
+
+	expiresAt, err := time.Parse(time.RFC3339, info["expires_at"].(string))
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, expiresAt.Sub(issuedAt))
+
+	resp = list(t, "unknown")
+	assert.Empty(t, resp.Data["keys"])
+}
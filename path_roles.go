@@ -3,6 +3,7 @@ package secretsengine
 import (
 	"context"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
@@ -16,16 +17,41 @@ This path allows you to read and write roles used to generate Grafana Cloud toke
 `
 
 	pathRoleListHelpSynopsis    = `List the existing roles in Grafana Cloud backend`
-	pathRoleListHelpDescription = `Roles will be listed by the role name.`
+	pathRoleListHelpDescription = `Roles will be listed by the role name. A delete with confirm=true removes all roles.`
 )
 
-// grafanaCloudRoleEntry defines the data required
+// RoleEntry defines the data required
 // for a Vault role to access and call the Grafana Cloud
 // token endpoints.
-type grafanaCloudRoleEntry struct {
+type RoleEntry struct {
 	GrafanaCloudRole string        `json:"gc_role"`
 	TTL              time.Duration `json:"ttl"`
 	MaxTTL           time.Duration `json:"max_ttl"`
+	IssuanceWindows  []string      `json:"issuance_window,omitempty"`
+	BoundCIDRs       []string      `json:"bound_cidrs,omitempty"`
+	// BoundEntityMetadata must match the metadata of the requester's entity.
+	BoundEntityMetadata map[string]string `json:"bound_entity_metadata,omitempty"`
+	// NonRenewable is stored inverted so roles written before it existed stay renewable.
+	NonRenewable bool `json:"non_renewable,omitempty"`
+	// URL overrides the Grafana Cloud API URL of the config for the keys of
+	// the role, e.g. to issue them in a test environment.
+	URL string `json:"url,omitempty"`
+}
+
+// clone returns a copy of the role which shares no slices or maps with it.
+func (r *RoleEntry) clone() *RoleEntry {
+	c := *r
+	c.IssuanceWindows = append([]string(nil), r.IssuanceWindows...)
+	c.BoundCIDRs = append([]string(nil), r.BoundCIDRs...)
+
+	if r.BoundEntityMetadata != nil {
+		c.BoundEntityMetadata = make(map[string]string, len(r.BoundEntityMetadata))
+		for key, value := range r.BoundEntityMetadata {
+			c.BoundEntityMetadata[key] = value
+		}
+	}
+
+	return &c
 }
 
 // grafanaCloudValidRoles valid roles in Grafana Cloud
@@ -40,57 +66,181 @@ var grafanaCloudValidRoles = map[string]bool{
 	"PluginPublisher":  true,
 }
 
+// ttlClampWarnings returns warnings for the ttl and max_ttl of the role that
+// exceed the max lease TTL of the mount, which Vault clamps leases to.
+func (r *RoleEntry) ttlClampWarnings(systemMaxTTL time.Duration) []string {
+	var warnings []string
+
+	for field, ttl := range map[string]time.Duration{"ttl": r.TTL, "max_ttl": r.MaxTTL} {
+		if systemMaxTTL > 0 && ttl > systemMaxTTL {
+			warnings = append(warnings, fmt.Sprintf("%s of %s exceeds the mount's max lease TTL of %s, leases are limited to %s",
+				field, ttl, systemMaxTTL, systemMaxTTL))
+		}
+	}
+
+	sort.Strings(warnings)
+
+	return warnings
+}
+
+// effectiveTTLs returns the ttl and max_ttl leases of the role get, after
+// the mount defaults are applied and both are clamped to its max lease TTL.
+func (r *RoleEntry) effectiveTTLs(sys logical.SystemView) (time.Duration, time.Duration) {
+	maxTTL := sys.MaxLeaseTTL()
+	if r.MaxTTL > 0 && r.MaxTTL < maxTTL {
+		maxTTL = r.MaxTTL
+	}
+
+	ttl := sys.DefaultLeaseTTL()
+	if r.TTL > 0 {
+		ttl = r.TTL
+	}
+
+	if ttl > maxTTL {
+		ttl = maxTTL
+	}
+
+	return ttl, maxTTL
+}
+
 // toResponseData returns response data for a role.
-func (r *grafanaCloudRoleEntry) toResponseData() map[string]interface{} {
+func (r *RoleEntry) toResponseData() map[string]interface{} {
 	respData := map[string]interface{}{
-		"gc_role": r.GrafanaCloudRole,
-		"ttl":     r.TTL.Seconds(),
-		"max_ttl": r.MaxTTL.Seconds(),
+		"gc_role":               r.GrafanaCloudRole,
+		"ttl":                   r.TTL.Seconds(),
+		"max_ttl":               r.MaxTTL.Seconds(),
+		"issuance_window":       r.IssuanceWindows,
+		"bound_cidrs":           r.BoundCIDRs,
+		"bound_entity_metadata": r.BoundEntityMetadata,
+		"renewable":             !r.NonRenewable,
 	}
+
+	if r.URL != "" {
+		respData["url"] = r.URL
+	}
+
 	return respData
 }
 
+// roleFields returns the fields of a role, shared by the role and batch paths.
+func roleFields() map[string]*framework.FieldSchema {
+	return map[string]*framework.FieldSchema{
+		"name": {
+			Type:        framework.TypeString,
+			Description: "The actual Role name",
+			Required:    true,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Role Name",
+			},
+		},
+		"gc_role": {
+			Type:        framework.TypeString,
+			Description: "The Grafana Cloud role, i.e. the key authorization level",
+			Required:    true,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Grafana Cloud Role",
+			},
+		},
+		"ttl": {
+			Type:        framework.TypeDurationSecond,
+			Description: "Default lease for generated credentials. If not set or set to 0, will use system default.",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:  "TTL",
+				Group: displayGroupLease,
+			},
+		},
+		"max_ttl": {
+			Type:        framework.TypeDurationSecond,
+			Description: "Maximum time for role. If not set or set to 0, will use system default.",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:  "Max TTL",
+				Group: displayGroupLease,
+			},
+		},
+		"issuance_window": {
+			Type: framework.TypeCommaStringSlice,
+			Description: "Time ranges in UTC during which keys may be issued, as '[days ]HH:MM-HH:MM'" +
+				" (e.g. 'Mon-Fri 09:00-17:00'). If not set keys can be issued at any time.",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Issuance Window",
+			},
+		},
+		"bound_cidrs": {
+			Type: framework.TypeCommaStringSlice,
+			Description: "CIDR blocks or IP addresses requests for keys must come from" +
+				" (e.g. '10.0.0.0/8'). If not set keys can be requested from any address.",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Bound CIDRs",
+			},
+		},
+		"bound_entity_metadata": {
+			Type: framework.TypeKVPairs,
+			Description: "Metadata the identity entity of requests for keys must have, as key=value pairs" +
+				" (e.g. 'team=observability'). If not set keys can be requested by any entity.",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Bound Entity Metadata",
+			},
+		},
+		"renewable": {
+			Type:        framework.TypeBool,
+			Default:     true,
+			Description: "Whether leases of issued keys can be renewed. When false keys must be re-issued once their ttl expires",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:  "Renewable",
+				Group: displayGroupLease,
+			},
+		},
+		"url": {
+			Type: framework.TypeString,
+			Description: "The Grafana Cloud API URL keys of the role are issued and revoked against, overriding the url of the config" +
+				" (e.g. a staging gateway). Keys are still created with the admin key of the config",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "URL",
+			},
+		},
+	}
+}
+
 // pathRole extends the Vault API with a `/role`
 // endpoint for the backend. You can choose whether
 // or not certain attributes should be displayed,
 // required, and named. You can also define different
 // path patterns to list all roles.
 func pathRole(b *grafanaCloudBackend) []*framework.Path {
+	fields := roleFields()
+	fields["preview"] = &framework.FieldSchema{
+		Type: framework.TypeBool,
+		Description: "Return a preview of the keys the role issues, with an example key name, the gc_role and the TTLs." +
+			" Not stored",
+		DisplayAttrs: &framework.DisplayAttributes{
+			Name: "Preview",
+		},
+	}
+
 	return []*framework.Path{
 		{
 			Pattern: "roles/" + framework.GenericNameRegex("name"),
-			Fields: map[string]*framework.FieldSchema{
-				"name": {
-					Type:        framework.TypeString,
-					Description: "The actual Role name",
-					Required:    true,
-				},
-				"gc_role": {
-					Type:        framework.TypeString,
-					Description: "The Grafana Cloud role, i.e. the key authorization level",
-					Required:    true,
-				},
-				"ttl": {
-					Type:        framework.TypeDurationSecond,
-					Description: "Default lease for generated credentials. If not set or set to 0, will use system default.",
-				},
-				"max_ttl": {
-					Type:        framework.TypeDurationSecond,
-					Description: "Maximum time for role. If not set or set to 0, will use system default.",
-				},
+			Fields:  fields,
+			DisplayAttrs: &framework.DisplayAttributes{
+				ItemType: "Role",
+				Action:   "Create",
 			},
 			Operations: map[logical.Operation]framework.OperationHandler{
 				logical.ReadOperation: &framework.PathOperation{
 					Callback: b.pathRolesRead,
+					Summary:  "Read a role.",
 				},
 				logical.CreateOperation: &framework.PathOperation{
 					Callback: b.pathRolesWrite,
+					Summary:  "Create a role.",
 				},
 				logical.UpdateOperation: &framework.PathOperation{
 					Callback: b.pathRolesWrite,
+					Summary:  "Update a role.",
 				},
 				logical.DeleteOperation: &framework.PathOperation{
 					Callback: b.pathRolesDelete,
+					Summary:  "Delete a role.",
 				},
 			},
 			HelpSynopsis:    pathRoleHelpSynopsis,
@@ -98,9 +248,24 @@ func pathRole(b *grafanaCloudBackend) []*framework.Path {
 		},
 		{
 			Pattern: "roles/?$",
+			Fields: map[string]*framework.FieldSchema{
+				"confirm": {
+					Type:        framework.TypeBool,
+					Description: "Must be set to true to delete all roles",
+				},
+			},
+			DisplayAttrs: &framework.DisplayAttributes{
+				ItemType:   "Role",
+				Navigation: true,
+			},
 			Operations: map[logical.Operation]framework.OperationHandler{
 				logical.ListOperation: &framework.PathOperation{
 					Callback: b.pathRolesList,
+					Summary:  "List the roles.",
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.pathRolesDeleteAll,
+					Summary:  "Delete all roles.",
 				},
 			},
 			HelpSynopsis:    pathRoleListHelpSynopsis,
@@ -109,11 +274,17 @@ func pathRole(b *grafanaCloudBackend) []*framework.Path {
 	}
 }
 
-func (b *grafanaCloudBackend) getRole(ctx context.Context, s logical.Storage, name string) (*grafanaCloudRoleEntry, error) {
+func (b *grafanaCloudBackend) getRole(ctx context.Context, s logical.Storage, name string) (*RoleEntry, error) {
 	if name == "" {
 		return nil, NewInvalidConfigurationError("missing role name", nil)
 	}
 
+	if role, ok := b.roles.get(name); ok {
+		return role, nil
+	}
+
+	generation := b.roles.currentGeneration()
+
 	entry, err := s.Get(ctx, "roles/"+name)
 	if err != nil {
 		return nil, err
@@ -123,23 +294,32 @@ func (b *grafanaCloudBackend) getRole(ctx context.Context, s logical.Storage, na
 		return nil, nil
 	}
 
-	var role grafanaCloudRoleEntry
+	var role RoleEntry
 
 	if err := entry.DecodeJSON(&role); err != nil {
 		return nil, err
 	}
+
+	b.roles.set(name, &role, generation)
+
 	return &role, nil
 }
 
+// roleNotFoundResponse returns an error response naming the missing role.
+// The existing roles are not listed, tokens allowed to read credentials of a
+// role may not be allowed to list the roles.
+func roleNotFoundResponse(name string) (*logical.Response, error) {
+	return logical.ErrorResponse(fmt.Sprintf("role %q not found", name)), nil
+}
+
 func (b *grafanaCloudBackend) pathRolesRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	entry, err := b.getRole(ctx, req.Storage, d.Get("name").(string))
 	if err != nil {
 		return nil, err
 	}
 
+	// A nil response is returned to the caller as a 404.
 	if entry == nil {
-		//nolint:godox
-		// TODO: shouldn't we return a Response or an error here?
 		return nil, nil
 	}
 
@@ -148,7 +328,7 @@ func (b *grafanaCloudBackend) pathRolesRead(ctx context.Context, req *logical.Re
 	}, nil
 }
 
-func setRole(ctx context.Context, s logical.Storage, name string, roleEntry *grafanaCloudRoleEntry) error {
+func (b *grafanaCloudBackend) setRole(ctx context.Context, s logical.Storage, name string, roleEntry *RoleEntry) error {
 	entry, err := logical.StorageEntryJSON("roles/"+name, roleEntry)
 	if err != nil {
 		return err
@@ -158,6 +338,8 @@ func setRole(ctx context.Context, s logical.Storage, name string, roleEntry *gra
 		return NewInternalError("failed to create storage entry for role", nil)
 	}
 
+	defer b.roles.forget(name)
+
 	if err := s.Put(ctx, entry); err != nil {
 		return err
 	}
@@ -171,24 +353,73 @@ func (b *grafanaCloudBackend) pathRolesWrite(ctx context.Context, req *logical.R
 		return logical.ErrorResponse("missing role name"), nil
 	}
 
+	// roles/batch is matched first, a role with its name could not be read back.
+	if name.(string) == rolesBatchName {
+		return logical.ErrorResponse(fmt.Sprintf("role name %q is reserved", rolesBatchName)), nil
+	}
+
 	roleEntry, err := b.getRole(ctx, req.Storage, name.(string))
 	if err != nil {
 		return nil, err
 	}
 
 	if roleEntry == nil {
-		roleEntry = &grafanaCloudRoleEntry{}
+		roleEntry = &RoleEntry{}
+	}
+
+	config, err := getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp, err := updateRole(roleEntry, config, d, req.Operation == logical.CreateOperation); resp != nil || err != nil {
+		return resp, err
+	}
+
+	if err := b.setRole(ctx, req.Storage, name.(string), roleEntry); err != nil {
+		return nil, err
+	}
+
+	resp := b.roleWriteResponse(roleEntry)
+
+	if d.Get("preview").(bool) {
+		preview, warnings := b.rolePreview(name.(string), roleEntry, config)
+		resp.Data["preview"] = preview
+
+		for _, warning := range warnings {
+			resp.AddWarning(warning)
+		}
 	}
 
-	createOperation := req.Operation == logical.CreateOperation
+	return resp, nil
+}
+
+// updateRole applies the fields in d to roleEntry and validates the result.
+// When createOperation is set unset fields get their defaults, otherwise
+// they keep their current values. Invalid fields are reported in an error
+// response.
+func updateRole(roleEntry *RoleEntry, config *Config, d *framework.FieldData, createOperation bool) (*logical.Response, error) {
+	if apiURL, ok := d.GetOk("url"); ok {
+		roleEntry.URL = ""
+
+		if apiURL.(string) != "" {
+			normalized, err := normalizeAPIURL(apiURL.(string))
+			if err != nil {
+				return errorResponse(err)
+			}
+
+			roleEntry.URL = normalized
+		}
+	}
 
 	if gcRole, ok := d.GetOk("gc_role"); ok {
 		roleEntry.GrafanaCloudRole = gcRole.(string)
-		if _, ok := grafanaCloudValidRoles[roleEntry.GrafanaCloudRole]; !ok {
-			return logical.ErrorResponse(fmt.Sprintf("provided gc_role %s is not valid", roleEntry.GrafanaCloudRole)), nil
-		}
-	} else if !ok && createOperation {
-		return nil, NewInvalidConfigurationError("missing gc_role value", nil)
+	} else if roleEntry.GrafanaCloudRole == "" {
+		return logical.ErrorResponse("missing gc_role value"), nil
+	}
+
+	if _, ok := config.cloudRoles()[roleEntry.GrafanaCloudRole]; !ok {
+		return logical.ErrorResponse(fmt.Sprintf("provided gc_role %s is not valid", roleEntry.GrafanaCloudRole)), nil
 	}
 
 	if ttlRaw, ok := d.GetOk("ttl"); ok {
@@ -209,15 +440,61 @@ func (b *grafanaCloudBackend) pathRolesWrite(ctx context.Context, req *logical.R
 		return logical.ErrorResponse("ttl cannot be greater than max_ttl"), nil
 	}
 
-	if err := setRole(ctx, req.Storage, name.(string), roleEntry); err != nil {
-		return nil, err
+	if renewable, ok := d.GetOk("renewable"); ok {
+		roleEntry.NonRenewable = !renewable.(bool)
+	}
+
+	if windows, ok := d.GetOk("issuance_window"); ok {
+		roleEntry.IssuanceWindows = windows.([]string)
+	}
+
+	for _, window := range roleEntry.IssuanceWindows {
+		if _, err := parseIssuanceWindow(window); err != nil {
+			return errorResponse(err)
+		}
+	}
+
+	if cidrs, ok := d.GetOk("bound_cidrs"); ok {
+		roleEntry.BoundCIDRs = cidrs.([]string)
+	}
+
+	for _, cidr := range roleEntry.BoundCIDRs {
+		if _, err := parseBoundCIDR(cidr); err != nil {
+			return errorResponse(err)
+		}
+	}
+
+	if metadata, ok := d.GetOk("bound_entity_metadata"); ok {
+		roleEntry.BoundEntityMetadata = metadata.(map[string]string)
+	}
+
+	if err := validateBoundEntityMetadata(roleEntry.BoundEntityMetadata); err != nil {
+		return errorResponse(err)
 	}
 
 	return nil, nil
 }
 
+// roleWriteResponse returns the stored role with the TTLs leases actually
+// get, so callers can check the result without reading the role back.
+func (b *grafanaCloudBackend) roleWriteResponse(roleEntry *RoleEntry) *logical.Response {
+	ttl, maxTTL := roleEntry.effectiveTTLs(b.System())
+	resp := &logical.Response{Data: roleEntry.toResponseData()}
+	resp.Data["effective_ttl"] = ttl.Seconds()
+	resp.Data["effective_max_ttl"] = maxTTL.Seconds()
+
+	for _, warning := range roleEntry.ttlClampWarnings(b.System().MaxLeaseTTL()) {
+		resp.AddWarning(warning)
+	}
+
+	return resp
+}
+
 func (b *grafanaCloudBackend) pathRolesDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	err := req.Storage.Delete(ctx, "roles/"+d.Get("name").(string))
+	name := d.Get("name").(string)
+	defer b.roles.forget(name)
+
+	err := req.Storage.Delete(ctx, "roles/"+name)
 	if err != nil {
 		return nil, fmt.Errorf("error deleting grafanaCloud role: %w", err)
 	}
@@ -225,6 +502,37 @@ func (b *grafanaCloudBackend) pathRolesDelete(ctx context.Context, req *logical.
 	return nil, nil
 }
 
+// pathRolesDeleteAll removes every role. Leases issued for the roles are
+// left untouched and are still revoked as usual.
+func (b *grafanaCloudBackend) pathRolesDeleteAll(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	if !d.Get("confirm").(bool) {
+		return logical.ErrorResponse("deleting all roles requires confirm=true"), nil
+	}
+
+	entries, err := req.Storage.List(ctx, "roles/")
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := make([]string, 0, len(entries))
+
+	defer b.roles.clear()
+
+	for _, name := range entries {
+		if err := req.Storage.Delete(ctx, "roles/"+name); err != nil {
+			return nil, fmt.Errorf("error deleting grafanaCloud role %q: %w", name, err)
+		}
+
+		deleted = append(deleted, name)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"deleted": deleted,
+		},
+	}, nil
+}
+
 func (b *grafanaCloudBackend) pathRolesList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 	entries, err := req.Storage.List(ctx, "roles/")
 	if err != nil {
@@ -0,0 +1,188 @@
+package secretsengine
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// rolesBatchName is the name the batch path takes from the role namespace.
+const rolesBatchName = "batch"
+
+//nolint:gochecknoglobals // compiled once, matches the role names accepted by the roles path.
+var roleNameRegex = regexp.MustCompile("^" + framework.GenericNameRegex("name") + "$")
+
+// pathRolesBatch extends the Vault API with a `/roles/batch`
+// endpoint writing several roles at once. It must be registered
+// before the roles path, which would otherwise match it.
+func pathRolesBatch(b *grafanaCloudBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "roles/" + rolesBatchName + "$",
+		Fields: map[string]*framework.FieldSchema{
+			"roles": {
+				Type:        framework.TypeSlice,
+				Description: "List of role definitions, each with a name and the fields accepted by the roles path",
+				Required:    true,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathRolesBatchWrite,
+				Summary:  "Create or update several roles.",
+			},
+		},
+		HelpSynopsis:    pathRolesBatchHelpSyn,
+		HelpDescription: pathRolesBatchHelpDesc,
+	}
+}
+
+const pathRolesBatchHelpSyn = `
+Create or update several roles at once.
+`
+
+const pathRolesBatchHelpDesc = `
+This path accepts a list of role definitions, each with a name and the fields
+accepted by the roles path. Roles which do not exist are created, existing
+roles are updated. Every definition is validated before any role is written,
+so if one is invalid none of the roles are written.
+`
+
+// batchRole is a role definition of a batch which passed validation.
+type batchRole struct {
+	name  string
+	entry *RoleEntry
+}
+
+func (b *grafanaCloudBackend) pathRolesBatchWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	definitions := d.Get("roles").([]interface{})
+	if len(definitions) == 0 {
+		return logical.ErrorResponse("roles must contain at least one role definition"), nil
+	}
+
+	config, err := getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make([]batchRole, 0, len(definitions))
+	seen := map[string]bool{}
+
+	var problems []string
+
+	for i, definition := range definitions {
+		role, problem, err := b.validateBatchRole(ctx, req.Storage, config, definition, seen)
+		if err != nil {
+			return nil, err
+		}
+
+		if problem != "" {
+			problems = append(problems, fmt.Sprintf("%s: %s", batchRoleLabel(i, definition), problem))
+			continue
+		}
+
+		roles = append(roles, role)
+	}
+
+	if len(problems) > 0 {
+		return logical.ErrorResponse(fmt.Sprintf("no roles were written, invalid role definitions: %s", strings.Join(problems, "; "))), nil
+	}
+
+	resp := &logical.Response{}
+	written := make(map[string]interface{}, len(roles))
+
+	for _, role := range roles {
+		if err := b.setRole(ctx, req.Storage, role.name, role.entry); err != nil {
+			return nil, fmt.Errorf("error writing role %q: %w", role.name, err)
+		}
+
+		roleResp := b.roleWriteResponse(role.entry)
+		written[role.name] = roleResp.Data
+
+		for _, warning := range roleResp.Warnings {
+			resp.AddWarning(fmt.Sprintf("role %q: %s", role.name, warning))
+		}
+	}
+
+	resp.Data = map[string]interface{}{
+		"roles": written,
+	}
+
+	return resp, nil
+}
+
+// validateBatchRole applies a role definition of a batch to the stored role
+// with its name, or a new role if there is none, without writing it. Invalid
+// definitions are reported as a problem rather than an error.
+func (b *grafanaCloudBackend) validateBatchRole(ctx context.Context, s logical.Storage, config *Config,
+	definition interface{}, seen map[string]bool,
+) (batchRole, string, error) {
+	raw, ok := definition.(map[string]interface{})
+	if !ok {
+		return batchRole{}, "must be an object", nil
+	}
+
+	name, _ := raw["name"].(string)
+
+	switch {
+	case name == "":
+		return batchRole{}, "missing role name", nil
+	case !roleNameRegex.MatchString(name):
+		return batchRole{}, "invalid role name", nil
+	case name == rolesBatchName:
+		return batchRole{}, "role name is reserved", nil
+	case seen[name]:
+		return batchRole{}, "role is defined more than once", nil
+	}
+
+	seen[name] = true
+
+	fields := roleFields()
+
+	for field := range raw {
+		if _, ok := fields[field]; !ok {
+			return batchRole{}, fmt.Sprintf("unknown field %q", field), nil
+		}
+	}
+
+	d := &framework.FieldData{Raw: raw, Schema: fields}
+	if err := d.Validate(); err != nil {
+		return batchRole{}, err.Error(), nil
+	}
+
+	entry, err := b.getRole(ctx, s, name)
+	if err != nil {
+		return batchRole{}, "", err
+	}
+
+	createOperation := entry == nil
+	if createOperation {
+		entry = &RoleEntry{}
+	}
+
+	resp, err := updateRole(entry, config, d, createOperation)
+	if err != nil {
+		return batchRole{}, "", err
+	}
+
+	if resp.IsError() {
+		return batchRole{}, resp.Error().Error(), nil
+	}
+
+	return batchRole{name: name, entry: entry}, "", nil
+}
+
+// batchRoleLabel names a role definition of a batch in error messages, by
+// its name if it has one and otherwise by its position.
+func batchRoleLabel(i int, definition interface{}) string {
+	if raw, ok := definition.(map[string]interface{}); ok {
+		if name, ok := raw["name"].(string); ok && name != "" {
+			return fmt.Sprintf("role %q", name)
+		}
+	}
+
+	return fmt.Sprintf("role %d", i)
+}
@@ -0,0 +1,108 @@
+package secretsengine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func testRolesBatchWrite(t *testing.T, b *grafanaCloudBackend, s logical.Storage, roles []interface{}) *logical.Response {
+	t.Helper()
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "roles/batch",
+		Data:      map[string]interface{}{"roles": roles},
+		Storage:   s,
+	})
+	require.NoError(t, err)
+
+	return resp
+}
+
+func TestRolesBatch(t *testing.T) {
+	t.Run("Write", func(t *testing.T) {
+		b, s := getTestBackend(t)
+
+		resp, err := testTokenRoleCreate(t, b, s, "existing", map[string]interface{}{"gc_role": "Viewer", "ttl": "60"})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+
+		resp = testRolesBatchWrite(t, b, s, []interface{}{
+			map[string]interface{}{"name": "new", "gc_role": "Admin", "max_ttl": "3600"},
+			map[string]interface{}{"name": "existing", "gc_role": "Editor"},
+		})
+		require.False(t, resp.IsError(), resp.Error())
+
+		roles := resp.Data["roles"].(map[string]interface{})
+		require.Len(t, roles, 2)
+		require.Equal(t, "Admin", roles["new"].(map[string]interface{})["gc_role"])
+
+		resp, err = testTokenRoleRead(t, b, s, "existing")
+		require.NoError(t, err)
+		require.Equal(t, "Editor", resp.Data["gc_role"])
+		require.EqualValues(t, 60, resp.Data["ttl"], "fields not in the definition keep their values")
+
+		resp, err = testTokenRoleRead(t, b, s, "new")
+		require.NoError(t, err)
+		require.Equal(t, "Admin", resp.Data["gc_role"])
+		require.EqualValues(t, 3600, resp.Data["max_ttl"])
+	})
+
+	t.Run("Invalid definitions", func(t *testing.T) {
+		b, s := getTestBackend(t)
+
+		resp := testRolesBatchWrite(t, b, s, []interface{}{
+			map[string]interface{}{"name": "valid", "gc_role": "Viewer"},
+			map[string]interface{}{"name": "invalid", "gc_role": "Owner"},
+			map[string]interface{}{"gc_role": "Viewer"},
+			map[string]interface{}{"name": "valid", "gc_role": "Viewer"},
+			map[string]interface{}{"name": "typo", "gc_rol": "Viewer"},
+			map[string]interface{}{"name": "batch", "gc_role": "Viewer"},
+			"viewer",
+		})
+		require.True(t, resp.IsError())
+		require.EqualError(t, resp.Error(), "no roles were written, invalid role definitions: "+
+			`role "invalid": provided gc_role Owner is not valid; `+
+			"role 2: missing role name; "+
+			`role "valid": role is defined more than once; `+
+			`role "typo": unknown field "gc_rol"; `+
+			`role "batch": role name is reserved; `+
+			"role 6: must be an object")
+
+		resp, err := testTokenRoleList(t, b, s)
+		require.NoError(t, err)
+		require.Empty(t, resp.Data["keys"], "no role is written when a definition is invalid")
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		b, s := getTestBackend(t)
+
+		resp := testRolesBatchWrite(t, b, s, []interface{}{})
+		require.EqualError(t, resp.Error(), "roles must contain at least one role definition")
+	})
+}
+
+func TestRoleNamedBatch(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	path := pathRole(b)[0]
+
+	resp, err := b.pathRolesWrite(context.Background(), &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      "roles/batch",
+		Storage:   s,
+	}, &framework.FieldData{
+		Raw:    map[string]interface{}{"name": "batch", "gc_role": "Viewer"},
+		Schema: path.Fields,
+	})
+	require.NoError(t, err)
+	require.EqualError(t, resp.Error(), `role name "batch" is reserved`)
+
+	role, err := b.getRole(context.Background(), s, "batch")
+	require.NoError(t, err)
+	require.Nil(t, role)
+}
@@ -2,9 +2,12 @@ package secretsengine
 
 import (
 	"context"
+	"fmt"
 	"strconv"
 	"testing"
+	"time"
 
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client/clienttest"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/stretchr/testify/require"
 )
@@ -45,7 +48,22 @@ func TestUserRole(t *testing.T) {
 
 		require.Nil(t, err)
 		require.Nil(t, resp.Error())
-		require.Nil(t, resp)
+		require.Equal(t, gcRole, resp.Data["gc_role"])
+		require.Equal(t, float64(testTTL), resp.Data["ttl"])
+		require.Equal(t, float64(testTTL), resp.Data["effective_ttl"])
+		require.Equal(t, float64(testMaxTTL), resp.Data["effective_max_ttl"])
+	})
+
+	t.Run("Create User Role - effective TTLs from mount defaults", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "defaults", map[string]interface{}{
+			"gc_role": gcRole,
+		})
+
+		require.Nil(t, err)
+		require.Nil(t, resp.Error())
+		require.Equal(t, float64(0), resp.Data["ttl"])
+		require.Equal(t, b.System().DefaultLeaseTTL().Seconds(), resp.Data["effective_ttl"])
+		require.Equal(t, b.System().MaxLeaseTTL().Seconds(), resp.Data["effective_max_ttl"])
 	})
 
 	t.Run("Create User Role - fail on invalid", func(t *testing.T) {
@@ -135,7 +153,8 @@ func TestUserRole(t *testing.T) {
 
 		require.Nil(t, err)
 		require.Nil(t, resp.Error())
-		require.Nil(t, resp)
+		require.Equal(t, float64(60), resp.Data["effective_ttl"])
+		require.Equal(t, float64(5*3600), resp.Data["effective_max_ttl"])
 	})
 
 	t.Run("Re-read User Role - existing", func(t *testing.T) {
@@ -219,3 +238,176 @@ func testTokenRoleDelete(t *testing.T, b *grafanaCloudBackend, s logical.Storage
 		Storage:   s,
 	})
 }
+
+func TestRolePreview(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	t.Run("Preview - not configured", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "metrics", map[string]interface{}{
+			"gc_role": "MetricsPublisher",
+			"preview": true,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+
+		preview := resp.Data["preview"].(map[string]interface{})
+		require.Regexp(t, `^metrics_[0-9a-f-]{36}$`, preview["key_name"])
+		require.Contains(t, resp.Warnings, "the backend is not configured, token_prefix and environment are not applied to the preview")
+	})
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          configURL,
+		"organisation": organisation,
+		"token_prefix": "vault",
+		"environment":  "prod-eu",
+	}))
+
+	t.Run("Preview", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "metrics", map[string]interface{}{
+			"gc_role": "MetricsPublisher",
+			"ttl":     "1h",
+			"max_ttl": "2h",
+			"preview": true,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+		require.Empty(t, resp.Warnings)
+
+		preview := resp.Data["preview"].(map[string]interface{})
+		require.Regexp(t, `^vault_metrics_[0-9a-f-]{36}_prod-eu$`, preview["key_name"])
+		require.Equal(t, "vault_<role>_<uuid>_prod-eu", preview["key_name_format"])
+		require.Equal(t, "MetricsPublisher", preview["gc_role"])
+		require.Equal(t, int64(3600), preview["ttl"])
+		require.Equal(t, int64(7200), preview["max_ttl"])
+
+		read, err := testTokenRoleRead(t, b, s, "metrics")
+		require.NoError(t, err)
+		require.NotContains(t, read.Data, "preview")
+	})
+
+	t.Run("No preview by default", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "metrics", map[string]interface{}{
+			"gc_role": "MetricsPublisher",
+		})
+		require.NoError(t, err)
+		require.NotContains(t, resp.Data, "preview")
+	})
+}
+
+func TestDeleteAllRoles(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	for i := 1; i <= 3; i++ {
+		_, err := testTokenRoleCreate(t, b, s, vaultRole+strconv.Itoa(i), map[string]interface{}{
+			"gc_role": gcRole,
+		})
+		require.NoError(t, err)
+	}
+
+	deleteAll := func(d map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.DeleteOperation,
+			Path:      "roles",
+			Storage:   s,
+			Data:      d,
+		})
+	}
+
+	t.Run("Delete All Roles - not confirmed", func(t *testing.T) {
+		resp, err := deleteAll(nil)
+
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+		require.EqualError(t, resp.Error(), "deleting all roles requires confirm=true")
+
+		resp, err = testTokenRoleList(t, b, s)
+		require.NoError(t, err)
+		require.Len(t, resp.Data["keys"], 3)
+	})
+
+	t.Run("Delete All Roles - confirmed", func(t *testing.T) {
+		resp, err := deleteAll(map[string]interface{}{"confirm": true})
+
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+		require.Equal(t, []string{vaultRole + "1", vaultRole + "2", vaultRole + "3"}, resp.Data["deleted"])
+
+		resp, err = testTokenRoleList(t, b, s)
+		require.NoError(t, err)
+		require.Empty(t, resp.Data["keys"])
+	})
+}
+
+func TestConfiguredGCRoles(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	createRole := func(t *testing.T, gcRole string) *logical.Response {
+		t.Helper()
+
+		resp, err := testTokenRoleCreate(t, b, s, vaultRole, map[string]interface{}{
+			"gc_role": gcRole,
+		})
+		require.NoError(t, err)
+
+		return resp
+	}
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":                 key,
+		"url":                 configURL,
+		"organisation":        organisation,
+		"additional_gc_roles": "TracesPublisher, ",
+	}))
+
+	require.False(t, createRole(t, "TracesPublisher").IsError())
+	require.False(t, createRole(t, "MetricsPublisher").IsError())
+
+	require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{
+		"valid_gc_roles": "Viewer",
+	}))
+
+	require.False(t, createRole(t, "Viewer").IsError())
+	require.False(t, createRole(t, "TracesPublisher").IsError())
+	require.EqualError(t, createRole(t, "MetricsPublisher").Error(), "provided gc_role MetricsPublisher is not valid")
+
+	config, err := getConfig(context.Background(), s)
+	require.NoError(t, err)
+	require.Equal(t, []string{"TracesPublisher"}, config.AdditionalGCRoles)
+}
+
+func TestRoleTTLClampWarnings(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	systemMaxTTL := b.System().MaxLeaseTTL()
+	maxTTL := systemMaxTTL + time.Hour
+
+	resp, err := testTokenRoleCreate(t, b, s, "clamped", map[string]interface{}{
+		"gc_role": gcRole,
+		"ttl":     testTTL,
+		"max_ttl": int64(maxTTL.Seconds()),
+	})
+	require.NoError(t, err)
+	require.False(t, resp.IsError())
+
+	expected := fmt.Sprintf("max_ttl of %s exceeds the mount's max lease TTL of %s, leases are limited to %s", maxTTL, systemMaxTTL, systemMaxTTL)
+	require.Equal(t, []string{expected}, resp.Warnings)
+
+	server := clienttest.NewServer(organisation, key)
+	defer server.Close()
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          server.URL + "/api",
+		"organisation": organisation,
+	}))
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "creds/clamped",
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	require.False(t, resp.IsError())
+	require.Contains(t, resp.Warnings, expected)
+}
@@ -0,0 +1,142 @@
+package secretsengine
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// redacted replaces sensitive values in the settings.
+const redacted = "<redacted>"
+
+// pathSettings extends the Vault API with a `/settings`
+// endpoint returning the settings the backend operates
+// with, after defaults are applied.
+func pathSettings(b *grafanaCloudBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "settings",
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathSettingsRead,
+				Summary:  "Read the effective settings of the backend.",
+			},
+		},
+		HelpSynopsis:    pathSettingsHelpSyn,
+		HelpDescription: pathSettingsHelpDesc,
+	}
+}
+
+const pathSettingsHelpSyn = `
+Read the effective settings of the secrets engine.
+`
+
+const pathSettingsHelpDesc = `
+This path returns the settings the plugin operates with: the stored config
+with the defaults of unset fields applied, the URLs requests are sent to,
+the mount's lease limits and the built-in intervals. Admin keys are
+redacted. The plugin reads no environment variables, so nothing else
+affects its behaviour.
+`
+
+func (b *grafanaCloudBackend) pathSettingsRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		return logical.ErrorResponse("backend not configured"), nil
+	}
+
+	apiURL, err := normalizeAPIURL(config.URL)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	gcRoles := make([]string, 0, len(config.cloudRoles()))
+	for role := range config.cloudRoles() {
+		gcRoles = append(gcRoles, role)
+	}
+
+	sort.Strings(gcRoles)
+
+	// Without tls settings the defaults of the Go runtime apply.
+	tlsMinVersion := config.TLSMinVersion
+	if tlsMinVersion == "" {
+		tlsMinVersion = "go_default"
+	}
+
+	settings := map[string]interface{}{
+		"organisation":               config.Organisation,
+		"api_url":                    apiURL + "/api",
+		"fallback_api_url":           "",
+		"region_api_urls":            config.Regions,
+		"key":                        redactedValue(config.Key),
+		"secondary_key":              redactedValue(config.SecondaryKey),
+		"key_expires_at":             "",
+		"key_name_format":            keyNameFormat(config),
+		"gc_roles":                   gcRoles,
+		"protected_key_prefixes":     config.ProtectedKeyPrefixes,
+		"verify_revocation":          config.VerifyRevocation,
+		"maintenance_mode":           config.MaintenanceMode,
+		"seal_wrap_issuance_records": config.SealWrapIssuanceRecords,
+		"debug_paths":                config.DebugPaths,
+		"webhook_url":                config.WebhookURL,
+		"webhook_secret":             redactedValue(config.WebhookSecret),
+		"max_outstanding_keys":       config.MaxOutstandingKeys,
+		"issuance_rate_window":       int64(config.issuanceRateWindow().Seconds()),
+		"issuance_rate_warn":         config.IssuanceRateWarn,
+		"issuance_rate_deny":         config.IssuanceRateDeny,
+		"tls_min_version":            tlsMinVersion,
+		"tls_cipher_suites":          config.TLSCipherSuites,
+		"ca_certificate_configured":  config.CACertificate != "",
+		"stack_cache_ttl":            int64(config.stackCacheTTL().Seconds()),
+		"endpoints":                  config.Endpoints.toResponseData(),
+		"default_lease_ttl":          int64(b.System().DefaultLeaseTTL().Seconds()),
+		"max_lease_ttl":              int64(b.System().MaxLeaseTTL().Seconds()),
+		"inventory_interval":         int64(inventoryInterval.Seconds()),
+		"issuance_history_retention": int64(issuanceHistoryRetention.Seconds()),
+		"upstream_retry_after":       int64(upstreamRetryAfter.Seconds()),
+	}
+
+	if config.FallbackURL != "" {
+		settings["fallback_api_url"] = config.FallbackURL + "/api"
+	}
+
+	if config.KeyExpiresAt != nil {
+		settings["key_expires_at"] = config.KeyExpiresAt.Format(time.RFC3339)
+	}
+
+	// user is the deprecated alias of prometheus_user.
+	delete(settings["endpoints"].(map[string]interface{}), "user")
+
+	return &logical.Response{
+		Data: settings,
+	}, nil
+}
+
+// redactedValue hides a sensitive value, keeping whether it is set.
+func redactedValue(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	return redacted
+}
+
+// keyNameFormat describes the names keyName gives to issued keys.
+func keyNameFormat(config *Config) string {
+	format := "<role>_<uuid>"
+	if config.TokenPrefix != "" {
+		format = config.TokenPrefix + "_" + format
+	}
+
+	if config.Environment != "" {
+		format = format + "_" + config.Environment
+	}
+
+	return format
+}
@@ -0,0 +1,59 @@
+package secretsengine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSettingsRead(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	readSettings := func(t *testing.T) *logical.Response {
+		t.Helper()
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "settings",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+
+		return resp
+	}
+
+	t.Run("Not configured", func(t *testing.T) {
+		assert.EqualError(t, readSettings(t).Error(), "backend not configured")
+	})
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":                 key,
+		"url":                 "https://grafana.com/api/",
+		"organisation":        organisation,
+		"token_prefix":        "cluster-a",
+		"environment":         "prod-eu",
+		"additional_gc_roles": "TracesPublisher",
+		"prometheus_user":     testPrometheusUser,
+	}))
+
+	data := readSettings(t).Data
+
+	assert.Equal(t, "https://grafana.com/api", data["api_url"])
+	assert.Equal(t, redacted, data["key"])
+	assert.Equal(t, "", data["secondary_key"])
+	assert.Equal(t, "cluster-a_<role>_<uuid>_prod-eu", data["key_name_format"])
+	assert.Contains(t, data["gc_roles"], "TracesPublisher")
+	assert.Contains(t, data["gc_roles"], "MetricsPublisher")
+	assert.Equal(t, int64(3600), data["issuance_rate_window"])
+	assert.Equal(t, "go_default", data["tls_min_version"])
+	assert.Equal(t, false, data["ca_certificate_configured"])
+	assert.Equal(t, int64(600), data["inventory_interval"])
+	assert.Equal(t, int64(b.System().MaxLeaseTTL().Seconds()), data["max_lease_ttl"])
+
+	endpoints := data["endpoints"].(map[string]interface{})
+	assert.Equal(t, testPrometheusUser, endpoints["prometheus_user"])
+	assert.NotContains(t, endpoints, "user")
+}
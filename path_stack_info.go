@@ -0,0 +1,112 @@
+package secretsengine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client"
+	grafanclient "github.com/grafana/grafana-api-golang-client"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// pathStackInfo extends the Vault API with a `/stack-info`
+// endpoint returning the connection details of a stack's
+// hosted instances, without issuing a key.
+func pathStackInfo(b *grafanaCloudBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "stack-info/" + framework.GenericNameRegex("slug"),
+		Fields: map[string]*framework.FieldSchema{
+			"slug": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Slug of the Grafana Cloud stack",
+				Required:    true,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{Callback: b.pathStackInfoRead},
+		},
+		HelpSynopsis:    pathStackInfoHelpSyn,
+		HelpDescription: pathStackInfoHelpDesc,
+	}
+}
+
+const pathStackInfoHelpSyn = `
+Read the hosted instance IDs and URLs of a Grafana Cloud stack.
+`
+
+const pathStackInfoHelpDesc = `
+This path returns the instance IDs (used as basic auth users) and URLs of
+the hosted metrics, logs, traces, graphite and alertmanager instances of
+a stack in the configured organisation. No key is issued.
+`
+
+// stackInfoResponseData returns response data for a stack, using the
+// same user/url naming as the config and creds paths.
+func stackInfoResponseData(stack *grafanclient.Stack) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                stack.ID,
+		"slug":              stack.Slug,
+		"name":              stack.Name,
+		"url":               stack.URL,
+		"status":            stack.Status,
+		"region":            stack.RegionSlug,
+		"prometheus_user":   instanceID(stack.HmInstancePromID),
+		"prometheus_url":    stack.HmInstancePromURL,
+		"loki_user":         instanceID(stack.HlInstanceID),
+		"loki_url":          stack.HlInstanceURL,
+		"tempo_user":        instanceID(stack.HtInstanceID),
+		"tempo_url":         stack.HtInstanceURL,
+		"alertmanager_user": instanceID(stack.AmInstanceID),
+		"alertmanager_url":  stack.AmInstanceURL,
+		"graphite_user":     instanceID(stack.HmInstanceGraphiteID),
+		"graphite_url":      stack.HmInstanceGraphiteURL,
+	}
+}
+
+// instanceID formats a hosted instance ID, returning an empty
+// string for instances the stack does not have.
+func instanceID(id int) string {
+	if id == 0 {
+		return ""
+	}
+
+	return strconv.Itoa(id)
+}
+
+func (b *grafanaCloudBackend) pathStackInfoRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	c, err := b.getClient(ctx, req.Storage)
+	if err != nil {
+		return nil, NewInternalError("error getting client", err)
+	}
+
+	config, err := getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		return logical.ErrorResponse("backend not configured"), nil
+	}
+
+	slug := d.Get("slug").(string)
+
+	stack, err := b.stacks.get(c, slug, config.stackCacheTTL(), time.Now())
+	if err != nil {
+		if errors.Is(err, client.ErrNotFound) {
+			return logical.ErrorResponse(fmt.Sprintf("stack %q not found", slug)), nil
+		}
+
+		return errorResponse(NewInternalError("error retrieving stack", err))
+	}
+
+	respData := stackInfoResponseData(&stack)
+	respData["region_api_url"] = config.regionURL(stack.RegionSlug)
+
+	return &logical.Response{
+		Data: respData,
+	}, nil
+}
@@ -0,0 +1,121 @@
+package secretsengine
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStackInfo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/instances/teststack":
+		case "/api/instances/forbidden":
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"code":"Forbidden","message":"API key does not have Admin role"}`))
+			return
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":                1,
+			"slug":              "teststack",
+			"name":              "teststack",
+			"url":               "https://teststack.grafana.net",
+			"status":            "active",
+			"regionSlug":        "eu",
+			"hmInstancePromId":  11,
+			"hmInstancePromUrl": "https://prometheus.grafana.net",
+			"hlInstanceId":      12,
+			"hlInstanceUrl":     "https://logs.grafana.net",
+			"htInstanceId":      13,
+			"htInstanceUrl":     "https://tempo.grafana.net",
+			"amInstanceId":      14,
+			"amInstanceUrl":     "https://alertmanager.grafana.net",
+		})
+	}))
+	defer server.Close()
+
+	b, s := getTestBackend(t)
+
+	err := testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          server.URL + "/api",
+		"organisation": organisation,
+		"regions":      map[string]interface{}{"eu": "https://EU.grafana.example/api/"},
+	})
+	require.NoError(t, err)
+
+	t.Run("Read Stack Info - existing", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "stack-info/teststack",
+			Storage:   s,
+		})
+
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.Equal(t, "active", resp.Data["status"])
+		require.Equal(t, "eu", resp.Data["region"])
+		require.Equal(t, "https://eu.grafana.example", resp.Data["region_api_url"])
+		require.Equal(t, "11", resp.Data["prometheus_user"])
+		require.Equal(t, "https://prometheus.grafana.net", resp.Data["prometheus_url"])
+		require.Equal(t, "12", resp.Data["loki_user"])
+		require.Equal(t, "13", resp.Data["tempo_user"])
+		require.Equal(t, "14", resp.Data["alertmanager_user"])
+		require.Equal(t, "", resp.Data["graphite_user"])
+	})
+
+	t.Run("Read Stack Info - non existent", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "stack-info/missing",
+			Storage:   s,
+		})
+
+		require.NoError(t, err)
+		require.EqualError(t, resp.Error(), `stack "missing" not found`)
+	})
+
+	t.Run("Read Stack Info - upstream error", func(t *testing.T) {
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			ID:        "stack-info-request",
+			Operation: logical.ReadOperation,
+			Path:      "stack-info/forbidden",
+			Storage:   s,
+		})
+
+		require.EqualError(t, err, "internal error: error retrieving stack: 403: API key does not have Admin role (correlation_id: stack-info-request)")
+	})
+
+	t.Run("Read Stack Info - upstream unreachable", func(t *testing.T) {
+		unreachable := httptest.NewServer(http.NotFoundHandler())
+		unreachable.Close()
+
+		b, s := getTestBackend(t)
+
+		require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+			"key":          key,
+			"url":          unreachable.URL + "/api",
+			"organisation": organisation,
+		}))
+
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "stack-info/teststack",
+			Storage:   s,
+		})
+
+		var coded logical.HTTPCodedError
+		require.ErrorAs(t, err, &coded)
+		require.Equal(t, http.StatusServiceUnavailable, coded.Code())
+		require.Contains(t, err.Error(), "Grafana Cloud is unavailable, retry in 30 seconds")
+	})
+}
@@ -0,0 +1,94 @@
+package secretsengine
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// pathStacks extends the Vault API with a `/stacks`
+// endpoint listing the stacks of the organisation.
+func pathStacks(b *grafanaCloudBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "stacks/?$",
+		Fields: map[string]*framework.FieldSchema{
+			"region": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Only list the stacks in this region, e.g. prod-eu-west-0",
+			},
+			"status": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Only list the stacks with this status, e.g. active",
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ListOperation: &framework.PathOperation{
+				Callback: b.pathStacksList,
+				Summary:  "List the stacks of the organisation.",
+			},
+		},
+		HelpSynopsis:    pathStacksHelpSyn,
+		HelpDescription: pathStacksHelpDesc,
+	}
+}
+
+const pathStacksHelpSyn = `
+List the stacks of the Grafana Cloud organisation.
+`
+
+const pathStacksHelpDesc = `
+This path lists the slugs of the stacks in the configured organisation,
+with the name, URL, status and region of each. The region and status
+fields narrow the listing to the stacks matching them. The instance IDs and
+URLs of a stack are read from stack-info/<slug>.
+`
+
+func (b *grafanaCloudBackend) pathStacksList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		return logical.ErrorResponse("backend not configured"), nil
+	}
+
+	c, err := b.getClient(ctx, req.Storage)
+	if err != nil {
+		return nil, NewInternalError("error getting client", err)
+	}
+
+	stacks, err := c.Stacks()
+	if err != nil {
+		return errorResponse(NewInternalError("error listing stacks", err))
+	}
+
+	region := d.Get("region").(string)
+	status := d.Get("status").(string)
+
+	slugs := []string{}
+	stackInfo := map[string]interface{}{}
+
+	for _, stack := range stacks.Items {
+		// The admin key may have access to stacks of other organisations.
+		if !strings.EqualFold(stack.OrgSlug, config.Organisation) {
+			continue
+		}
+
+		if (region != "" && strings.ToLower(stack.RegionSlug) != region) || (status != "" && strings.ToLower(stack.Status) != status) {
+			continue
+		}
+
+		slugs = append(slugs, stack.Slug)
+		stackInfo[stack.Slug] = map[string]interface{}{
+			"name":   stack.Name,
+			"url":    stack.URL,
+			"status": stack.Status,
+			"region": stack.RegionSlug,
+		}
+	}
+
+	return logical.ListResponseWithInfo(slugs, stackInfo), nil
+}
@@ -0,0 +1,60 @@
+package secretsengine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client/clienttest"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStacksList(t *testing.T) {
+	server := clienttest.NewServer(organisation, key)
+	defer server.Close()
+
+	server.AddStack("eu-active")
+	server.AddStack("eu-paused")
+	server.SetStackStatus("eu-paused", "paused")
+	server.AddStack("us-active").RegionSlug = "us"
+	server.AddStack("other-org").OrgSlug = "other"
+
+	b, s := getTestBackend(t)
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          server.URL + "/api",
+		"organisation": organisation,
+	}))
+
+	list := func(t *testing.T, data map[string]interface{}) *logical.Response {
+		t.Helper()
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ListOperation,
+			Path:      "stacks/",
+			Storage:   s,
+			Data:      data,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+
+		return resp
+	}
+
+	resp := list(t, nil)
+	assert.Equal(t, []string{"eu-active", "eu-paused", "us-active"}, resp.Data["keys"])
+	assert.Equal(t, map[string]interface{}{
+		"name":   "eu-paused",
+		"url":    server.URL + "/stacks/eu-paused",
+		"status": "paused",
+		"region": "eu",
+	}, resp.Data["key_info"].(map[string]interface{})["eu-paused"])
+
+	resp = list(t, map[string]interface{}{"region": "EU", "status": "active"})
+	assert.Equal(t, []string{"eu-active"}, resp.Data["keys"])
+
+	resp = list(t, map[string]interface{}{"region": "ap"})
+	assert.Empty(t, resp.Data["keys"])
+}
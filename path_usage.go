@@ -0,0 +1,109 @@
+package secretsengine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-secure-stdlib/parseutil"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// pathUsage extends the Vault API with a `/usage`
+// endpoint summarising the keys issued per role.
+func pathUsage(b *grafanaCloudBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "usage/?$",
+		Fields: map[string]*framework.FieldSchema{
+			"windows": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Time windows to count issued keys over, such as 1h or 7d.",
+				Default:     []string{"1h", "24h", "7d"},
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathUsageRead,
+				Summary:  "Count the keys issued per role.",
+			},
+		},
+		HelpSynopsis:    pathUsageHelpSyn,
+		HelpDescription: pathUsageHelpDesc,
+	}
+}
+
+const pathUsageHelpSyn = `
+Count the keys issued per role over time windows.
+`
+
+const pathUsageHelpDesc = `
+This path returns, for each role, the number of keys issued within each of
+the requested time windows, 1h, 24h and 7d by default. The counts include
+revoked keys, whose records are kept for 7 days, so windows longer than that
+undercount.
+`
+
+// usageWindow is a time window the usage is counted over, labelled as requested.
+type usageWindow struct {
+	label    string
+	duration time.Duration
+}
+
+func (b *grafanaCloudBackend) pathUsageRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	raw := d.Get("windows").([]string)
+	windows := make([]usageWindow, 0, len(raw))
+
+	for _, label := range raw {
+		label = strings.TrimSpace(label)
+
+		duration, err := parseutil.ParseDurationSecond(label)
+		if err != nil || duration <= 0 {
+			return logical.ErrorResponse("invalid window %q, expected a positive duration such as 1h or 7d", label), nil
+		}
+
+		windows = append(windows, usageWindow{label: label, duration: duration})
+	}
+
+	records, err := issuanceHistory(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	roles := map[string]interface{}{}
+
+	for _, record := range records {
+		counts, ok := roles[record.Role].(map[string]int)
+		if !ok {
+			counts = make(map[string]int, len(windows))
+			for _, window := range windows {
+				counts[window.label] = 0
+			}
+
+			roles[record.Role] = counts
+		}
+
+		for _, window := range windows {
+			if record.IssuedAt.After(now.Add(-window.duration)) {
+				counts[window.label]++
+			}
+		}
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"roles": roles,
+		},
+	}
+
+	for _, window := range windows {
+		if window.duration > issuanceHistoryRetention {
+			resp.AddWarning(fmt.Sprintf("window %s is longer than the %s the records of revoked keys are kept for, its counts are incomplete",
+				window.label, issuanceHistoryRetention))
+		}
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,104 @@
+package secretsengine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageRead(t *testing.T) {
+	b, s := getTestBackend(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	for i, issued := range []struct {
+		role string
+		age  time.Duration
+	}{
+		{"metrics_publisher", 10 * time.Minute},
+		{"metrics_publisher", 2 * time.Hour},
+		{"metrics_publisher", 3 * 24 * time.Hour},
+		{"viewer", 30 * time.Minute},
+		{"viewer", 10 * 24 * time.Hour},
+	} {
+		require.NoError(t, putIssuanceRecord(ctx, s, &issuanceRecord{
+			Name:     issued.role + "_" + string(rune('a'+i)),
+			Role:     issued.role,
+			IssuedAt: now.Add(-issued.age),
+		}))
+	}
+
+	// Revoked keys are still counted.
+	require.NoError(t, revokeIssuanceRecord(ctx, s, "metrics_publisher_b", now))
+
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "usage",
+		Storage:   s,
+	})
+	require.NoError(t, err)
+	require.False(t, resp.IsError())
+	assert.Empty(t, resp.Warnings)
+
+	assert.Equal(t, map[string]interface{}{
+		"metrics_publisher": map[string]int{"1h": 1, "24h": 2, "7d": 3},
+		"viewer":            map[string]int{"1h": 1, "24h": 1, "7d": 1},
+	}, resp.Data["roles"])
+
+	t.Run("Custom windows", func(t *testing.T) {
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "usage",
+			Storage:   s,
+			Data:      map[string]interface{}{"windows": "15m,30d"},
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+
+		assert.Equal(t, map[string]interface{}{
+			"metrics_publisher": map[string]int{"15m": 1, "30d": 3},
+			"viewer":            map[string]int{"15m": 0, "30d": 2},
+		}, resp.Data["roles"])
+		assert.Len(t, resp.Warnings, 1)
+	})
+
+	t.Run("Invalid window", func(t *testing.T) {
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "usage",
+			Storage:   s,
+			Data:      map[string]interface{}{"windows": "1h,soon"},
+		})
+		require.NoError(t, err)
+		assert.EqualError(t, resp.Error(), `invalid window "soon", expected a positive duration such as 1h or 7d`)
+	})
+}
+
+func TestPruneIssuanceHistory(t *testing.T) {
+	_, s := getTestBackend(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	for _, name := range []string{"old", "recent", "outstanding"} {
+		require.NoError(t, putIssuanceRecord(ctx, s, &issuanceRecord{Name: name, Role: "role", IssuedAt: now.Add(-10 * 24 * time.Hour)}))
+	}
+
+	require.NoError(t, revokeIssuanceRecord(ctx, s, "old", now.Add(-8*24*time.Hour)))
+	require.NoError(t, revokeIssuanceRecord(ctx, s, "recent", now.Add(-time.Hour)))
+
+	pruned, err := pruneIssuanceHistory(ctx, s, now.Add(-issuanceHistoryRetention))
+	require.NoError(t, err)
+	assert.Equal(t, 1, pruned)
+
+	records, err := issuanceHistory(ctx, s)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "outstanding", records[0].Name)
+	assert.Nil(t, records[0].RevokedAt)
+	assert.Equal(t, "recent", records[1].Name)
+	assert.NotNil(t, records[1].RevokedAt)
+}
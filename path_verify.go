@@ -0,0 +1,250 @@
+package secretsengine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	verifyProbeTimeout = 10 * time.Second
+	verifyLogSource    = "vault-plugin-secrets-grafanacloud"
+)
+
+// pathVerify extends the Vault API with a `/verify`
+// endpoint for a role. It issues a throwaway key,
+// exercises the configured endpoints with it and
+// revokes it again.
+func pathVerify(b *grafanaCloudBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "verify/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeLowerCaseString,
+				Description: "Name of the role",
+				Required:    true,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation:   &framework.PathOperation{Callback: b.pathVerifyRead},
+			logical.UpdateOperation: &framework.PathOperation{Callback: b.pathVerifyRead},
+		},
+		HelpSynopsis:    pathVerifyHelpSyn,
+		HelpDescription: pathVerifyHelpDesc,
+	}
+}
+
+const pathVerifyHelpSyn = `
+Run an end-to-end check of credential issuance for a role.
+`
+
+const pathVerifyHelpDesc = `
+This path issues a temporary Grafana Cloud API key for the role, uses it
+to read from Prometheus and to write to and read from Loki (for whichever
+of those endpoints are configured), then deletes the key. The result of
+every step is reported so the whole pipeline can be checked in one call.
+`
+
+// verifyProbe is a single request made against a configured endpoint
+// using the throwaway key.
+type verifyProbe struct {
+	name   string
+	method string
+	url    string
+	user   string
+	body   []byte
+}
+
+// verifyResult is the outcome of a verifyProbe.
+type verifyResult struct {
+	OK         bool
+	StatusCode int
+	Error      string
+}
+
+func (r *verifyResult) toResponseData() map[string]interface{} {
+	respData := map[string]interface{}{
+		"ok":          r.OK,
+		"status_code": r.StatusCode,
+	}
+
+	if r.Error != "" {
+		respData["error"] = r.Error
+	}
+
+	return respData
+}
+
+// verifyProbes returns the probes that can be run for the endpoints
+// returned alongside key. Endpoints without both a user and a URL are skipped.
+func verifyProbes(key *GrafanaCloudKey, now time.Time) []verifyProbe {
+	var probes []verifyProbe
+
+	if key.PrometheusURL != "" && key.PrometheusUser != "" {
+		probes = append(probes, verifyProbe{
+			name:   "prometheus_read",
+			method: http.MethodGet,
+			url:    joinURLPath(key.PrometheusURL, "/api/v1/query") + "?" + url.Values{"query": {"vector(1)"}}.Encode(),
+			user:   key.PrometheusUser,
+		})
+	}
+
+	if key.LokiURL != "" && key.LokiUser != "" {
+		push := map[string]interface{}{
+			"streams": []interface{}{
+				map[string]interface{}{
+					"stream": map[string]string{"source": verifyLogSource},
+					"values": [][]string{
+						{strconv.FormatInt(now.UnixNano(), 10), "vault verify " + key.Name},
+					},
+				},
+			},
+		}
+		// Marshalling a map of strings cannot fail.
+		body, _ := json.Marshal(push)
+
+		probes = append(probes,
+			verifyProbe{
+				name:   "loki_write",
+				method: http.MethodPost,
+				url:    joinURLPath(key.LokiURL, "/loki/api/v1/push"),
+				user:   key.LokiUser,
+				body:   body,
+			},
+			verifyProbe{
+				name:   "loki_read",
+				method: http.MethodGet,
+				url:    joinURLPath(key.LokiURL, "/loki/api/v1/labels"),
+				user:   key.LokiUser,
+			},
+		)
+	}
+
+	return probes
+}
+
+// run performs the probe using token, if any, as the basic auth password.
+func (p *verifyProbe) run(ctx context.Context, c *http.Client, token string) *verifyResult {
+	ctx, cancel := context.WithTimeout(ctx, verifyProbeTimeout)
+	defer cancel()
+
+	var body io.Reader
+	if p.body != nil {
+		body = bytes.NewReader(p.body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, p.method, p.url, body)
+	if err != nil {
+		return &verifyResult{Error: err.Error()}
+	}
+
+	if token != "" {
+		req.SetBasicAuth(p.user, token)
+	}
+	if p.body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return &verifyResult{Error: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	result := &verifyResult{
+		OK:         resp.StatusCode >= 200 && resp.StatusCode < 300,
+		StatusCode: resp.StatusCode,
+	}
+
+	if !result.OK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		result.Error = strings.TrimSpace(string(msg))
+	}
+
+	return result
+}
+
+func joinURLPath(base, path string) string {
+	return strings.TrimSuffix(base, "/") + path
+}
+
+func (b *grafanaCloudBackend) pathVerifyRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	roleName := d.Get("name").(string)
+
+	roleEntry, err := b.getRole(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, NewInternalError("error retrieving role", err)
+	}
+
+	if roleEntry == nil {
+		return roleNotFoundResponse(roleName)
+	}
+
+	if err := checkBoundCIDRs(roleEntry.BoundCIDRs, req.Connection); err != nil {
+		return errorResponse(err)
+	}
+
+	if err := checkBoundEntityMetadata(roleEntry.BoundEntityMetadata, b.System(), req.EntityID); err != nil {
+		return errorResponse(err)
+	}
+
+	key, warnings, err := b.createKey(ctx, req.Storage, roleName, roleEntry)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	success := true
+	checks := map[string]interface{}{}
+	httpClient, err := b.getHTTPClient(ctx, req.Storage)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	probes := verifyProbes(key, time.Now())
+	for i := range probes {
+		result := probes[i].run(ctx, httpClient, key.Token)
+		checks[probes[i].name] = result.toResponseData()
+		success = success && result.OK
+	}
+
+	revoked := true
+	if err := b.revokeKey(ctx, req.Storage, key.secretData(roleName)); err != nil {
+		b.Logger().Error("failed to delete verification key", "name", key.Name, "error", err)
+		revoked = false
+		success = false
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"role":     roleName,
+			"key_name": key.Name,
+			"checks":   checks,
+			"revoked":  revoked,
+			"success":  success,
+		},
+	}
+
+	for _, warning := range warnings {
+		resp.AddWarning(warning)
+	}
+
+	if len(probes) == 0 {
+		resp.AddWarning("no prometheus or loki endpoints are configured, only key issuance and revocation were verified")
+	}
+
+	if !revoked {
+		resp.AddWarning(fmt.Sprintf("key %s could not be deleted and must be removed manually", key.Name))
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,95 @@
+package secretsengine
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+const testVerifyToken = "verify-token"
+
+func TestVerify(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+
+		user, pass, ok := r.BasicAuth()
+		if !ok || pass != testVerifyToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.URL.Path == "/api/prom/api/v1/query" && user == testPrometheusUser:
+			require.Equal(t, "vector(1)", r.URL.Query().Get("query"))
+			w.WriteHeader(http.StatusOK)
+		case r.URL.Path == "/loki/api/v1/push" && user == testLokiUser:
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte("insufficient scope"))
+		case r.URL.Path == "/loki/api/v1/labels" && user == testLokiUser:
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t.Run("Probes - only configured endpoints", func(t *testing.T) {
+		probes := verifyProbes(&GrafanaCloudKey{
+			PrometheusURL: server.URL,
+			LokiUser:      testLokiUser,
+		}, time.Now())
+
+		require.Empty(t, probes)
+	})
+
+	t.Run("Probes - results", func(t *testing.T) {
+		requests = nil
+
+		probes := verifyProbes(&GrafanaCloudKey{
+			Name:           "role_key",
+			PrometheusUser: testPrometheusUser,
+			PrometheusURL:  server.URL + "/api/prom/",
+			LokiUser:       testLokiUser,
+			LokiURL:        server.URL,
+		}, time.Now())
+		require.Len(t, probes, 3)
+
+		results := map[string]*verifyResult{}
+		for i := range probes {
+			results[probes[i].name] = probes[i].run(context.Background(), cleanhttp.DefaultClient(), testVerifyToken)
+		}
+
+		require.Equal(t, []string{
+			"GET /api/prom/api/v1/query",
+			"POST /loki/api/v1/push",
+			"GET /loki/api/v1/labels",
+		}, requests)
+
+		require.True(t, results["prometheus_read"].OK)
+		require.False(t, results["loki_write"].OK)
+		require.Equal(t, http.StatusForbidden, results["loki_write"].StatusCode)
+		require.Equal(t, "insufficient scope", results["loki_write"].Error)
+		require.True(t, results["loki_read"].OK)
+	})
+
+	t.Run("Verify - missing role", func(t *testing.T) {
+		b, s := getTestBackend(t)
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "verify/missing",
+			Storage:   s,
+		})
+
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.True(t, resp.IsError())
+	})
+}
@@ -0,0 +1,74 @@
+package secretsengine
+
+import (
+	"context"
+	"runtime/debug"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// recoverHandlers wraps the callbacks of all paths and secrets, so a panic
+// while handling a single request is logged and returned as an internal
+// error instead of crashing the plugin process.
+func (b *grafanaCloudBackend) recoverHandlers() {
+	for _, p := range b.Paths {
+		for _, handler := range p.Operations {
+			if op, ok := handler.(*framework.PathOperation); ok && op.Callback != nil {
+				op.Callback = b.recoverCallback(op.Callback)
+			}
+		}
+
+		for operation, callback := range p.Callbacks {
+			p.Callbacks[operation] = b.recoverCallback(callback)
+		}
+
+		if p.ExistenceCheck != nil {
+			p.ExistenceCheck = b.recoverExistenceCheck(p.ExistenceCheck)
+		}
+	}
+
+	for _, secret := range b.Secrets {
+		if secret.Renew != nil {
+			secret.Renew = b.recoverCallback(secret.Renew)
+		}
+
+		if secret.Revoke != nil {
+			secret.Revoke = b.recoverCallback(secret.Revoke)
+		}
+	}
+}
+
+func (b *grafanaCloudBackend) recoverCallback(callback framework.OperationFunc) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (resp *logical.Response, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				resp, err = nil, b.recoveredPanic(req, r)
+			}
+		}()
+
+		return callback(ctx, req, data)
+	}
+}
+
+func (b *grafanaCloudBackend) recoverExistenceCheck(check framework.ExistenceFunc) framework.ExistenceFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (exists bool, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				exists, err = false, b.recoveredPanic(req, r)
+			}
+		}()
+
+		return check(ctx, req, data)
+	}
+}
+
+// recoveredPanic logs a panic recovered while handling req, along with the
+// stack trace, and returns the error reported to the caller. The panic value
+// is left out of the error as it may contain sensitive data.
+func (b *grafanaCloudBackend) recoveredPanic(req *logical.Request, r interface{}) error {
+	b.Logger().Error("recovered from panic while handling request",
+		"path", req.Path, "operation", req.Operation, "panic", r, "stack", string(debug.Stack()))
+
+	return NewInternalError("unexpected failure handling the request, see the plugin logs for details", nil)
+}
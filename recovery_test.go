@@ -0,0 +1,67 @@
+package secretsengine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoverHandlers(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	panicking := func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		var config *Config
+		return &logical.Response{Data: map[string]interface{}{"key": config.Key}}, nil
+	}
+
+	t.Run("Callback", func(t *testing.T) {
+		resp, err := b.recoverCallback(panicking)(context.Background(), &logical.Request{Path: "panic", Storage: s}, nil)
+		require.Nil(t, resp)
+		require.EqualError(t, err, "internal error: unexpected failure handling the request, see the plugin logs for details")
+	})
+
+	t.Run("Existence check", func(t *testing.T) {
+		check := func(ctx context.Context, req *logical.Request, data *framework.FieldData) (bool, error) {
+			panic("existence check")
+		}
+
+		exists, err := b.recoverExistenceCheck(check)(context.Background(), &logical.Request{Path: "panic", Storage: s}, nil)
+		require.False(t, exists)
+		require.EqualError(t, err, "internal error: unexpected failure handling the request, see the plugin logs for details")
+	})
+
+	t.Run("Paths are wrapped", func(t *testing.T) {
+		b := backend()
+		b.Paths = append(b.Paths, &framework.Path{
+			Pattern: "panic",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{Callback: panicking},
+			},
+		})
+		b.recoverHandlers()
+
+		require.NoError(t, b.Setup(context.Background(), &logical.BackendConfig{
+			StorageView: s,
+			System:      logical.TestSystemView(),
+		}))
+
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			ID:        "panic-request",
+			Operation: logical.ReadOperation,
+			Path:      "panic",
+			Storage:   s,
+		})
+		require.EqualError(t, err, "internal error: unexpected failure handling the request, see the plugin logs for details (correlation_id: panic-request)")
+
+		// Requests to other paths still succeed after a panic.
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "config",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,69 @@
+package secretsengine
+
+import (
+	"sync"
+	"time"
+)
+
+// revocationErrorLogInterval is how often revocation errors are logged. When
+// Grafana Cloud is down every lease fails revocation, further errors within
+// the interval are counted and logged as a summary instead.
+const revocationErrorLogInterval = time.Minute
+
+// revocationErrorLog rate limits the logging of revocation errors.
+type revocationErrorLog struct {
+	mu         sync.Mutex
+	lastLogged time.Time
+	suppressed int
+	lastError  string
+}
+
+// record reports whether a revocation error at now should be logged, and
+// otherwise counts it for the next summary.
+func (l *revocationErrorLog) record(err error, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.lastLogged.IsZero() || now.Sub(l.lastLogged) >= revocationErrorLogInterval {
+		l.lastLogged = now
+		return true
+	}
+
+	l.suppressed++
+	l.lastError = err.Error()
+
+	return false
+}
+
+// summary returns the number of errors not logged since the last summary
+// and the last of them, and resets the count.
+func (l *revocationErrorLog) summary(now time.Time) (int, string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	suppressed, lastError := l.suppressed, l.lastError
+	if suppressed > 0 {
+		l.lastLogged = now
+	}
+
+	l.suppressed, l.lastError = 0, ""
+
+	return suppressed, lastError
+}
+
+// logRevocationError logs the failure to revoke the named key, unless
+// another was logged within revocationErrorLogInterval.
+func (b *grafanaCloudBackend) logRevocationError(name string, err error, now time.Time) {
+	if b.revocationErrors.record(err, now) {
+		b.Logger().Error("failed to revoke key", "name", name, "error", err)
+	}
+}
+
+// logRevocationErrorSummary logs how many revocation errors were not logged
+// since it was last called, if any.
+func (b *grafanaCloudBackend) logRevocationErrorSummary(now time.Time) {
+	if suppressed, lastError := b.revocationErrors.summary(now); suppressed > 0 {
+		b.Logger().Error("failed to revoke more keys, revocation errors are only logged once per interval",
+			"count", suppressed, "interval", revocationErrorLogInterval, "last_error", lastError)
+	}
+}
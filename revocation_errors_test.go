@@ -0,0 +1,37 @@
+package secretsengine
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevocationErrorLog(t *testing.T) {
+	start := time.Date(2026, time.October, 14, 9, 0, 0, 0, time.UTC)
+	unavailable := errors.New("503: service unavailable")
+
+	var l revocationErrorLog
+
+	require.True(t, l.record(unavailable, start), "the first error is logged")
+
+	for i := 1; i <= 100; i++ {
+		require.False(t, l.record(unavailable, start.Add(time.Duration(i)*100*time.Millisecond)))
+	}
+
+	suppressed, lastError := l.summary(start.Add(30 * time.Second))
+	require.Equal(t, 100, suppressed)
+	require.Equal(t, "503: service unavailable", lastError)
+
+	// The summary counts as logging, the next error is only logged a minute later.
+	require.False(t, l.record(unavailable, start.Add(time.Minute)))
+	require.True(t, l.record(unavailable, start.Add(90*time.Second)))
+
+	suppressed, _ = l.summary(start.Add(2 * time.Minute))
+	require.Equal(t, 1, suppressed)
+
+	suppressed, lastError = l.summary(start.Add(3 * time.Minute))
+	require.Zero(t, suppressed)
+	require.Empty(t, lastError)
+}
@@ -0,0 +1,88 @@
+package secretsengine
+
+import (
+	"strings"
+	"sync"
+)
+
+// roleCache keeps the decoded roles read from storage, so issuing keys does
+// not read and decode the role on every request. Entries are
+// dropped when the role is written or deleted, and on replicated nodes when
+// Vault invalidates the role's storage key, so standbys never serve a role
+// that was changed on the active node.
+type roleCache struct {
+	mu    sync.RWMutex
+	roles map[string]*RoleEntry
+	// generation is bumped whenever roles are dropped, so a role read from
+	// storage before that is not cached after it, see set.
+	generation uint64
+}
+
+func newRoleCache() *roleCache {
+	return &roleCache{roles: map[string]*RoleEntry{}}
+}
+
+// get returns a copy of the cached role, so changes made by the caller are
+// not seen by other requests.
+func (rc *roleCache) get(name string) (*RoleEntry, bool) {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+
+	role, ok := rc.roles[name]
+	if !ok {
+		return nil, false
+	}
+
+	return role.clone(), true
+}
+
+// currentGeneration returns the generation to pass to set for a role about
+// to be read from storage.
+func (rc *roleCache) currentGeneration() uint64 {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+
+	return rc.generation
+}
+
+// set caches the role read from storage unless roles were dropped since
+// generation was returned by currentGeneration, as the role read may then
+// be older than the one in storage.
+func (rc *roleCache) set(name string, role *RoleEntry, generation uint64) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if generation != rc.generation {
+		return
+	}
+
+	rc.roles[name] = role.clone()
+}
+
+// forget drops the cached role with the name.
+func (rc *roleCache) forget(name string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	delete(rc.roles, name)
+	rc.generation++
+}
+
+// clear drops every cached role.
+func (rc *roleCache) clear() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.roles = map[string]*RoleEntry{}
+	rc.generation++
+}
+
+// roleNameFromStorageKey returns the name of the role stored at key, or
+// false if key does not hold a role.
+func roleNameFromStorageKey(key string) (string, bool) {
+	if !strings.HasPrefix(key, "roles/") {
+		return "", false
+	}
+
+	return strings.TrimPrefix(key, "roles/"), true
+}
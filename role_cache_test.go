@@ -0,0 +1,192 @@
+package secretsengine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client/clienttest"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoleCache(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	resp, err := testTokenRoleCreate(t, b, s, "cached", map[string]interface{}{
+		"gc_role":     "Viewer",
+		"ttl":         "60",
+		"bound_cidrs": "10.0.0.0/8",
+	})
+	require.NoError(t, err)
+	require.False(t, resp.IsError())
+
+	role, err := b.getRole(context.Background(), s, "cached")
+	require.NoError(t, err)
+	require.Equal(t, time.Minute, role.TTL)
+
+	// Changes made by callers are not seen by later reads.
+	role.TTL = time.Hour
+	role.BoundCIDRs[0] = "0.0.0.0/0"
+
+	role, err = b.getRole(context.Background(), s, "cached")
+	require.NoError(t, err)
+	require.Equal(t, time.Minute, role.TTL)
+	require.Equal(t, []string{"10.0.0.0/8"}, role.BoundCIDRs)
+
+	// Simulate a write replicated from the active node.
+	entry, err := logical.StorageEntryJSON("roles/cached", &RoleEntry{GrafanaCloudRole: "Admin", TTL: 2 * time.Minute})
+	require.NoError(t, err)
+	require.NoError(t, s.Put(context.Background(), entry))
+
+	role, err = b.getRole(context.Background(), s, "cached")
+	require.NoError(t, err)
+	require.Equal(t, time.Minute, role.TTL, "the role is served from the cache")
+
+	b.invalidate(context.Background(), "roles/cached")
+
+	role, err = b.getRole(context.Background(), s, "cached")
+	require.NoError(t, err)
+	require.Equal(t, 2*time.Minute, role.TTL)
+	require.Equal(t, "Admin", role.GrafanaCloudRole)
+
+	// Writes and deletes on this node drop the cached role.
+	resp, err = testTokenRoleUpdate(t, b, s, "cached", map[string]interface{}{"ttl": "180"})
+	require.NoError(t, err)
+	require.False(t, resp.IsError())
+
+	role, err = b.getRole(context.Background(), s, "cached")
+	require.NoError(t, err)
+	require.Equal(t, 3*time.Minute, role.TTL)
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "roles/cached",
+		Storage:   s,
+	})
+	require.NoError(t, err)
+
+	role, err = b.getRole(context.Background(), s, "cached")
+	require.NoError(t, err)
+	require.Nil(t, role)
+}
+
+// hookStorage calls afterGet once the key has been read, before the entry is
+// returned, to change the storage while a read is in flight.
+type hookStorage struct {
+	logical.Storage
+	afterGet func(key string)
+}
+
+func (s *hookStorage) Get(ctx context.Context, key string) (*logical.StorageEntry, error) {
+	entry, err := s.Storage.Get(ctx, key)
+	if s.afterGet != nil {
+		afterGet := s.afterGet
+		s.afterGet = nil
+		afterGet(key)
+	}
+
+	return entry, err
+}
+
+func TestRoleCacheInvalidatedDuringRead(t *testing.T) {
+	b, inmem := getTestBackend(t)
+	s := &hookStorage{Storage: inmem}
+
+	resp, err := testTokenRoleCreate(t, b, s, "racy", map[string]interface{}{"gc_role": "Viewer", "ttl": "60"})
+	require.NoError(t, err)
+	require.False(t, resp.IsError())
+
+	// The role changes on the active node after this node read the old one.
+	s.afterGet = func(key string) {
+		entry, err := logical.StorageEntryJSON(key, &RoleEntry{GrafanaCloudRole: "Admin", TTL: 2 * time.Minute})
+		require.NoError(t, err)
+		require.NoError(t, inmem.Put(context.Background(), entry))
+
+		b.invalidate(context.Background(), key)
+	}
+
+	role, err := b.getRole(context.Background(), s, "racy")
+	require.NoError(t, err)
+	require.Equal(t, time.Minute, role.TTL, "the read in flight returns the role it read")
+
+	role, err = b.getRole(context.Background(), s, "racy")
+	require.NoError(t, err)
+	require.Equal(t, 2*time.Minute, role.TTL, "the role read before the invalidation is not cached")
+	require.Equal(t, "Admin", role.GrafanaCloudRole)
+}
+
+func TestRoleCacheWrittenDuringRead(t *testing.T) {
+	b, inmem := getTestBackend(t)
+	s := &hookStorage{Storage: inmem}
+
+	resp, err := testTokenRoleCreate(t, b, s, "racy", map[string]interface{}{
+		"gc_role":     "Admin",
+		"ttl":         "60",
+		"bound_cidrs": "0.0.0.0/0",
+	})
+	require.NoError(t, err)
+	require.False(t, resp.IsError())
+
+	// The role is tightened on this node while a creds request is reading it.
+	s.afterGet = func(string) {
+		resp, err := testTokenRoleUpdate(t, b, s, "racy", map[string]interface{}{
+			"gc_role":     "Viewer",
+			"bound_cidrs": "10.0.0.0/8",
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+	}
+
+	role, err := b.getRole(context.Background(), s, "racy")
+	require.NoError(t, err)
+	require.Equal(t, "Admin", role.GrafanaCloudRole, "the read in flight returns the role it read")
+
+	role, err = b.getRole(context.Background(), s, "racy")
+	require.NoError(t, err)
+	require.Equal(t, "Viewer", role.GrafanaCloudRole, "the role read before the write is not cached")
+	require.Equal(t, []string{"10.0.0.0/8"}, role.BoundCIDRs)
+}
+
+// countingStorage counts the reads of the storage keys.
+type countingStorage struct {
+	logical.Storage
+	gets map[string]int
+}
+
+func (s *countingStorage) Get(ctx context.Context, key string) (*logical.StorageEntry, error) {
+	s.gets[key]++
+	return s.Storage.Get(ctx, key)
+}
+
+func TestCredentialsRoleCache(t *testing.T) {
+	server := clienttest.NewServer(organisation, key)
+	defer server.Close()
+
+	b, inmem := getTestBackend(t)
+	s := &countingStorage{Storage: inmem, gets: map[string]int{}}
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          server.URL + "/api",
+		"organisation": organisation,
+	}))
+
+	resp, err := testTokenRoleCreate(t, b, s, "hot", map[string]interface{}{"gc_role": "Viewer"})
+	require.NoError(t, err)
+	require.False(t, resp.IsError())
+
+	s.gets = map[string]int{}
+
+	for i := 0; i < 3; i++ {
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/hot",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+	}
+
+	require.Equal(t, 1, s.gets["roles/hot"], "the role is read from storage once")
+}
@@ -0,0 +1,125 @@
+package secretsengine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// roleDrift returns the problems of the roles that no longer match the config,
+// keyed by role name. Roles are only validated when they are written, so a
+// gc_role removed from valid_gc_roles goes unnoticed until keys fail to be
+// issued.
+func (b *grafanaCloudBackend) roleDrift(ctx context.Context, s logical.Storage, config *Config) (map[string][]string, error) {
+	names, err := s.List(ctx, "roles/")
+	if err != nil {
+		return nil, NewInternalError("error listing roles", err)
+	}
+
+	drift := map[string][]string{}
+
+	for _, name := range names {
+		role, err := b.getRole(ctx, s, name)
+		if err != nil {
+			return nil, err
+		}
+
+		if role == nil {
+			continue
+		}
+
+		var problems []string
+
+		if !config.cloudRoles()[role.GrafanaCloudRole] {
+			problems = append(problems, fmt.Sprintf("gc_role %q is no longer accepted", role.GrafanaCloudRole))
+		}
+
+		if len(problems) > 0 {
+			drift[name] = problems
+		}
+	}
+
+	return drift, nil
+}
+
+// logRoleDrift logs a warning for every role that drifted, called by the
+// periodic function.
+func (b *grafanaCloudBackend) logRoleDrift(ctx context.Context, s logical.Storage, config *Config) {
+	drift, err := b.roleDrift(ctx, s, config)
+	if err != nil {
+		b.Logger().Warn("failed to check the roles for drift", "error", err)
+		return
+	}
+
+	names := make([]string, 0, len(drift))
+	for name := range drift {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		b.Logger().Warn("role no longer matches the config, issuing keys for it may fail",
+			"role", name, "problems", strings.Join(drift[name], "; "))
+	}
+}
+
+// pathRoleDrift extends the Vault API with a `/role-drift`
+// endpoint reporting the roles that no longer match
+// the config.
+func pathRoleDrift(b *grafanaCloudBackend) *framework.Path {
+	return &framework.Path{
+		Pattern: "role-drift/?$",
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathRoleDriftRead,
+				Summary:  "Check the roles against the config.",
+			},
+		},
+		HelpSynopsis:    pathRoleDriftHelpSyn,
+		HelpDescription: pathRoleDriftHelpDesc,
+	}
+}
+
+const pathRoleDriftHelpSyn = `
+Report the roles that no longer match the config.
+`
+
+const pathRoleDriftHelpDesc = `
+Roles are validated when they are written. This path checks every role again:
+that its gc_role is still accepted by the config. Roles with problems are
+returned with the list of their problems. The same check runs every 10
+minutes and logs a warning per role.
+`
+
+func (b *grafanaCloudBackend) pathRoleDriftRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	config, err := getConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if config == nil {
+		return logical.ErrorResponse("backend not configured"), nil
+	}
+
+	drift, err := b.roleDrift(ctx, req.Storage, config)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	roles := make(map[string]interface{}, len(drift))
+	for name, problems := range drift {
+		roles[name] = problems
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"ok":    len(drift) == 0,
+			"roles": roles,
+		},
+	}, nil
+}
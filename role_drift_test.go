@@ -0,0 +1,64 @@
+package secretsengine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoleDrift(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          configURL,
+		"organisation": organisation,
+	}))
+
+	roles := map[string]map[string]interface{}{
+		"metrics": {"gc_role": "MetricsPublisher"},
+		"plugins": {"gc_role": "PluginPublisher"},
+		"viewer":  {"gc_role": "Viewer"},
+	}
+
+	for name, data := range roles {
+		resp, err := testTokenRoleCreate(t, b, s, name, data)
+		require.NoError(t, err)
+		require.False(t, resp.IsError(), name)
+	}
+
+	read := func(t *testing.T) *logical.Response {
+		t.Helper()
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "role-drift",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+
+		return resp
+	}
+
+	t.Run("No drift", func(t *testing.T) {
+		resp := read(t)
+		assert.Equal(t, true, resp.Data["ok"])
+		assert.Empty(t, resp.Data["roles"])
+	})
+
+	t.Run("Drift", func(t *testing.T) {
+		require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{
+			"valid_gc_roles": "Viewer,Admin,Editor,MetricsPublisher",
+		}))
+
+		resp := read(t)
+		assert.Equal(t, false, resp.Data["ok"])
+		assert.Equal(t, map[string]interface{}{
+			"plugins": []string{`gc_role "PluginPublisher" is no longer accepted`},
+		}, resp.Data["roles"])
+	})
+}
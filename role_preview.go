@@ -0,0 +1,27 @@
+package secretsengine
+
+// rolePreview describes the keys the role would issue with the current
+// config, returned by role writes with preview=true so mistakes in the name
+// settings or the gc_role show up before the role is used. Nothing is
+// created, the name holds an example UUID.
+func (b *grafanaCloudBackend) rolePreview(roleName string, role *RoleEntry, config *Config) (map[string]interface{}, []string) {
+	var warnings []string
+
+	if config == nil {
+		warnings = append(warnings, "the backend is not configured, token_prefix and environment are not applied to the preview")
+		config = &Config{}
+	}
+
+	ttl, maxTTL := role.effectiveTTLs(b.System())
+
+	preview := map[string]interface{}{
+		"key_name":        keyName(config, roleName),
+		"key_name_format": keyNameFormat(config),
+		"gc_role":         role.GrafanaCloudRole,
+		"ttl":             int64(ttl.Seconds()),
+		"max_ttl":         int64(maxTTL.Seconds()),
+		"renewable":       !role.NonRenewable,
+	}
+
+	return preview, warnings
+}
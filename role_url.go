@@ -0,0 +1,54 @@
+package secretsengine
+
+import (
+	"context"
+	"time"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// clientFor returns the client to issue and delete keys with against apiURL,
+// the url of a role: the client of the config when it is empty, otherwise one
+// authenticated with the admin key of the config against apiURL, e.g. a
+// staging gateway. Factories are kept until reset.
+func (b *grafanaCloudBackend) clientFor(ctx context.Context, s logical.Storage, config *Config, apiURL string) (client.API, error) {
+	if apiURL == "" {
+		return b.getClient(ctx, s)
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if cached, ok := b.urlClients[apiURL]; ok {
+		return cached.newClient(correlationOptions(ctx)...)
+	}
+
+	baseURL, err := normalizeAPIURL(apiURL)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient, err := config.httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	key := config.Key
+	cached := &cachedClient{
+		newClient: func(opts ...client.Option) (client.API, error) {
+			return client.New(baseURL, key, httpClient, opts...)
+		},
+		baseURL:   baseURL,
+		auth:      clientAuthAdminKey,
+		createdAt: time.Now(),
+	}
+
+	if b.urlClients == nil {
+		b.urlClients = map[string]*cachedClient{}
+	}
+
+	b.urlClients[apiURL] = cached
+
+	return cached.newClient(correlationOptions(ctx)...)
+}
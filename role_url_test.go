@@ -0,0 +1,81 @@
+package secretsengine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client/clienttest"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoleURL(t *testing.T) {
+	production := clienttest.NewServer(organisation, key)
+	defer production.Close()
+
+	staging := clienttest.NewServer(organisation, key)
+	defer staging.Close()
+
+	b, s := getTestBackend(t)
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          production.URL + "/api",
+		"organisation": organisation,
+	}))
+
+	issueAndRevoke := func(t *testing.T, roleName string, issued func() int) {
+		t.Helper()
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/" + roleName,
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+		require.Equal(t, 1, issued())
+
+		_, err = b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Storage:   s,
+			Secret:    resp.Secret,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 0, issued())
+	}
+
+	t.Run("Invalid url", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "invalid", map[string]interface{}{
+			"gc_role": "Viewer",
+			"url":     "not a url",
+		})
+		require.NoError(t, err)
+		require.True(t, resp.IsError())
+	})
+
+	t.Run("Keys", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "metrics", map[string]interface{}{
+			"gc_role": "MetricsPublisher",
+			"url":     staging.URL + "/api/",
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+		assert.Equal(t, staging.URL, resp.Data["url"])
+
+		issueAndRevoke(t, "metrics", func() int { return len(staging.CloudKeys()) })
+		assert.Empty(t, production.Requests())
+	})
+
+	t.Run("Unset", func(t *testing.T) {
+		resp, err := testTokenRoleCreate(t, b, s, "metrics", map[string]interface{}{
+			"gc_role": "MetricsPublisher",
+			"url":     "",
+		})
+		require.NoError(t, err)
+		assert.NotContains(t, resp.Data, "url")
+
+		issueAndRevoke(t, "metrics", func() int { return len(production.CloudKeys()) })
+	})
+}
@@ -0,0 +1,102 @@
+package secretsengine
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// secretDataVersion is the version of the internal data written to secrets.
+// Version 1, used before the version was recorded, only holds the key name,
+// which renewals derive the role from. Version 2 also holds the role and the
+// id of the key, plus the url of keys issued for a role with a url.
+const secretDataVersion = 2
+
+// secretData is the internal data of a secret, which identifies the key to
+// renew and revoke.
+type secretData struct {
+	Version int
+	Name    string
+	Role    string
+	ID      int64
+	URL     string
+}
+
+// secretData returns the secret internal data needed to renew and revoke the key.
+func (k *GrafanaCloudKey) secretData(roleName string) *secretData {
+	return &secretData{
+		Version: secretDataVersion,
+		Name:    k.Name,
+		Role:    roleName,
+		ID:      k.ID,
+		URL:     k.URL,
+	}
+}
+
+// internalData returns the data as stored in the secret.
+func (d *secretData) internalData() map[string]interface{} {
+	data := map[string]interface{}{
+		"version": d.Version,
+		"name":    d.Name,
+		"role":    d.Role,
+	}
+
+	if d.ID != 0 {
+		data["id"] = strconv.FormatInt(d.ID, 10)
+	}
+
+	if d.URL != "" {
+		data["url"] = d.URL
+	}
+
+	return data
+}
+
+// parseSecretData reads the internal data of a secret issued by any version
+// of the plugin.
+func parseSecretData(internalData map[string]interface{}) (*secretData, error) {
+	version, err := secretDataVersionOf(internalData["version"])
+	if err != nil {
+		return nil, err
+	}
+
+	if version > secretDataVersion {
+		return nil, NewInternalError(fmt.Sprintf("secret internal data version %d is newer than this plugin supports, upgrade the plugin", version), nil)
+	}
+
+	data := &secretData{Version: version}
+	data.Name, _ = internalData["name"].(string)
+	data.Role, _ = internalData["role"].(string)
+	data.URL, _ = internalData["url"].(string)
+
+	if idRaw, ok := internalData["id"].(string); ok && idRaw != "" {
+		if data.ID, err = strconv.ParseInt(idRaw, 10, 64); err != nil {
+			return nil, NewInternalError(fmt.Sprintf("secret has an invalid id %q in its internal data", idRaw), err)
+		}
+	}
+
+	return data, nil
+}
+
+// secretDataVersionOf returns the version recorded in the internal data. It
+// is an int when the secret was just issued, and a number decoded from JSON
+// once Vault has stored the lease.
+func secretDataVersionOf(raw interface{}) (int, error) {
+	switch v := raw.(type) {
+	case nil:
+		return 1, nil
+	case int:
+		return v, nil
+	case float64:
+		return int(v), nil
+	case json.Number:
+		version, err := v.Int64()
+		if err != nil {
+			return 0, NewInternalError(fmt.Sprintf("secret has an invalid version %q in its internal data", v), err)
+		}
+
+		return int(version), nil
+	default:
+		return 0, NewInternalError(fmt.Sprintf("secret has an invalid version %v in its internal data", raw), nil)
+	}
+}
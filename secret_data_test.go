@@ -0,0 +1,114 @@
+package secretsengine
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client/clienttest"
+	grafanclient "github.com/grafana/grafana-api-golang-client"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretData(t *testing.T) {
+	t.Run("Round trip", func(t *testing.T) {
+		key := &GrafanaCloudKey{Name: "role_uuid", ID: 42, URL: "https://staging.example"}
+
+		internalData := key.secretData("role").internalData()
+
+		// Vault decodes the stored lease from JSON.
+		encoded, err := json.Marshal(internalData)
+		require.NoError(t, err)
+
+		decoded := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(encoded, &decoded))
+
+		data, err := parseSecretData(decoded)
+		require.NoError(t, err)
+		assert.Equal(t, key.secretData("role"), data)
+
+		data, err = parseSecretData(map[string]interface{}{"version": json.Number("2"), "name": "role_uuid"})
+		require.NoError(t, err)
+		assert.Equal(t, 2, data.Version)
+	})
+
+	t.Run("Version 1", func(t *testing.T) {
+		data, err := parseSecretData(map[string]interface{}{"name": "role_uuid"})
+		require.NoError(t, err)
+		assert.Equal(t, &secretData{Version: 1, Name: "role_uuid"}, data)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := parseSecretData(map[string]interface{}{"version": 3})
+		assert.EqualError(t, err, "internal error: secret internal data version 3 is newer than this plugin supports, upgrade the plugin")
+
+		_, err = parseSecretData(map[string]interface{}{"id": "forty-two"})
+		assert.ErrorContains(t, err, `secret has an invalid id "forty-two" in its internal data`)
+	})
+}
+
+func TestRevokeVersion1Secret(t *testing.T) {
+	server := clienttest.NewServer(organisation, key)
+	defer server.Close()
+
+	b, s := getTestBackend(t)
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          server.URL + "/api",
+		"organisation": organisation,
+	}))
+
+	c, err := grafanclient.New(server.URL, grafanclient.Config{APIKey: key})
+	require.NoError(t, err)
+
+	_, err = c.CreateCloudAPIKey(organisation, &grafanclient.CreateCloudAPIKeyInput{Name: "old-role_uuid", Role: "Viewer"})
+	require.NoError(t, err)
+
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RevokeOperation,
+		Storage:   s,
+		Secret: &logical.Secret{
+			InternalData: map[string]interface{}{
+				"secret_type": grafanaCloudKeyType,
+				"name":        "old-role_uuid",
+			},
+		},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, server.CloudKeys())
+}
+
+func TestRenewVersion1Secret(t *testing.T) {
+	b, s := getTestBackend(t)
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          "https://grafana.example/api",
+		"organisation": organisation,
+		"token_prefix": "vault",
+	}))
+
+	_, err := testTokenRoleCreate(t, b, s, "old-role", map[string]interface{}{
+		"gc_role": "Viewer",
+		"ttl":     "1h",
+	})
+	require.NoError(t, err)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.RenewOperation,
+		Storage:   s,
+		Secret: &logical.Secret{
+			InternalData: map[string]interface{}{
+				"secret_type": grafanaCloudKeyType,
+				"name":        "vault_old-role_0f8fad5b-d9cb-469f-a165-70867728950e",
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.False(t, resp.IsError())
+	assert.Equal(t, time.Hour, resp.Secret.TTL)
+}
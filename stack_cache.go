@@ -0,0 +1,93 @@
+package secretsengine
+
+import (
+	"sync"
+	"time"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client"
+	grafanclient "github.com/grafana/grafana-api-golang-client"
+)
+
+// defaultStackCacheTTL is how long stack metadata is cached when the config
+// does not set stack_cache_ttl.
+const defaultStackCacheTTL = 5 * time.Minute
+
+// stackCache keeps the stacks looked up by slug, so reading stack-info does
+// not need a round-trip to Grafana Cloud each time.
+// It is kept in memory and emptied whenever the config changes.
+type stackCache struct {
+	mu     sync.Mutex
+	stacks map[string]cachedStack
+}
+
+type cachedStack struct {
+	stack     grafanclient.Stack
+	fetchedAt time.Time
+}
+
+func newStackCache() *stackCache {
+	return &stackCache{stacks: map[string]cachedStack{}}
+}
+
+// get returns the stack with the slug, fetching it with c if it is not cached
+// or was fetched more than ttl ago. A zero ttl disables the cache.
+func (sc *stackCache) get(c client.API, slug string, ttl time.Duration, now time.Time) (grafanclient.Stack, error) {
+	sc.mu.Lock()
+	cached, ok := sc.stacks[slug]
+	sc.mu.Unlock()
+
+	if ok && now.Sub(cached.fetchedAt) < ttl {
+		return cached.stack, nil
+	}
+
+	stack, err := c.StackBySlug(slug)
+	if err != nil {
+		return stack, err
+	}
+
+	if ttl > 0 {
+		sc.mu.Lock()
+		sc.stacks[slug] = cachedStack{stack: stack, fetchedAt: now}
+		sc.mu.Unlock()
+	}
+
+	return stack, nil
+}
+
+// forget drops the cached stack with the slug, e.g. after its status changed.
+func (sc *stackCache) forget(slug string) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	delete(sc.stacks, slug)
+}
+
+// clear drops every cached stack.
+func (sc *stackCache) clear() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.stacks = map[string]cachedStack{}
+}
+
+// entries returns the cached stacks and when each was fetched.
+func (sc *stackCache) entries() map[string]cachedStack {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	entries := make(map[string]cachedStack, len(sc.stacks))
+	for slug, cached := range sc.stacks {
+		entries[slug] = cached
+	}
+
+	return entries
+}
+
+// stackCacheTTL returns how long stack metadata is cached for.
+func (c *Config) stackCacheTTL() time.Duration {
+	if c.StackCacheTTL == nil {
+		return defaultStackCacheTTL
+	}
+
+	return *c.StackCacheTTL
+}
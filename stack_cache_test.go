@@ -0,0 +1,74 @@
+package secretsengine
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client/clienttest"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStackCache(t *testing.T) {
+	server := clienttest.NewServer(organisation, key)
+	defer server.Close()
+
+	server.AddStack("teststack")
+
+	b, s := getTestBackend(t)
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          server.URL + "/api",
+		"organisation": organisation,
+	}))
+
+	lookups := func() int {
+		count := 0
+
+		for _, r := range server.Requests() {
+			if r.Method == http.MethodGet && r.Path == "/api/instances/teststack" {
+				count++
+			}
+		}
+
+		return count
+	}
+
+	readStackInfo := func(t *testing.T) {
+		t.Helper()
+
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "stack-info/teststack",
+			Storage:   s,
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+	}
+
+	readStackInfo(t)
+	readStackInfo(t)
+	assert.Equal(t, 1, lookups())
+
+	t.Run("Expiry", func(t *testing.T) {
+		c, err := b.getClient(context.Background(), s)
+		require.NoError(t, err)
+
+		_, err = b.stacks.get(c, "teststack", time.Minute, time.Now().Add(2*time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, 2, lookups())
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{"stack_cache_ttl": 0}))
+
+		before := lookups()
+		readStackInfo(t)
+		readStackInfo(t)
+		assert.Equal(t, before+2, lookups())
+	})
+}
@@ -0,0 +1,121 @@
+package secretsengine
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-cleanhttp"
+)
+
+//nolint:gochecknoglobals // lookup table for tls_min_version.
+var tlsVersions = map[string]uint16{
+	"tls12": tls.VersionTLS12,
+	"tls13": tls.VersionTLS13,
+}
+
+// parseTLSMinVersion returns the TLS version named by tls_min_version.
+func parseTLSMinVersion(name string) (uint16, error) {
+	version, ok := tlsVersions[strings.ToLower(name)]
+	if !ok {
+		return 0, NewInvalidConfigurationError(fmt.Sprintf("invalid tls_min_version %q, expected one of tls12, tls13", name), nil)
+	}
+
+	return version, nil
+}
+
+// parseCipherSuites returns the IDs of the named cipher suites. Only the
+// suites Go considers secure are accepted.
+func parseCipherSuites(names []string) ([]uint16, error) {
+	supported := map[string]uint16{}
+	for _, suite := range tls.CipherSuites() {
+		supported[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+
+	for _, name := range names {
+		id, ok := supported[strings.ToUpper(strings.TrimSpace(name))]
+		if !ok {
+			known := make([]string, 0, len(supported))
+			for suite := range supported {
+				known = append(known, suite)
+			}
+
+			sort.Strings(known)
+
+			return nil, NewInvalidConfigurationError(fmt.Sprintf("unsupported tls_cipher_suites entry %q, expected one of %s",
+				name, strings.Join(known, ", ")), nil)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// tlsConfig returns the TLS settings for connections to Grafana Cloud,
+// or nil to use the Go defaults.
+func (c *Config) tlsConfig() (*tls.Config, error) {
+	if c.TLSMinVersion == "" && len(c.TLSCipherSuites) == 0 && c.CACertificate == "" {
+		return nil, nil
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if c.CACertificate != "" {
+		certs, err := caCertificates(c.CACertificate)
+		if err != nil {
+			return nil, err
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		for _, cert := range certs {
+			pool.AddCert(cert)
+		}
+
+		config.RootCAs = pool
+	}
+
+	if c.TLSMinVersion != "" {
+		version, err := parseTLSMinVersion(c.TLSMinVersion)
+		if err != nil {
+			return nil, err
+		}
+
+		config.MinVersion = version
+	}
+
+	if len(c.TLSCipherSuites) > 0 {
+		suites, err := parseCipherSuites(c.TLSCipherSuites)
+		if err != nil {
+			return nil, err
+		}
+
+		config.CipherSuites = suites
+	}
+
+	return config, nil
+}
+
+// httpClient returns the HTTP client for all requests the plugin makes.
+func (c *Config) httpClient() (*http.Client, error) {
+	tlsConfig, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	client := cleanhttp.DefaultPooledClient()
+	if tlsConfig != nil {
+		client.Transport.(*http.Transport).TLSClientConfig = tlsConfig
+	}
+
+	return client, nil
+}
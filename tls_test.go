@@ -0,0 +1,78 @@
+package secretsengine
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSConfig(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		config, err := (&Config{}).tlsConfig()
+		require.NoError(t, err)
+		assert.Nil(t, config)
+	})
+
+	t.Run("Min version and cipher suites", func(t *testing.T) {
+		config, err := (&Config{
+			TLSMinVersion:   "TLS13",
+			TLSCipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384", " tls_ecdhe_rsa_with_aes_256_gcm_sha384"},
+		}).tlsConfig()
+		require.NoError(t, err)
+		assert.Equal(t, uint16(tls.VersionTLS13), config.MinVersion)
+		assert.Equal(t, []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384, tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384}, config.CipherSuites)
+	})
+
+	t.Run("Cipher suites only", func(t *testing.T) {
+		config, err := (&Config{
+			TLSCipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"},
+		}).tlsConfig()
+		require.NoError(t, err)
+		assert.Equal(t, uint16(tls.VersionTLS12), config.MinVersion)
+	})
+
+	t.Run("CA certificate", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer server.Close()
+
+		client, err := (&Config{}).httpClient()
+		require.NoError(t, err)
+
+		_, err = client.Get(server.URL)
+		require.Error(t, err, "the test server certificate is not trusted by default")
+
+		client, err = (&Config{
+			CACertificate: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})),
+		}).httpClient()
+		require.NoError(t, err)
+
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := (&Config{TLSMinVersion: "tls10"}).tlsConfig()
+		assert.EqualError(t, err, `invalid configuration: invalid tls_min_version "tls10", expected one of tls12, tls13`)
+
+		_, err = (&Config{TLSCipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}}).tlsConfig()
+		assert.ErrorContains(t, err, `invalid configuration: unsupported tls_cipher_suites entry "TLS_RSA_WITH_RC4_128_SHA", expected one of `)
+	})
+
+	t.Run("Config write rejects invalid settings", func(t *testing.T) {
+		b, s := getTestBackend(t)
+
+		err := testConfigCreate(b, s, map[string]interface{}{
+			"key":             key,
+			"url":             configURL,
+			"organisation":    organisation,
+			"tls_min_version": "ssl3",
+		})
+		assert.EqualError(t, err, `invalid configuration: invalid tls_min_version "ssl3", expected one of tls12, tls13`)
+	})
+}
@@ -0,0 +1,60 @@
+package secretsengine
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// tokenHMACKeyStoragePath is where the secret of the mount used to compute
+// the HMAC of issued tokens is kept. It is generated with the first key.
+const tokenHMACKeyStoragePath = "token-hmac-key"
+
+const tokenHMACKeySize = 32
+
+// getTokenHMACKey returns the secret of the mount the HMAC of issued tokens
+// is keyed with, generating and storing it if there is none yet.
+func (b *grafanaCloudBackend) getTokenHMACKey(ctx context.Context, s logical.Storage) ([]byte, error) {
+	b.tokenHMACLock.Lock()
+	defer b.tokenHMACLock.Unlock()
+
+	if b.tokenHMACKey != nil {
+		return b.tokenHMACKey, nil
+	}
+
+	entry, err := s.Get(ctx, tokenHMACKeyStoragePath)
+	if err != nil {
+		return nil, NewInternalError("error reading token HMAC key", err)
+	}
+
+	if entry != nil {
+		b.tokenHMACKey = entry.Value
+		return b.tokenHMACKey, nil
+	}
+
+	key := make([]byte, tokenHMACKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, NewInternalError("error generating token HMAC key", err)
+	}
+
+	if err := s.Put(ctx, &logical.StorageEntry{Key: tokenHMACKeyStoragePath, Value: key}); err != nil {
+		return nil, NewInternalError("error writing token HMAC key", err)
+	}
+
+	b.tokenHMACKey = key
+
+	return b.tokenHMACKey, nil
+}
+
+// tokenHMAC returns the hex encoded HMAC-SHA256 of the token, which
+// identifies it in the issuance records without storing it.
+func tokenHMAC(key []byte, token string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(token))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,116 @@
+package secretsengine
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+const (
+	// urlFailoverThreshold is the number of consecutive failed requests to
+	// url after which requests are sent to fallback_url.
+	urlFailoverThreshold = 3
+	// urlFailoverCooldown is how long requests are sent to fallback_url
+	// before url is tried again.
+	urlFailoverCooldown = 5 * time.Minute
+)
+
+// urlFailoverTransport sends requests for the primary API URL to the
+// fallback URL once the primary has failed urlFailoverThreshold times in a
+// row, and back to the primary after urlFailoverCooldown. Requests to other
+// URLs, such as stack APIs, are passed through.
+type urlFailoverTransport struct {
+	base     http.RoundTripper
+	primary  *url.URL
+	fallback *url.URL
+	logger   hclog.Logger
+	now      func() time.Time
+
+	mu           sync.Mutex
+	failures     int
+	failedOverAt time.Time
+}
+
+func newURLFailoverTransport(base http.RoundTripper, primary, fallback string, logger hclog.Logger) (*urlFailoverTransport, error) {
+	primaryURL, err := url.Parse(primary)
+	if err != nil {
+		return nil, NewInvalidConfigurationError("invalid url", err)
+	}
+
+	fallbackURL, err := url.Parse(fallback)
+	if err != nil {
+		return nil, NewInvalidConfigurationError("invalid fallback_url", err)
+	}
+
+	return &urlFailoverTransport{
+		base:     base,
+		primary:  primaryURL,
+		fallback: fallbackURL,
+		logger:   logger,
+		now:      time.Now,
+	}, nil
+}
+
+func (t *urlFailoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != t.primary.Scheme || req.URL.Host != t.primary.Host || !strings.HasPrefix(req.URL.Path, t.primary.Path) {
+		return t.base.RoundTrip(req)
+	}
+
+	if t.failedOver() {
+		fallback := req.Clone(req.Context())
+		fallback.URL.Scheme = t.fallback.Scheme
+		fallback.URL.Host = t.fallback.Host
+		fallback.URL.Path = t.fallback.Path + strings.TrimPrefix(req.URL.Path, t.primary.Path)
+		fallback.URL.RawPath = ""
+		fallback.Host = ""
+
+		return t.base.RoundTrip(fallback)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	t.record(err != nil || resp.StatusCode >= http.StatusInternalServerError)
+
+	return resp, err
+}
+
+// failedOver reports whether requests are sent to the fallback URL.
+func (t *urlFailoverTransport) failedOver() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.failures < urlFailoverThreshold {
+		return false
+	}
+
+	if t.now().Sub(t.failedOverAt) < urlFailoverCooldown {
+		return true
+	}
+
+	t.logger.Info("trying the primary Grafana Cloud API URL again", "url", t.primary.Redacted())
+	t.failures = 0
+
+	return false
+}
+
+// record counts consecutive failed requests to the primary URL.
+func (t *urlFailoverTransport) record(failed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !failed {
+		t.failures = 0
+		return
+	}
+
+	t.failures++
+
+	if t.failures == urlFailoverThreshold {
+		t.failedOverAt = t.now()
+		t.logger.Warn("the Grafana Cloud API URL keeps failing, switching to fallback_url",
+			"url", t.primary.Redacted(), "fallback_url", t.fallback.Redacted(), "failures", t.failures)
+	}
+}
@@ -0,0 +1,117 @@
+package secretsengine
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client/clienttest"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLFailoverTransport(t *testing.T) {
+	primaryStatus := http.StatusServiceUnavailable
+
+	var paths []string
+
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, "primary "+r.URL.Path)
+		w.WriteHeader(primaryStatus)
+	}))
+	defer primary.Close()
+
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, "fallback "+r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer fallback.Close()
+
+	transport, err := newURLFailoverTransport(http.DefaultTransport, primary.URL+"/grafana", fallback.URL+"/gateway", hclog.NewNullLogger())
+	require.NoError(t, err)
+
+	now := time.Date(2026, time.October, 14, 9, 0, 0, 0, time.UTC)
+	transport.now = func() time.Time { return now }
+
+	get := func(url string) int {
+		resp, err := (&http.Client{Transport: transport}).Get(url)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	for i := 0; i < urlFailoverThreshold; i++ {
+		require.Equal(t, http.StatusServiceUnavailable, get(primary.URL+"/grafana/api/instances"))
+	}
+
+	require.Equal(t, http.StatusOK, get(primary.URL+"/grafana/api/instances"))
+
+	// Other URLs are not failed over.
+	require.Equal(t, http.StatusServiceUnavailable, get(primary.URL+"/stacks/teststack/api/auth/keys"))
+
+	// The primary is tried again after the cooldown.
+	now = now.Add(urlFailoverCooldown)
+	primaryStatus = http.StatusOK
+
+	require.Equal(t, http.StatusOK, get(primary.URL+"/grafana/api/instances"))
+	require.Equal(t, []string{
+		"primary /grafana/api/instances",
+		"primary /grafana/api/instances",
+		"primary /grafana/api/instances",
+		"fallback /gateway/api/instances",
+		"primary /stacks/teststack/api/auth/keys",
+		"primary /grafana/api/instances",
+	}, paths)
+}
+
+func TestFallbackURLCredentials(t *testing.T) {
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer unavailable.Close()
+
+	server := clienttest.NewServer(organisation, key)
+	defer server.Close()
+
+	b, s := getTestBackend(t)
+
+	require.EqualError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          server.URL + "/api",
+		"fallback_url": server.URL,
+		"organisation": organisation,
+	}), "invalid configuration: fallback_url must differ from url")
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          unavailable.URL + "/api",
+		"fallback_url": server.URL + "/api",
+		"organisation": organisation,
+	}))
+
+	resp, err := testTokenRoleCreate(t, b, s, "fallback", map[string]interface{}{"gc_role": "Viewer"})
+	require.NoError(t, err)
+	require.False(t, resp.IsError())
+
+	readCreds := func() (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "creds/fallback",
+			Storage:   s,
+		})
+	}
+
+	for i := 0; i < urlFailoverThreshold; i++ {
+		_, err := readCreds()
+		require.Error(t, err)
+	}
+
+	resp, err = readCreds()
+	require.NoError(t, err)
+	require.False(t, resp.IsError())
+	require.Len(t, server.CloudKeys(), 1)
+}
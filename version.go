@@ -0,0 +1,8 @@
+package secretsengine
+
+// Version is the version of the plugin, reported to Vault as its running
+// version. Release builds set it with
+// -ldflags "-X github.com/form3tech-oss/vault-plugin-secrets-grafanacloud.Version=v1.2.3".
+//
+//nolint:gochecknoglobals // set at link time.
+var Version = "v0.0.0-dev"
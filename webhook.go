@@ -0,0 +1,134 @@
+package secretsengine
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// Types of the events sent to webhook_url.
+const (
+	webhookEventIssued           = "credential.issued"
+	webhookEventRevoked          = "credential.revoked"
+	webhookEventRevocationFailed = "credential.revocation_failed"
+	webhookEventAdminKeyRotated  = "admin_key.rotated"
+)
+
+// webhookSignatureHeader holds the hex encoded HMAC-SHA256 of the request
+// body keyed with webhook_secret, prefixed with "sha256=".
+const webhookSignatureHeader = "X-Vault-Signature"
+
+// webhookTimeout bounds the delivery of an event, which is not retried.
+const webhookTimeout = 10 * time.Second
+
+// webhookEvent is the JSON body posted to webhook_url.
+type webhookEvent struct {
+	Type         string                 `json:"type"`
+	Time         time.Time              `json:"time"`
+	Organisation string                 `json:"organisation"`
+	Data         map[string]interface{} `json:"data"`
+}
+
+// webhookSignature returns the value of webhookSignatureHeader for body.
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// notify posts an event to the configured webhook and pushes it to Loki, if
+// enabled. Delivery happens in the background so a slow or failing receiver
+// never holds up issuance or revocation, failures are logged.
+func (b *grafanaCloudBackend) notify(ctx context.Context, s logical.Storage, eventType string, data map[string]interface{}) {
+	config, err := getConfig(ctx, s)
+	if err != nil {
+		b.Logger().Warn("failed to read config for webhook", "event", eventType, "error", err)
+		return
+	}
+
+	if config == nil {
+		return
+	}
+
+	b.notifyConfig(ctx, s, config, eventType, data)
+}
+
+// notifyConfig is notify with the config already at hand.
+func (b *grafanaCloudBackend) notifyConfig(ctx context.Context, s logical.Storage, config *Config, eventType string, data map[string]interface{}) {
+	if config.WebhookURL == "" && !config.EventsToLoki {
+		return
+	}
+
+	event := &webhookEvent{
+		Type:         eventType,
+		Time:         time.Now().UTC(),
+		Organisation: config.Organisation,
+		Data:         data,
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		b.Logger().Warn("failed to encode webhook event", "event", eventType, "error", err)
+		return
+	}
+
+	b.shipEvent(ctx, s, config, event, body)
+
+	if config.WebhookURL == "" {
+		return
+	}
+
+	httpClient, err := config.httpClient()
+	if err != nil {
+		b.Logger().Warn("failed to deliver webhook event", "event", eventType, "error", err)
+		return
+	}
+
+	webhookURL, secret := config.WebhookURL, config.WebhookSecret
+
+	b.webhooks.Add(1)
+
+	go func() {
+		defer b.webhooks.Done()
+
+		if err := postWebhook(httpClient, webhookURL, secret, body); err != nil {
+			b.Logger().Warn("failed to deliver webhook event", "event", eventType, "error", err)
+		}
+	}()
+}
+
+// postWebhook posts body to webhookURL with httpClient, which carries the
+// TLS settings of the config.
+func postWebhook(httpClient *http.Client, webhookURL, secret string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookSignatureHeader, webhookSignature(secret, body))
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return NewInternalError(fmt.Sprintf("webhook responded with status %d", resp.StatusCode), nil)
+	}
+
+	return nil
+}
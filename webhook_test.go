@@ -0,0 +1,192 @@
+package secretsengine
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/form3tech-oss/vault-plugin-secrets-grafanacloud/client/clienttest"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSignature(t *testing.T) {
+	// echo -n '{"type":"credential.issued"}' | openssl dgst -sha256 -hmac secret
+	require.Equal(t, "sha256=c408509b4d13b5214cdeaf2625c9913bd6cd23759ed8989d26667e46068af72b",
+		webhookSignature("secret", []byte(`{"type":"credential.issued"}`)))
+}
+
+func TestWebhook(t *testing.T) {
+	server := clienttest.NewServer(organisation, key)
+	defer server.Close()
+
+	type delivery struct {
+		body      []byte
+		signature string
+	}
+
+	var (
+		mu         sync.Mutex
+		deliveries []delivery
+	)
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		deliveries = append(deliveries, delivery{body: body, signature: r.Header.Get(webhookSignatureHeader)})
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	b, s := getTestBackend(t)
+
+	lastEvent := func(t *testing.T) webhookEvent {
+		t.Helper()
+
+		b.webhooks.Wait()
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		require.NotEmpty(t, deliveries)
+
+		last := deliveries[len(deliveries)-1]
+		require.Equal(t, webhookSignature("webhook-secret", last.body), last.signature)
+
+		var event webhookEvent
+		require.NoError(t, json.Unmarshal(last.body, &event))
+
+		return event
+	}
+
+	require.NoError(t, testConfigCreate(b, s, map[string]interface{}{
+		"key":          key,
+		"url":          server.URL + "/api",
+		"organisation": organisation,
+	}))
+
+	_, err := testTokenRoleCreate(t, b, s, "cloud-role", map[string]interface{}{
+		"gc_role": "Viewer",
+	})
+	require.NoError(t, err)
+
+	t.Run("Webhook URL without secret", func(t *testing.T) {
+		err := testConfigUpdate(b, s, map[string]interface{}{"webhook_url": receiver.URL})
+		require.EqualError(t, err, "invalid configuration: webhook_secret is required with webhook_url")
+	})
+
+	require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{
+		"webhook_url":    receiver.URL,
+		"webhook_secret": "webhook-secret",
+	}))
+
+	var secret *logical.Secret
+
+	t.Run("Issued", func(t *testing.T) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation:   logical.ReadOperation,
+			Path:        "creds/cloud-role",
+			Storage:     s,
+			EntityID:    "entity-id",
+			DisplayName: "token-app",
+		})
+		require.NoError(t, err)
+		require.False(t, resp.IsError())
+
+		secret = resp.Secret
+
+		event := lastEvent(t)
+		require.Equal(t, webhookEventIssued, event.Type)
+		require.Equal(t, organisation, event.Organisation)
+		require.Equal(t, resp.Data["key_name"], event.Data["key_name"])
+		require.Equal(t, "cloud-role", event.Data["role"])
+		require.Equal(t, "entity-id", event.Data["entity_id"])
+		require.Equal(t, "token-app", event.Data["display_name"])
+		require.NotContains(t, event.Data, "token")
+	})
+
+	t.Run("Revocation failed", func(t *testing.T) {
+		name := secret.InternalData["name"].(string)
+
+		server.InjectError(http.MethodDelete, "/api/orgs/"+organisation+"/api-keys/"+name, http.StatusServiceUnavailable, "try again later")
+		defer server.ClearErrors()
+
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Storage:   s,
+			Secret:    secret,
+		})
+		require.Error(t, err)
+
+		event := lastEvent(t)
+		require.Equal(t, webhookEventRevocationFailed, event.Type)
+		require.Equal(t, name, event.Data["key_name"])
+		require.Equal(t, "cloud-role", event.Data["role"])
+		require.Equal(t, "internal error: error deleting Grafana Cloud key: 503: try again later", event.Data["error"])
+	})
+
+	t.Run("Revoked", func(t *testing.T) {
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.RevokeOperation,
+			Storage:   s,
+			Secret:    secret,
+		})
+		require.NoError(t, err)
+
+		event := lastEvent(t)
+		require.Equal(t, webhookEventRevoked, event.Type)
+		require.Equal(t, secret.InternalData["name"], event.Data["key_name"])
+		require.Equal(t, "cloud-role", event.Data["role"])
+	})
+
+	t.Run("Admin key rotated", func(t *testing.T) {
+		require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{
+			"key":            "new-admin-key",
+			"key_expires_at": "2027-01-01T00:00:00Z",
+		}))
+
+		event := lastEvent(t)
+		require.Equal(t, webhookEventAdminKeyRotated, event.Type)
+		require.Equal(t, "2027-01-01T00:00:00Z", event.Data["key_expires_at"])
+		require.NotContains(t, event.Data, "key")
+
+		mu.Lock()
+		count := len(deliveries)
+		mu.Unlock()
+
+		// Writing the same key again is not a rotation.
+		require.NoError(t, testConfigUpdate(b, s, map[string]interface{}{"key": "new-admin-key"}))
+		b.webhooks.Wait()
+
+		mu.Lock()
+		defer mu.Unlock()
+		require.Len(t, deliveries, count)
+	})
+}
+
+func TestPostWebhookTLS(t *testing.T) {
+	receiver := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer receiver.Close()
+
+	caCertificate := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: receiver.Certificate().Raw}))
+
+	t.Run("Untrusted CA", func(t *testing.T) {
+		httpClient, err := (&Config{}).httpClient()
+		require.NoError(t, err)
+
+		require.ErrorContains(t, postWebhook(httpClient, receiver.URL, "secret", []byte(`{}`)), "certificate")
+	})
+
+	t.Run("CA of the config", func(t *testing.T) {
+		httpClient, err := (&Config{CACertificate: caCertificate}).httpClient()
+		require.NoError(t, err)
+
+		require.NoError(t, postWebhook(httpClient, receiver.URL, "secret", []byte(`{}`)))
+	})
+}